@@ -1,4 +1,4 @@
 #!/bin/bash
 
 docker build -t receipt-processor-challenge .
-docker run -p 8080:8080 receipt-processor-challenge
\ No newline at end of file
+docker run -p 8080:8080 -p 9090:9090 receipt-processor-challenge
\ No newline at end of file
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	account_store "receipt_manager/account_store"
+	idempotency_store "receipt_manager/idempotency_store"
+	response_handler "receipt_manager/response_handler"
+
+	"github.com/gorilla/mux"
+)
+
+func getBalanceHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	accountId := mux.Vars(request)["id"]
+	balance, accountError := accountStore.Balance(accountId)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+
+	response_handler.SendBalanceResponse(accountId, balance, response)
+}
+
+func getAccountReceiptsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	accountId := mux.Vars(request)["id"]
+	credits, accountError := accountStore.Credits(accountId)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+
+	response_handler.SendAccountReceiptsResponse(accountId, credits, response)
+}
+
+func getLedgerHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	accountId := mux.Vars(request)["id"]
+	entries, accountError := accountStore.Entries(accountId)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+	balance, _ := accountStore.Balance(accountId)
+
+	response_handler.SendLedgerResponse(accountId, balance, entries, response)
+}
+
+type redemptionRequest struct {
+	Points        int    `json:"points"`
+	RedemptionKey string `json:"redemptionKey"`
+}
+
+func redemptionHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	redemption := redemptionRequest{}
+	decoderError := json.NewDecoder(request.Body).Decode(&redemption)
+	if decoderError != nil {
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Redemption data decoding failed")
+		return
+	}
+	if redemption.RedemptionKey == "" {
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidRedemption, "Redemption is missing a redemption key")
+		return
+	}
+
+	accountId := mux.Vars(request)["id"]
+	entry, accountError := accountStore.Redeem(accountId, redemption.RedemptionKey, redemption.Points)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+	balance, _ := accountStore.Balance(accountId)
+
+	response_handler.SendRedemptionResponse(accountId, balance, entry, response)
+}
+
+func reconciliationHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	accountId := mux.Vars(request)["id"]
+	credits, accountError := accountStore.Credits(accountId)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+
+	/*
+		Every credited receipt is rescored against the campaigns, catalog and rates in
+		force when it was credited, so only a ledger that disagrees with its receipts
+		shows up as a discrepancy, not later changes to how receipts are scored
+	*/
+	discrepancies := []response_handler.ReceiptDiscrepancy{}
+	for _, credit := range credits {
+		recomputedPoints := 0
+		receipt, storeError := receiptStore.Get(credit.ReceiptId)
+		if storeError == nil {
+			scoringContext, contextSaved := receiptStore.ScoringContext(credit.ReceiptId)
+			if !contextSaved {
+				_, scoringContext, _ = scoreReceipt(receipt)
+			}
+			breakdown, processorError := rescoreReceipt(receipt, scoringContext)
+			if processorError != nil {
+				response_handler.HandleInternalServerError(response)
+				return
+			}
+			recomputedPoints = breakdown.Points
+		}
+
+		if recomputedPoints != credit.Points {
+			discrepancies = append(discrepancies, response_handler.ReceiptDiscrepancy{
+				ReceiptId:        credit.ReceiptId,
+				LedgerPoints:     credit.Points,
+				RecomputedPoints: recomputedPoints,
+			})
+		}
+	}
+
+	response_handler.SendReconciliationResponse(accountId, accountStore.Balanced(), discrepancies, response)
+}
+
+func getExpirationsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	accountId := mux.Vars(request)["id"]
+	expirations, accountError := accountStore.UpcomingExpirations(accountId)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+
+	response_handler.SendExpirationsResponse(accountId, expirations, response)
+}
+
+func eraseAccountHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	/*
+		Erasure removes every receipt on the account, leaving tombstones behind, along
+		with everything else kept about it: webhook retries, delivery records and dead
+		letters, and idempotent responses for its receipts. Subscribers are still told
+		the receipts were deleted, but not whose they were
+	*/
+	accountId := mux.Vars(request)["id"]
+	receiptIds, accountError := accountStore.Erase(accountId)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+
+	webhookDispatcher.EraseAccount(accountId)
+	erasedReceipts := map[string]bool{}
+	for _, receiptId := range receiptIds {
+		receiptStore.Delete(receiptId)
+		erasedReceipts[receiptId] = true
+	}
+	idempotencyStore.Forget(func(storedResponse idempotency_store.Response) bool {
+		return erasedReceipts[path.Base(storedResponse.Header.Get("Location"))]
+	})
+	for _, receiptId := range receiptIds {
+		publishReceiptDeleted(receiptId, "")
+	}
+
+	response_handler.SendNoContentResponse(response)
+}
+
+func handleAccountError(response http.ResponseWriter, accountError error) {
+	switch accountError {
+	case account_store.ErrAccountNotFound:
+		response_handler.HandleNotFoundError(response, response_handler.ErrorCodeAccountNotFound, "The requested account doesn't exist")
+	case account_store.ErrInsufficientPoints:
+		response_handler.HandleConflictError(response, response_handler.ErrorCodeInsufficientPoints, "The account doesn't have enough points")
+	case account_store.ErrRedemptionKeyReused:
+		response_handler.HandleConflictError(response, response_handler.ErrorCodeRedemptionKeyReused, "The redemption key was already used for a different redemption")
+	case account_store.ErrInvalidRedemption:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidRedemption, "Redemption must be for a positive number of points")
+	default:
+		response_handler.HandleInternalServerError(response)
+	}
+}
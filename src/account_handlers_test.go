@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	receipt_processor "receipt_manager/point_calculator"
+	"testing"
+	"time"
+)
+
+func TestReconciliationAfterCampaignChange(test *testing.T) {
+	// Points are fixed at scoring time, so a campaign created afterwards isn't a discrepancy
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	if recorder := serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`, "accountId": "customer-42"}`); recorder.Code != http.StatusOK {
+		test.Fatalf("Processing a receipt, got status %d and %s", recorder.Code, recorder.Body.String())
+	}
+	campaign := `{"name": "New year bonus", "startDate": "2022-01-01", "endDate": "2022-01-31", "bonus": 10}`
+	if recorder := serveTestRequest(router, "POST", "/v1/campaigns", campaign); recorder.Code != http.StatusOK {
+		test.Fatalf("Creating a campaign, got status %d and %s", recorder.Code, recorder.Body.String())
+	}
+
+	var reconciliation struct {
+		Reconciled    bool
+		Discrepancies []json.RawMessage
+	}
+	recorder := serveTestRequest(router, "GET", "/v2/accounts/customer-42/reconciliation", "")
+	json.Unmarshal(recorder.Body.Bytes(), &reconciliation)
+	if !reconciliation.Reconciled || len(reconciliation.Discrepancies) != 0 {
+		test.Errorf("Reconciliation, got %s, but expected no discrepancies", recorder.Body.String())
+	}
+}
+
+func TestReconciliationReportsLedgerMismatch(test *testing.T) {
+	// A credit the stored receipt doesn't score to is reported, even when the cached breakdown agrees with it
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	recorder := serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`, "accountId": "customer-42"}`)
+	if recorder.Code != http.StatusOK {
+		test.Fatalf("Processing a receipt, got status %d and %s", recorder.Code, recorder.Body.String())
+	}
+	var processed struct{ Id string }
+	json.Unmarshal(recorder.Body.Bytes(), &processed)
+	scoringContext, _ := receiptStore.ScoringContext(processed.Id)
+	receiptStore.CacheBreakdown(processed.Id, receipt_processor.Breakdown{Points: 999}, scoringContext)
+	accountStore.PostReceipt("customer-42", processed.Id, 999, time.Time{})
+
+	var reconciliation struct {
+		Reconciled    bool
+		Discrepancies []struct {
+			ReceiptId        string
+			LedgerPoints     int
+			RecomputedPoints int
+		}
+	}
+	recorder = serveTestRequest(router, "GET", "/v2/accounts/customer-42/reconciliation", "")
+	json.Unmarshal(recorder.Body.Bytes(), &reconciliation)
+	if reconciliation.Reconciled || len(reconciliation.Discrepancies) != 1 {
+		test.Fatalf("Reconciliation, got %s, but expected one discrepancy", recorder.Body.String())
+	}
+	discrepancy := reconciliation.Discrepancies[0]
+	if discrepancy.ReceiptId != processed.Id || discrepancy.LedgerPoints != 999 || discrepancy.RecomputedPoints == 999 {
+		test.Errorf("Got discrepancy %+v, but expected ledger points 999 for '%s'", discrepancy, processed.Id)
+	}
+}
@@ -0,0 +1,485 @@
+package receipt_manager
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrInsufficientPoints  = errors.New("insufficient points")
+	ErrInvalidRedemption   = errors.New("redemption must be for a positive number of points")
+	ErrRedemptionKeyReused = errors.New("redemption key was already used for a different redemption")
+)
+
+const (
+	EntryKindReceipt    = "receipt"
+	EntryKindReversal   = "reversal"
+	EntryKindRedemption = "redemption"
+	EntryKindExpiry     = "expiry"
+	EntryKindWriteOff   = "write-off"
+)
+
+// Every transaction posts to a customer account and one of these system
+// accounts, so the ledger as a whole always sums to zero
+const (
+	issuedAccountId   = "system:issued"
+	redeemedAccountId = "system:redeemed"
+	expiredAccountId  = "system:expired"
+	// Points a deleted receipt had credited but its account had already spent
+	writtenOffAccountId = "system:written-off"
+)
+
+type Entry struct {
+	Id            int        `json:"id"`
+	TransactionId int        `json:"transactionId"`
+	AccountId     string     `json:"accountId"`
+	Kind          string     `json:"kind"`
+	Points        int        `json:"points"`
+	ReceiptId     string     `json:"receiptId,omitempty"`
+	RedemptionKey string     `json:"redemptionKey,omitempty"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type Credit struct {
+	ReceiptId  string    `json:"receiptId"`
+	Points     int       `json:"points"`
+	CreditedAt time.Time `json:"creditedAt"`
+}
+
+type Expiration struct {
+	ReceiptId string    `json:"receiptId"`
+	Points    int       `json:"points"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type AccountStore struct {
+	mutex             sync.RWMutex
+	now               func() time.Time
+	entries           []Entry
+	lastEntryId       int
+	lastTransactionId int
+	receiptAccounts   map[string]string
+	redemptions       map[string]Entry
+}
+
+func NewAccountStore() *AccountStore {
+	return &AccountStore{
+		now:             time.Now,
+		receiptAccounts: make(map[string]string),
+		redemptions:     make(map[string]Entry),
+	}
+}
+
+func (store *AccountStore) SetClock(now func() time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.now = now
+}
+
+func (store *AccountStore) PostReceipt(accountId string, receiptId string, points int, expiresAt time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	/*
+		A rescored or reassigned receipt first reverses whatever it is still credited,
+		and neither step may leave an account below zero. Points of the receipt that
+		already expired stay expired, so a rescored receipt is only credited the rest
+	*/
+	previousAccountId, receiptCredited := store.receiptAccounts[receiptId]
+	outstandingPoints := 0
+	creditedPoints := points
+	if receiptCredited {
+		outstandingPoints = store.receiptPoints(previousAccountId, receiptId)
+		previousBalance := store.balance(previousAccountId) - outstandingPoints
+		if previousAccountId == accountId {
+			creditedPoints = max(points-store.expiredPoints(accountId, receiptId), 0)
+			previousBalance += creditedPoints
+		}
+		if previousBalance < 0 {
+			return ErrInsufficientPoints
+		}
+	}
+
+	if receiptCredited {
+		store.post(previousAccountId, issuedAccountId, EntryKindReversal, -outstandingPoints, receiptId, "")
+		delete(store.receiptAccounts, receiptId)
+	}
+	if accountId != "" {
+		store.post(accountId, issuedAccountId, EntryKindReceipt, creditedPoints, receiptId, "")
+		if !expiresAt.IsZero() {
+			store.entries[len(store.entries)-2].ExpiresAt = &expiresAt
+			store.entries[len(store.entries)-1].ExpiresAt = &expiresAt
+		}
+		store.receiptAccounts[receiptId] = accountId
+	}
+	return nil
+}
+
+func (store *AccountStore) ReverseReceipt(receiptId string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	/*
+		Unlike rescoring, reversing a deleted receipt can't be refused. The account gives
+		back what it still has of the receipt's points, and whatever it already spent is
+		written off, so the account never goes below zero
+	*/
+	accountId, receiptCredited := store.receiptAccounts[receiptId]
+	if !receiptCredited {
+		return
+	}
+	outstandingPoints := store.receiptPoints(accountId, receiptId)
+	reversedPoints := min(outstandingPoints, max(store.balance(accountId), 0))
+	store.post(accountId, issuedAccountId, EntryKindReversal, -reversedPoints, receiptId, "")
+	if writtenOffPoints := outstandingPoints - reversedPoints; writtenOffPoints > 0 {
+		store.post(writtenOffAccountId, issuedAccountId, EntryKindWriteOff, -writtenOffPoints, receiptId, "")
+	}
+	delete(store.receiptAccounts, receiptId)
+}
+
+func (store *AccountStore) Redeem(accountId string, redemptionKey string, points int) (Entry, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if points <= 0 {
+		return Entry{}, ErrInvalidRedemption
+	}
+
+	// Replaying a redemption key returns the original entry instead of spending twice
+	redemption, keyUsed := store.redemptions[redemptionKey]
+	if keyUsed {
+		if redemption.AccountId != accountId || redemption.Points != -points {
+			return Entry{}, ErrRedemptionKeyReused
+		}
+		return redemption, nil
+	}
+
+	if !store.accountExists(accountId) {
+		return Entry{}, ErrAccountNotFound
+	}
+	if store.balance(accountId) < points {
+		return Entry{}, ErrInsufficientPoints
+	}
+
+	redemption = store.post(accountId, redeemedAccountId, EntryKindRedemption, -points, "", redemptionKey)
+	store.redemptions[redemptionKey] = redemption
+	return redemption, nil
+}
+
+func (store *AccountStore) Balance(accountId string) (int, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if !store.accountExists(accountId) {
+		return 0, ErrAccountNotFound
+	}
+	return store.balance(accountId), nil
+}
+
+func (store *AccountStore) Entries(accountId string) ([]Entry, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if !store.accountExists(accountId) {
+		return nil, ErrAccountNotFound
+	}
+
+	entries := []Entry{}
+	for _, entry := range store.entries {
+		if entry.AccountId == accountId {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (store *AccountStore) Credits(accountId string) ([]Credit, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if !store.accountExists(accountId) {
+		return nil, ErrAccountNotFound
+	}
+
+	/*
+		Only receipts still credited to the account are listed, at the net points the
+		ledger credited them: every receipt entry less the reversals of earlier ones.
+		Expiries and redemptions spend points rather than uncredit them, so they don't count
+	*/
+	credits := []Credit{}
+	for _, entry := range store.entries {
+		if entry.AccountId != accountId || entry.Kind != EntryKindReceipt {
+			continue
+		}
+		if store.receiptAccounts[entry.ReceiptId] != accountId {
+			continue
+		}
+		credits = removeCredit(credits, entry.ReceiptId)
+		credits = append(credits, Credit{
+			ReceiptId:  entry.ReceiptId,
+			Points:     store.creditedPoints(accountId, entry.ReceiptId),
+			CreditedAt: entry.CreatedAt,
+		})
+	}
+	return credits, nil
+}
+
+func (store *AccountStore) ExpirePoints() []Entry {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Every account's lots that have reached their expiry lose whatever is still unspent
+	now := store.now()
+	expiryEntries := []Entry{}
+	for _, accountId := range store.accountIds() {
+		for _, lot := range store.unspentLots(accountId) {
+			if lot.ExpiresAt.After(now) || lot.Points == 0 {
+				continue
+			}
+			entry := store.post(accountId, expiredAccountId, EntryKindExpiry, -lot.Points, lot.ReceiptId, "")
+			expiryEntries = append(expiryEntries, entry)
+		}
+	}
+	return expiryEntries
+}
+
+func (store *AccountStore) UpcomingExpirations(accountId string) ([]Expiration, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if !store.accountExists(accountId) {
+		return nil, ErrAccountNotFound
+	}
+
+	now := store.now()
+	expirations := []Expiration{}
+	for _, lot := range store.unspentLots(accountId) {
+		if lot.ExpiresAt.After(now) && lot.Points > 0 {
+			expirations = append(expirations, lot)
+		}
+	}
+	sort.SliceStable(expirations, func(i, j int) bool {
+		return expirations[i].ExpiresAt.Before(expirations[j].ExpiresAt)
+	})
+	return expirations, nil
+}
+
+func (store *AccountStore) Balanced() bool {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	total := 0
+	for _, entry := range store.entries {
+		total += entry.Points
+	}
+	return total == 0
+}
+
+func (store *AccountStore) Erase(accountId string) ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if !store.accountExists(accountId) {
+		return nil, ErrAccountNotFound
+	}
+
+	// Erasure is the one exception to the ledger being append-only: every
+	// transaction touching the account is dropped, both sides together
+	erasedTransactions := make(map[int]bool)
+	for _, entry := range store.entries {
+		if entry.AccountId == accountId {
+			erasedTransactions[entry.TransactionId] = true
+		}
+	}
+
+	remainingEntries := []Entry{}
+	for _, entry := range store.entries {
+		if !erasedTransactions[entry.TransactionId] {
+			remainingEntries = append(remainingEntries, entry)
+		}
+	}
+	store.entries = remainingEntries
+
+	receiptIds := []string{}
+	for receiptId, receiptAccountId := range store.receiptAccounts {
+		if receiptAccountId == accountId {
+			receiptIds = append(receiptIds, receiptId)
+			delete(store.receiptAccounts, receiptId)
+		}
+	}
+	for redemptionKey, redemption := range store.redemptions {
+		if redemption.AccountId == accountId {
+			delete(store.redemptions, redemptionKey)
+		}
+	}
+	return receiptIds, nil
+}
+
+func (store *AccountStore) unspentLots(accountId string) []Expiration {
+	/*
+		Each receipt still credited to the account is a lot expiring at its credit's expiry.
+		Spending is taken from the soonest-expiring points first, so the balance is attributed
+		to the latest-expiring lots and whatever is left over has already been spent
+	*/
+	lots := []Expiration{}
+	for _, entry := range store.entries {
+		if entry.AccountId != accountId || entry.Kind != EntryKindReceipt || entry.ExpiresAt == nil {
+			continue
+		}
+		if store.receiptAccounts[entry.ReceiptId] != accountId {
+			continue
+		}
+		lots = removeLot(lots, entry.ReceiptId)
+		lots = append(lots, Expiration{
+			ReceiptId: entry.ReceiptId,
+			Points:    store.receiptPoints(accountId, entry.ReceiptId),
+			ExpiresAt: *entry.ExpiresAt,
+		})
+	}
+	sort.SliceStable(lots, func(i, j int) bool {
+		return lots[i].ExpiresAt.After(lots[j].ExpiresAt)
+	})
+
+	// Points that never expire are counted as unspent before any lot
+	unspentPoints := store.balance(accountId) - store.neverExpiringPoints(accountId)
+	for index := range lots {
+		lotPoints := min(lots[index].Points, max(unspentPoints, 0))
+		unspentPoints -= lotPoints
+		lots[index].Points = lotPoints
+	}
+	return lots
+}
+
+func (store *AccountStore) neverExpiringPoints(accountId string) int {
+	points := 0
+	for receiptId, receiptAccountId := range store.receiptAccounts {
+		if receiptAccountId != accountId {
+			continue
+		}
+		for index := len(store.entries) - 1; index >= 0; index-- {
+			entry := store.entries[index]
+			if entry.AccountId == accountId && entry.ReceiptId == receiptId && entry.Kind == EntryKindReceipt {
+				if entry.ExpiresAt == nil {
+					points += store.receiptPoints(accountId, receiptId)
+				}
+				break
+			}
+		}
+	}
+	return points
+}
+
+func (store *AccountStore) accountIds() []string {
+	accountIds := []string{}
+	seenAccounts := make(map[string]bool)
+	for _, entry := range store.entries {
+		if !seenAccounts[entry.AccountId] && store.accountExists(entry.AccountId) {
+			accountIds = append(accountIds, entry.AccountId)
+		}
+		seenAccounts[entry.AccountId] = true
+	}
+	return accountIds
+}
+
+func (store *AccountStore) post(accountId string, systemAccountId string, kind string, points int, receiptId string, redemptionKey string) Entry {
+	store.lastTransactionId++
+	store.lastEntryId += 2
+	createdAt := store.now().UTC()
+
+	entry := Entry{
+		Id:            store.lastEntryId - 1,
+		TransactionId: store.lastTransactionId,
+		AccountId:     accountId,
+		Kind:          kind,
+		Points:        points,
+		ReceiptId:     receiptId,
+		RedemptionKey: redemptionKey,
+		CreatedAt:     createdAt,
+	}
+	offsetEntry := entry
+	offsetEntry.Id = entry.Id + 1
+	offsetEntry.AccountId = systemAccountId
+	offsetEntry.Points = -points
+
+	store.entries = append(store.entries, entry, offsetEntry)
+	return entry
+}
+
+func (store *AccountStore) accountExists(accountId string) bool {
+	// System accounts are internal to the ledger and never exposed
+	if accountId == issuedAccountId || accountId == redeemedAccountId || accountId == expiredAccountId || accountId == writtenOffAccountId {
+		return false
+	}
+	for _, entry := range store.entries {
+		if entry.AccountId == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+func (store *AccountStore) balance(accountId string) int {
+	balance := 0
+	for _, entry := range store.entries {
+		if entry.AccountId == accountId {
+			balance += entry.Points
+		}
+	}
+	return balance
+}
+
+func (store *AccountStore) receiptPoints(accountId string, receiptId string) int {
+	points := 0
+	for _, entry := range store.entries {
+		if entry.AccountId == accountId && entry.ReceiptId == receiptId {
+			points += entry.Points
+		}
+	}
+	return points
+}
+
+func (store *AccountStore) expiredPoints(accountId string, receiptId string) int {
+	points := 0
+	for _, entry := range store.entries {
+		if entry.AccountId == accountId && entry.ReceiptId == receiptId && entry.Kind == EntryKindExpiry {
+			points -= entry.Points
+		}
+	}
+	return points
+}
+
+func (store *AccountStore) creditedPoints(accountId string, receiptId string) int {
+	points := 0
+	for _, entry := range store.entries {
+		if entry.AccountId != accountId || entry.ReceiptId != receiptId {
+			continue
+		}
+		if entry.Kind == EntryKindReceipt || entry.Kind == EntryKindReversal {
+			points += entry.Points
+		}
+	}
+	return points
+}
+
+func removeCredit(credits []Credit, receiptId string) []Credit {
+	for index, credit := range credits {
+		if credit.ReceiptId == receiptId {
+			return append(credits[:index:index], credits[index+1:]...)
+		}
+	}
+	return credits
+}
+
+func removeLot(lots []Expiration, receiptId string) []Expiration {
+	for index, lot := range lots {
+		if lot.ReceiptId == receiptId {
+			return append(lots[:index:index], lots[index+1:]...)
+		}
+	}
+	return lots
+}
@@ -0,0 +1,313 @@
+package receipt_manager_test
+
+import (
+	as "receipt_manager/account_store"
+	"testing"
+	"time"
+)
+
+func TestBalance(test *testing.T) {
+	testCases := []struct {
+		credits         map[string]int
+		reversedReceipt string
+		expectedBalance int
+	}{
+		{
+			credits:         map[string]int{"abc": 28},
+			expectedBalance: 28,
+		},
+		{
+			credits:         map[string]int{"abc": 28, "def": 109},
+			expectedBalance: 137,
+		},
+		{
+			credits:         map[string]int{"abc": 28, "def": 109},
+			reversedReceipt: "abc",
+			expectedBalance: 109,
+		},
+	}
+
+	for _, testCase := range testCases {
+		store := as.NewAccountStore()
+		for receiptId, points := range testCase.credits {
+			store.PostReceipt("account1", receiptId, points, time.Time{})
+		}
+		if testCase.reversedReceipt != "" {
+			store.ReverseReceipt(testCase.reversedReceipt)
+		}
+
+		balance, err := store.Balance("account1")
+		if err != nil {
+			test.Errorf("Balance failed with error: %v", err)
+		}
+		if balance != testCase.expectedBalance {
+			test.Errorf("Credits %v, got balance %d, but expected %d",
+				testCase.credits, balance, testCase.expectedBalance)
+		}
+		if !store.Balanced() {
+			test.Errorf("Credits %v left the ledger unbalanced", testCase.credits)
+		}
+	}
+}
+
+func TestRescoredReceipt(test *testing.T) {
+	store := as.NewAccountStore()
+	store.PostReceipt("account1", "abc", 28, time.Time{})
+	store.PostReceipt("account1", "abc", 40, time.Time{})
+
+	credits, _ := store.Credits("account1")
+	if len(credits) != 1 || credits[0].Points != 40 {
+		test.Errorf("Got credits %+v, but expected a single credit of %d points", credits, 40)
+	}
+
+	entries, _ := store.Entries("account1")
+	expectedKinds := []string{as.EntryKindReceipt, as.EntryKindReversal, as.EntryKindReceipt}
+	if len(entries) != len(expectedKinds) {
+		test.Fatalf("Got %d ledger entries, but expected %d", len(entries), len(expectedKinds))
+	}
+	for index, entry := range entries {
+		if entry.Kind != expectedKinds[index] {
+			test.Errorf("Entry %d is a %s, but expected a %s", index, entry.Kind, expectedKinds[index])
+		}
+	}
+
+	store.PostReceipt("account2", "abc", 40, time.Time{})
+	if balance, _ := store.Balance("account1"); balance != 0 {
+		test.Errorf("Got balance %d after the receipt moved accounts, but expected %d", balance, 0)
+	}
+	if balance, _ := store.Balance("account2"); balance != 40 {
+		test.Errorf("Got balance %d on the new account, but expected %d", balance, 40)
+	}
+}
+
+func TestRedeem(test *testing.T) {
+	testCases := []struct {
+		redemptionKey string
+		points        int
+		expectedErr   error
+	}{
+		{
+			redemptionKey: "first",
+			points:        20,
+			expectedErr:   nil,
+		},
+		{
+			redemptionKey: "second",
+			points:        200,
+			expectedErr:   as.ErrInsufficientPoints,
+		},
+		{
+			redemptionKey: "third",
+			points:        0,
+			expectedErr:   as.ErrInvalidRedemption,
+		},
+		{
+			redemptionKey: "seeded",
+			points:        5,
+			expectedErr:   as.ErrRedemptionKeyReused,
+		},
+	}
+
+	for _, testCase := range testCases {
+		store := as.NewAccountStore()
+		store.PostReceipt("account1", "abc", 28, time.Time{})
+		store.Redeem("account1", "seeded", 1)
+
+		_, err := store.Redeem("account1", testCase.redemptionKey, testCase.points)
+		if err != testCase.expectedErr {
+			test.Errorf("Redeeming %d points with key '%s', got error %v, but expected %v",
+				testCase.points, testCase.redemptionKey, err, testCase.expectedErr)
+		}
+	}
+}
+
+func TestRedeemIsIdempotent(test *testing.T) {
+	store := as.NewAccountStore()
+	store.PostReceipt("account1", "abc", 28, time.Time{})
+
+	firstEntry, _ := store.Redeem("account1", "key", 20)
+	secondEntry, err := store.Redeem("account1", "key", 20)
+	if err != nil {
+		test.Errorf("Replayed redemption failed with error: %v", err)
+	}
+	if firstEntry.Id != secondEntry.Id {
+		test.Errorf("Replayed redemption created entry %d, but expected %d", secondEntry.Id, firstEntry.Id)
+	}
+	if balance, _ := store.Balance("account1"); balance != 8 {
+		test.Errorf("Got balance %d after replayed redemption, but expected %d", balance, 8)
+	}
+}
+
+func TestReversalCannotOverdraw(test *testing.T) {
+	store := as.NewAccountStore()
+	store.PostReceipt("account1", "abc", 28, time.Time{})
+	store.Redeem("account1", "key", 20)
+
+	if err := store.PostReceipt("account1", "abc", 10, time.Time{}); err != as.ErrInsufficientPoints {
+		test.Errorf("Rescoring below spent points returned %v, but expected %v", err, as.ErrInsufficientPoints)
+	}
+	if err := store.PostReceipt("account1", "abc", 25, time.Time{}); err != nil {
+		test.Errorf("Rescoring within the balance failed with error: %v", err)
+	}
+}
+
+func TestReversalWritesOffSpentPoints(test *testing.T) {
+	// Reversing a receipt whose points were partly spent takes back the rest and writes off the shortfall
+	store := as.NewAccountStore()
+	store.PostReceipt("account1", "abc", 28, time.Time{})
+	store.PostReceipt("account1", "def", 10, time.Time{})
+	store.Redeem("account1", "key", 30)
+
+	store.ReverseReceipt("abc")
+	if balance, _ := store.Balance("account1"); balance != 0 {
+		test.Errorf("Got balance %d after reversal, but expected 0", balance)
+	}
+	if credits, _ := store.Credits("account1"); len(credits) != 1 || credits[0].ReceiptId != "def" {
+		test.Errorf("Got credits %v after reversal, but expected only def", credits)
+	}
+	if !store.Balanced() {
+		test.Errorf("Ledger doesn't balance after a written-off reversal")
+	}
+	entries, _ := store.Entries("account1")
+	if reversal := entries[len(entries)-1]; reversal.Kind != as.EntryKindReversal || reversal.Points != -8 {
+		test.Errorf("Got reversal %v, but expected -8 points", reversal)
+	}
+}
+
+func TestErase(test *testing.T) {
+	store := as.NewAccountStore()
+	store.PostReceipt("account1", "abc", 28, time.Time{})
+	store.PostReceipt("account1", "def", 109, time.Time{})
+	store.PostReceipt("account2", "ghi", 15, time.Time{})
+
+	receiptIds, err := store.Erase("account1")
+	if err != nil {
+		test.Errorf("Erase failed with error: %v", err)
+	}
+	if len(receiptIds) != 2 {
+		test.Errorf("Got erased receipts %v, but expected %d", receiptIds, 2)
+	}
+
+	if _, err := store.Balance("account1"); err != as.ErrAccountNotFound {
+		test.Errorf("Balance after erase returned %v, but expected %v", err, as.ErrAccountNotFound)
+	}
+	if _, err := store.Erase("account1"); err != as.ErrAccountNotFound {
+		test.Errorf("Second erase returned %v, but expected %v", err, as.ErrAccountNotFound)
+	}
+	if balance, _ := store.Balance("account2"); balance != 15 || !store.Balanced() {
+		test.Errorf("Erase disturbed the rest of the ledger")
+	}
+}
+
+func TestExpirePoints(test *testing.T) {
+	january := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		redeemedPoints   int
+		now              time.Time
+		expectedExpired  int
+		expectedBalance  int
+		expectedUpcoming int
+	}{
+		{
+			now:              time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC),
+			expectedExpired:  0,
+			expectedBalance:  50,
+			expectedUpcoming: 2,
+		},
+		{
+			now:              time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
+			expectedExpired:  20,
+			expectedBalance:  30,
+			expectedUpcoming: 1,
+		},
+		{
+			redeemedPoints:   15,
+			now:              time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
+			expectedExpired:  5,
+			expectedBalance:  30,
+			expectedUpcoming: 1,
+		},
+		{
+			redeemedPoints:   25,
+			now:              time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC),
+			expectedExpired:  25,
+			expectedBalance:  0,
+			expectedUpcoming: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		store := as.NewAccountStore()
+		store.SetClock(func() time.Time { return testCase.now })
+		store.PostReceipt("account1", "abc", 20, january)
+		store.PostReceipt("account1", "def", 30, june)
+		if testCase.redeemedPoints > 0 {
+			store.Redeem("account1", "key", testCase.redeemedPoints)
+		}
+
+		expiredPoints := 0
+		for _, entry := range store.ExpirePoints() {
+			expiredPoints -= entry.Points
+		}
+		if expiredPoints != testCase.expectedExpired {
+			test.Errorf("At %s, expired %d points, but expected %d",
+				testCase.now.Format("2006-01-02"), expiredPoints, testCase.expectedExpired)
+		}
+
+		if len(store.ExpirePoints()) != 0 {
+			test.Errorf("At %s, a second expiry run expired more points", testCase.now.Format("2006-01-02"))
+		}
+
+		balance, _ := store.Balance("account1")
+		if balance != testCase.expectedBalance {
+			test.Errorf("At %s, got balance %d, but expected %d",
+				testCase.now.Format("2006-01-02"), balance, testCase.expectedBalance)
+		}
+
+		upcoming, _ := store.UpcomingExpirations("account1")
+		if len(upcoming) != testCase.expectedUpcoming {
+			test.Errorf("At %s, got upcoming expirations %+v, but expected %d",
+				testCase.now.Format("2006-01-02"), upcoming, testCase.expectedUpcoming)
+		}
+	}
+}
+
+func TestRescoreAfterExpiry(test *testing.T) {
+	// Rescoring a receipt whose points partly expired doesn't bring the expired points back
+	january := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		redeemedPoints  int
+		rescoredPoints  int
+		expectedBalance int
+	}{
+		{redeemedPoints: 0, rescoredPoints: 120, expectedBalance: 50},
+		{redeemedPoints: 40, rescoredPoints: 120, expectedBalance: 50},
+		{redeemedPoints: 0, rescoredPoints: 80, expectedBalance: 30},
+	}
+
+	for _, testCase := range testCases {
+		store := as.NewAccountStore()
+		store.SetClock(func() time.Time { return time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC) })
+		store.PostReceipt("account1", "abc", 100, january)
+		store.PostReceipt("account1", "def", 30, june)
+		if testCase.redeemedPoints > 0 {
+			store.Redeem("account1", "key", testCase.redeemedPoints)
+		}
+		store.ExpirePoints()
+
+		// An amended purchase date moves the receipt's expiry, so nothing expires again
+		if err := store.PostReceipt("account1", "abc", testCase.rescoredPoints, june); err != nil {
+			test.Errorf("Rescoring to %d points failed with error: %v", testCase.rescoredPoints, err)
+		}
+
+		balance, _ := store.Balance("account1")
+		if balance != testCase.expectedBalance || !store.Balanced() {
+			test.Errorf("Rescoring to %d points after redeeming %d, got balance %d, but expected %d",
+				testCase.rescoredPoints, testCase.redeemedPoints, balance, testCase.expectedBalance)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package receipt_manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	response_handler "receipt_manager/response_handler"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+)
+
+var (
+	ErrMissingOperationId  = errors.New("operation has no operationId")
+	ErrUnhandledOperation  = errors.New("operation has no handler")
+	ErrUndocumentedHandler = errors.New("handler has no operation in the spec")
+	ErrInvalidDeprecation  = errors.New("deprecated server needs an x-deprecated-since date")
+)
+
+// Handlers maps each operationId in the spec to the handler serving it
+type Handlers map[string]http.HandlerFunc
+
+// Servers maps each server URL in the spec to the handlers serving operations under it
+type Servers map[string]Handlers
+
+type serverKey struct{}
+
+// A JSON body that doesn't match its schema, with the schema keyword it failed
+type BodyError struct {
+	Keyword string
+	Field   string
+	Reason  string
+}
+
+func (bodyError *BodyError) Error() string {
+	if bodyError.Field == "" {
+		return "The request body is invalid: " + bodyError.Reason
+	}
+	return fmt.Sprintf("The request body field %q is invalid: %s", bodyError.Field, bodyError.Reason)
+}
+
+func Load(data []byte) (*openapi3.T, error) {
+	spec, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	// Examples aren't checked: the XML and CSV ones are serialized bodies, not schema values
+	if err := spec.Validate(context.Background(), openapi3.DisableExamplesValidation()); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func Route(router *mux.Router, spec *openapi3.T, servers Servers) error {
+	/*
+		Registers one route per spec path under each server that serves it, so the
+		spec is the only list of endpoints and versions. Literal paths are registered
+		before templated ones, putting /receipts/parse ahead of /receipts/{id}. Every
+		operation needs a handler under each of its servers and every handler an
+		operation, or nothing is registered
+	*/
+	documented := map[string]map[string]bool{}
+	for _, path := range spec.Paths.InMatchingOrder() {
+		pathItem := spec.Paths.Value(path)
+		for _, server := range PathServers(spec, pathItem) {
+			if _, _, err := serverDeprecation(server); err != nil {
+				return fmt.Errorf("%w: %s", err, server.URL)
+			}
+			if documented[server.URL] == nil {
+				documented[server.URL] = map[string]bool{}
+			}
+			for method, operation := range pathItem.Operations() {
+				if operation.OperationID == "" {
+					return fmt.Errorf("%w: %s %s", ErrMissingOperationId, method, path)
+				}
+				if servers[server.URL][operation.OperationID] == nil {
+					return fmt.Errorf("%w: %s (%s %s on %s)", ErrUnhandledOperation, operation.OperationID, method, path, server.URL)
+				}
+				documented[server.URL][operation.OperationID] = true
+			}
+		}
+	}
+	for url, handlers := range servers {
+		for operationId := range handlers {
+			if !documented[url][operationId] {
+				return fmt.Errorf("%w: %s on %s", ErrUndocumentedHandler, operationId, url)
+			}
+		}
+	}
+
+	for _, path := range spec.Paths.InMatchingOrder() {
+		pathItem := spec.Paths.Value(path)
+		for _, server := range PathServers(spec, pathItem) {
+			router.HandleFunc(strings.TrimSuffix(server.URL, "/")+path, operationsHandler(spec, path, server, servers[server.URL]))
+		}
+	}
+	return nil
+}
+
+func PathServers(spec *openapi3.T, pathItem *openapi3.PathItem) openapi3.Servers {
+	// A path's own servers replace the document's, and with neither everything is served from /
+	if len(pathItem.Servers) > 0 {
+		return pathItem.Servers
+	}
+	if len(spec.Servers) > 0 {
+		return spec.Servers
+	}
+	return openapi3.Servers{{URL: "/"}}
+}
+
+func ServerURL(request *http.Request) string {
+	// The URL of the spec server the request was routed under, such as /v1, or "" outside the router
+	url, _ := request.Context().Value(serverKey{}).(string)
+	return url
+}
+
+func operationsHandler(spec *openapi3.T, path string, server *openapi3.Server, handlers Handlers) http.HandlerFunc {
+	pathItem := spec.Paths.Value(path)
+	allowedMethods := []string{}
+	for method := range pathItem.Operations() {
+		allowedMethods = append(allowedMethods, method)
+	}
+	sort.Strings(allowedMethods)
+	successor, deprecation, _ := serverDeprecation(server)
+	// Servers with x-legacy-errors answer errors in the shape they had before problem details
+	legacyErrors, _ := server.Extensions["x-legacy-errors"].(bool)
+
+	return func(response http.ResponseWriter, request *http.Request) {
+		if legacyErrors {
+			response = response_handler.LegacyErrors(response)
+		}
+		if successor != "" {
+			// RFC 9745 and RFC 8594 headers point clients at the same path on the successor
+			response.Header().Set("Deprecation", deprecation)
+			successorPath := strings.TrimSuffix(successor, "/") + "/" + strings.TrimPrefix(request.URL.Path, strings.TrimSuffix(server.URL, "/")+"/")
+			response.Header().Set("Link", "<"+successorPath+">; rel=\"successor-version\"")
+		}
+
+		operation := pathItem.GetOperation(request.Method)
+		if operation == nil {
+			response.Header().Set("Allow", strings.Join(allowedMethods, ", "))
+			response_handler.HandleMethodNotAllowed(response)
+			return
+		}
+
+		// Checked before the handler runs, so a client that can't read the answer changes nothing.
+		// Operations with x-negotiated: false, such as the docs, pick their own media type
+		if operation.Extensions["x-negotiated"] != false && !response_handler.Acceptable(response) {
+			response_handler.HandleNotAcceptable(response)
+			return
+		}
+
+		route := &routers.Route{Spec: spec, Server: server, Path: path, PathItem: pathItem, Method: request.Method, Operation: operation}
+		if err := ValidateParameters(request, route, mux.Vars(request)); err != nil {
+			response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidParameter, err.Error())
+			return
+		}
+		request = request.WithContext(context.WithValue(request.Context(), serverKey{}, server.URL))
+		if err := ValidateBody(request, route); err != nil {
+			handleBodyError(response, operation, err)
+			return
+		}
+		handlers[operation.OperationID](response, request)
+	}
+}
+
+func handleBodyError(response http.ResponseWriter, operation *openapi3.Operation, err error) {
+	/*
+		A schema failure gets the code the operation's handler would give the same
+		mistake, looked up by keyword in the request body's x-error-codes. Anything
+		else falls back to its default, except a field of the wrong type, which the
+		handlers' decoders report as request_decoding_failed unless mapped
+	*/
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		response_handler.HandlePayloadTooLargeError(response, fmt.Sprintf("Request body exceeds %d bytes", maxBytesError.Limit))
+		return
+	}
+	code := response_handler.ErrorCodeRequestDecodingFailed
+	var bodyError *BodyError
+	if errors.As(err, &bodyError) {
+		errorCodes, _ := operation.RequestBody.Value.Extensions["x-error-codes"].(map[string]interface{})
+		if keywordCode, mapped := errorCodes[bodyError.Keyword].(string); mapped {
+			code = response_handler.ErrorCode(keywordCode)
+		} else if defaultCode, mapped := errorCodes["default"].(string); mapped && bodyError.Keyword != "type" {
+			code = response_handler.ErrorCode(defaultCode)
+		}
+	}
+	response_handler.HandleBadRequestError(response, code, err.Error())
+}
+
+func serverDeprecation(server *openapi3.Server) (string, string, error) {
+	/*
+		A server with x-successor is deprecated in favor of that server's URL, as of
+		its x-deprecated-since date. The Deprecation header is "@" and the Unix time
+	*/
+	successor, _ := server.Extensions["x-successor"].(string)
+	if successor == "" {
+		return "", "", nil
+	}
+	since, _ := server.Extensions["x-deprecated-since"].(string)
+	sinceDate, err := time.Parse("2006-01-02", since)
+	if err != nil {
+		return "", "", ErrInvalidDeprecation
+	}
+	return successor, "@" + strconv.FormatInt(sinceDate.Unix(), 10), nil
+}
+
+func ValidateBody(request *http.Request, route *routers.Route) error {
+	/*
+		Checks a body the handler will read as JSON against the operation's schema,
+		leaving the body for the handler to read again. That is any body that isn't
+		one of the other media types the operation declares, as handlers read a body
+		without a content type, or with one they don't recognize, as JSON. Under the
+		servers in the request body's x-strict-servers an unrecognized content type is
+		refused by the handler instead. JSON that doesn't parse is left to the handler's
+		decoder, whose error codes clients already match on
+	*/
+	if route.Operation.RequestBody == nil || route.Operation.RequestBody.Value == nil {
+		return nil
+	}
+	requestBody := route.Operation.RequestBody.Value
+	if requestBody.Content.Get("application/json") == nil {
+		return nil
+	}
+	if contentType := request.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		jsonMediaType := err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+		if !jsonMediaType && err == nil && requestBody.Content.Get(mediaType) != nil {
+			return nil
+		}
+		if !jsonMediaType && strictServer(requestBody, ServerURL(request)) {
+			return nil
+		}
+	}
+
+	validated := request.Clone(request.Context())
+	validated.Header.Set("Content-Type", "application/json")
+	err := openapi3filter.ValidateRequestBody(request.Context(), &openapi3filter.RequestValidationInput{
+		Request: validated,
+		Route:   route,
+		Options: &openapi3filter.Options{
+			// Handlers apply their own defaults and ignore read-only fields, as they always have
+			SkipSettingDefaults:        true,
+			ExcludeReadOnlyValidations: true,
+		},
+	}, requestBody)
+	request.Body, request.GetBody, request.ContentLength = validated.Body, validated.GetBody, validated.ContentLength
+	if err == nil {
+		return nil
+	}
+
+	var requestError *openapi3filter.RequestError
+	if errors.As(err, &requestError) && requestError.Reason == "reading failed" {
+		return requestError.Err
+	}
+	var schemaError *openapi3.SchemaError
+	if !errors.As(err, &schemaError) {
+		return nil
+	}
+	field := strings.Join(schemaError.JSONPointer(), ".")
+	return &BodyError{Keyword: schemaError.SchemaField, Field: field, Reason: schemaError.Reason}
+}
+
+func strictServer(requestBody *openapi3.RequestBody, serverURL string) bool {
+	strictServers, _ := requestBody.Extensions["x-strict-servers"].([]interface{})
+	for _, strictServer := range strictServers {
+		if strictServer == serverURL {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateParameters(request *http.Request, route *routers.Route, pathParams map[string]string) error {
+	/*
+		Checks the path, query and header parameters against the spec. Bodies are
+		checked separately by ValidateBody
+	*/
+	err := openapi3filter.ValidateRequest(request.Context(), &openapi3filter.RequestValidationInput{
+		Request:    request,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			ExcludeRequestBody: true,
+		},
+	})
+	if err == nil {
+		return nil
+	}
+
+	var requestError *openapi3filter.RequestError
+	if errors.As(err, &requestError) && requestError.Parameter != nil {
+		reason := requestError.Reason
+		var schemaError *openapi3.SchemaError
+		if errors.As(requestError.Err, &schemaError) {
+			reason = schemaError.Reason
+		} else if reason == "" && requestError.Err != nil {
+			reason = requestError.Err.Error()
+		}
+		return fmt.Errorf("The %s parameter %q is invalid: %s", requestError.Parameter.In, requestError.Parameter.Name, reason)
+	}
+	return err
+}
@@ -0,0 +1,319 @@
+package receipt_manager_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	api_spec "receipt_manager/api_spec"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info:
+    title: Test
+    version: 1.0.0
+paths:
+    /widgets/{id}:
+        get:
+            operationId: getWidget
+            parameters:
+                - name: id
+                  in: path
+                  required: true
+                  schema:
+                      type: string
+                      pattern: "^[a-z]+$"
+            responses:
+                200:
+                    description: The widget
+        delete:
+            operationId: deleteWidget
+            parameters:
+                - name: id
+                  in: path
+                  required: true
+                  schema:
+                      type: string
+            responses:
+                204:
+                    description: The widget was deleted
+    /widgets/new:
+        post:
+            operationId: createWidget
+            responses:
+                200:
+                    description: The created widget
+`
+
+const versionedSpec = `
+openapi: 3.0.3
+info:
+    title: Test
+    version: 2.0.0
+servers:
+    - url: /v1
+    - url: /
+      x-successor: /v1
+      x-deprecated-since: "2026-10-19"
+paths:
+    /widgets/{id}:
+        get:
+            operationId: getWidget
+            parameters:
+                - name: id
+                  in: path
+                  required: true
+                  schema:
+                      type: string
+            responses:
+                200:
+                    description: The widget
+`
+
+func respondWith(statusCode int) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		response.WriteHeader(statusCode)
+	}
+}
+
+func TestRoute(test *testing.T) {
+	spec, err := api_spec.Load([]byte(testSpec))
+	if err != nil {
+		test.Fatalf("Loading the spec failed with error: %v", err)
+	}
+
+	testCases := []struct {
+		name          string
+		servers       api_spec.Servers
+		expectedError error
+	}{
+		{
+			name: "every operation handled",
+			servers: api_spec.Servers{"/": {
+				"getWidget": respondWith(http.StatusOK), "deleteWidget": respondWith(http.StatusNoContent), "createWidget": respondWith(http.StatusOK),
+			}},
+		},
+		{
+			name:          "operation without a handler",
+			servers:       api_spec.Servers{"/": {"getWidget": respondWith(http.StatusOK), "createWidget": respondWith(http.StatusOK)}},
+			expectedError: api_spec.ErrUnhandledOperation,
+		},
+		{
+			name: "handler without an operation",
+			servers: api_spec.Servers{"/": {
+				"getWidget": respondWith(http.StatusOK), "deleteWidget": respondWith(http.StatusNoContent), "createWidget": respondWith(http.StatusOK),
+				"listWidgets": respondWith(http.StatusOK),
+			}},
+			expectedError: api_spec.ErrUndocumentedHandler,
+		},
+		{
+			name: "handlers for a server the spec doesn't have",
+			servers: api_spec.Servers{
+				"/":   {"getWidget": respondWith(http.StatusOK), "deleteWidget": respondWith(http.StatusNoContent), "createWidget": respondWith(http.StatusOK)},
+				"/v9": {"getWidget": respondWith(http.StatusOK)},
+			},
+			expectedError: api_spec.ErrUndocumentedHandler,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := api_spec.Route(mux.NewRouter(), spec, testCase.servers)
+		if !errors.Is(err, testCase.expectedError) {
+			test.Errorf("Case '%s', got error %v, but expected %v", testCase.name, err, testCase.expectedError)
+		}
+	}
+}
+
+func TestRoutedRequests(test *testing.T) {
+	spec, _ := api_spec.Load([]byte(testSpec))
+	router := mux.NewRouter()
+	api_spec.Route(router, spec, api_spec.Servers{"/": {
+		"getWidget": respondWith(http.StatusOK), "deleteWidget": respondWith(http.StatusNoContent), "createWidget": respondWith(http.StatusCreated),
+	}})
+
+	testCases := []struct {
+		method             string
+		path               string
+		expectedStatusCode int
+		expectedAllow      string
+	}{
+		{http.MethodGet, "/widgets/gear", http.StatusOK, ""},
+		{http.MethodDelete, "/widgets/gear", http.StatusNoContent, ""},
+		{http.MethodGet, "/widgets/Gear-1", http.StatusBadRequest, ""},
+		{http.MethodPost, "/widgets/new", http.StatusCreated, ""},
+		{http.MethodGet, "/widgets/new", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodPut, "/widgets/gear", http.StatusMethodNotAllowed, "DELETE, GET"},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(testCase.method, testCase.path, nil))
+		if recorder.Code != testCase.expectedStatusCode {
+			test.Errorf("Request '%s %s', got status %d, but expected %d", testCase.method, testCase.path, recorder.Code, testCase.expectedStatusCode)
+		}
+		if allow := recorder.Header().Get("Allow"); allow != testCase.expectedAllow {
+			test.Errorf("Request '%s %s', got Allow '%s', but expected '%s'", testCase.method, testCase.path, allow, testCase.expectedAllow)
+		}
+	}
+}
+
+func TestVersionedRoutes(test *testing.T) {
+	spec, _ := api_spec.Load([]byte(versionedSpec))
+	router := mux.NewRouter()
+	respondWithServer := func(response http.ResponseWriter, request *http.Request) {
+		response.Write([]byte(api_spec.ServerURL(request)))
+	}
+	err := api_spec.Route(router, spec, api_spec.Servers{"/v1": {"getWidget": respondWithServer}, "/": {"getWidget": respondWithServer}})
+	if err != nil {
+		test.Fatalf("Routing the spec failed with error: %v", err)
+	}
+
+	testCases := []struct {
+		path                string
+		expectedServer      string
+		expectedDeprecation string
+		expectedLink        string
+	}{
+		{"/v1/widgets/gear", "/v1", "", ""},
+		{"/widgets/gear", "/", "@1792368000", `</v1/widgets/gear>; rel="successor-version"`},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testCase.path, nil))
+		if server := recorder.Body.String(); server != testCase.expectedServer {
+			test.Errorf("Request '%s', got server '%s', but expected '%s'", testCase.path, server, testCase.expectedServer)
+		}
+		if deprecation := recorder.Header().Get("Deprecation"); deprecation != testCase.expectedDeprecation {
+			test.Errorf("Request '%s', got Deprecation '%s', but expected '%s'", testCase.path, deprecation, testCase.expectedDeprecation)
+		}
+		if link := recorder.Header().Get("Link"); link != testCase.expectedLink {
+			test.Errorf("Request '%s', got Link '%s', but expected '%s'", testCase.path, link, testCase.expectedLink)
+		}
+	}
+
+	undatedSpec, _ := api_spec.Load([]byte(strings.Replace(versionedSpec, `x-deprecated-since: "2026-10-19"`, `x-deprecated-since: "soon"`, 1)))
+	err = api_spec.Route(mux.NewRouter(), undatedSpec, api_spec.Servers{"/v1": {"getWidget": respondWithServer}, "/": {"getWidget": respondWithServer}})
+	if !errors.Is(err, api_spec.ErrInvalidDeprecation) {
+		test.Errorf("Undated deprecation, got error %v, but expected %v", err, api_spec.ErrInvalidDeprecation)
+	}
+}
+
+func TestDocsHandler(test *testing.T) {
+	spec, _ := api_spec.Load([]byte(testSpec))
+	router := mux.NewRouter()
+	api_spec.Route(router, spec, api_spec.Servers{"/": {
+		"getWidget": respondWith(http.StatusOK), "deleteWidget": respondWith(http.StatusNoContent), "createWidget": respondWith(http.StatusCreated),
+	}})
+	router.HandleFunc("/gadgets", respondWith(http.StatusOK))
+
+	recorder := httptest.NewRecorder()
+	api_spec.DocsHandler(spec, router)(recorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	page := recorder.Body.String()
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		test.Errorf("Got content type '%s', but expected 'text/html; charset=utf-8'", contentType)
+	}
+	expectedContents := []string{
+		`data-method="GET" data-path="/widgets/{id}"`,
+		`data-method="DELETE" data-path="/widgets/{id}"`,
+		`data-method="POST" data-path="/widgets/new"`,
+		`<li><code>/gadgets</code></li>`,
+	}
+	for _, expectedContent := range expectedContents {
+		if !strings.Contains(page, expectedContent) {
+			test.Errorf("Docs page is missing '%s'", expectedContent)
+		}
+	}
+	if strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		test.Errorf("Docs page refers to another host")
+	}
+}
+
+const bodySpec = `
+openapi: 3.0.3
+info:
+    title: Test
+    version: 1.0.0
+paths:
+    /widgets:
+        post:
+            operationId: createWidget
+            requestBody:
+                required: true
+                x-error-codes:
+                    required: missing_fields
+                    default: invalid_widget
+                content:
+                    application/json:
+                        schema:
+                            type: object
+                            required:
+                                - name
+                            properties:
+                                name:
+                                    type: string
+                                    pattern: "^[a-z]+$"
+                                size:
+                                    type: integer
+                                    default: 1
+            responses:
+                200:
+                    description: The created widget
+`
+
+func TestValidateBody(test *testing.T) {
+	// Handlers see the body exactly as sent, and only JSON that parses is checked against the schema
+	spec, _ := api_spec.Load([]byte(bodySpec))
+	router := mux.NewRouter()
+	api_spec.Route(router, spec, api_spec.Servers{"/": {
+		"createWidget": func(response http.ResponseWriter, request *http.Request) {
+			body, _ := io.ReadAll(request.Body)
+			response.Write(body)
+		},
+	}})
+
+	testCases := []struct {
+		name               string
+		contentType        string
+		body               string
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"valid", "application/json", `{"name": "gear"}`, http.StatusOK, ""},
+		{"no content type", "", `{"name": "gear"}`, http.StatusOK, ""},
+		{"missing field", "application/json", `{"size": 2}`, http.StatusBadRequest, "missing_fields"},
+		{"pattern", "application/json", `{"name": "Gear"}`, http.StatusBadRequest, "invalid_widget"},
+		{"wrong type", "application/json; charset=utf-8", `{"name": "gear", "size": "big"}`, http.StatusBadRequest, "request_decoding_failed"},
+		{"unparseable", "application/json", `{"name": `, http.StatusOK, ""},
+		{"other format", "application/xml", `<widget/>`, http.StatusOK, ""},
+		{"too large", "application/json", `{"name": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large"},
+	}
+
+	for _, testCase := range testCases {
+		request := httptest.NewRequest(http.MethodPost, "/widgets", io.NopCloser(strings.NewReader(testCase.body)))
+		if testCase.contentType != "" {
+			request.Header.Set("Content-Type", testCase.contentType)
+		}
+		recorder := httptest.NewRecorder()
+		request.Body = http.MaxBytesReader(recorder, request.Body, 64)
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != testCase.expectedStatusCode {
+			test.Errorf("Case '%s', got status %d, but expected %d", testCase.name, recorder.Code, testCase.expectedStatusCode)
+			continue
+		}
+		if testCase.expectedCode == "" {
+			if body := recorder.Body.String(); body != testCase.body {
+				test.Errorf("Case '%s', handler got body '%s', but expected '%s'", testCase.name, body, testCase.body)
+			}
+		} else if !strings.Contains(recorder.Body.String(), `"code":"`+testCase.expectedCode+`"`) {
+			test.Errorf("Case '%s', got body %s, but expected code %s", testCase.name, recorder.Body.String(), testCase.expectedCode)
+		}
+	}
+}
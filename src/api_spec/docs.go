@@ -0,0 +1,215 @@
+package receipt_manager
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+)
+
+//go:embed docs.html
+var docsTemplateText string
+
+var docsTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{"lower": strings.ToLower}).Parse(docsTemplateText))
+
+var methodOrder = map[string]int{
+	http.MethodGet: 0, http.MethodPost: 1, http.MethodPut: 2, http.MethodPatch: 3, http.MethodDelete: 4,
+}
+
+type docsPage struct {
+	Title        string
+	Version      string
+	Description  string
+	Operations   []docsOperation
+	Undocumented []string
+}
+
+type docsOperation struct {
+	Method       string
+	Path         string
+	OperationId  string
+	Summary      string
+	Description  string
+	Deprecated   bool
+	Parameters   []*openapi3.Parameter
+	ContentTypes []string
+	Example      string
+	Responses    []docsResponse
+}
+
+type docsResponse struct {
+	Status      string
+	Description string
+}
+
+func YAMLHandler(data []byte) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Set("Content-Type", "application/yaml")
+		response.Write(data)
+	}
+}
+
+func JSONHandler(spec *openapi3.T) (http.HandlerFunc, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Set("Content-Type", "application/json")
+		response.Write(data)
+	}, nil
+}
+
+func DocsHandler(spec *openapi3.T, router *mux.Router) http.HandlerFunc {
+	/*
+		Renders the page from the routes registered when it's requested, so it lists
+		exactly what the server answers. Everything it needs is inline, since the
+		page may be opened where there's no internet access
+	*/
+	return func(response http.ResponseWriter, request *http.Request) {
+		page := docsPage{Title: spec.Info.Title, Version: spec.Info.Version, Description: spec.Info.Description}
+		router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			template, err := route.GetPathTemplate()
+			if err != nil {
+				return nil
+			}
+			server, path := specPath(spec, template)
+			if server == nil {
+				page.Undocumented = append(page.Undocumented, template)
+				return nil
+			}
+			successor, _, _ := serverDeprecation(server)
+			page.Operations = append(page.Operations, pathOperations(template, spec.Paths.Value(path), successor != "")...)
+			return nil
+		})
+		// Deprecated aliases go last
+		sort.SliceStable(page.Operations, func(i, j int) bool {
+			if page.Operations[i].Deprecated != page.Operations[j].Deprecated {
+				return !page.Operations[i].Deprecated
+			}
+			return page.Operations[i].Path < page.Operations[j].Path
+		})
+
+		response.Header().Set("Content-Type", "text/html; charset=utf-8")
+		docsTemplate.Execute(response, page)
+	}
+}
+
+func specPath(spec *openapi3.T, template string) (*openapi3.Server, string) {
+	// Finds the server and spec path a route was registered for, such as /v1 and /receipts/{id} for /v1/receipts/{id}
+	for _, path := range spec.Paths.InMatchingOrder() {
+		for _, server := range PathServers(spec, spec.Paths.Value(path)) {
+			if strings.TrimSuffix(server.URL, "/")+path == template {
+				return server, path
+			}
+		}
+	}
+	return nil, ""
+}
+
+func pathOperations(path string, pathItem *openapi3.PathItem, deprecated bool) []docsOperation {
+	operations := []docsOperation{}
+	for method, operation := range pathItem.Operations() {
+		documented := docsOperation{
+			Method:      method,
+			Path:        path,
+			OperationId: operation.OperationID,
+			Summary:     operation.Summary,
+			Description: operation.Description,
+			Deprecated:  deprecated || operation.Deprecated,
+		}
+		for _, parameters := range []openapi3.Parameters{pathItem.Parameters, operation.Parameters} {
+			for _, parameter := range parameters {
+				documented.Parameters = append(documented.Parameters, parameter.Value)
+			}
+		}
+		if operation.RequestBody != nil && operation.RequestBody.Value != nil {
+			for contentType := range operation.RequestBody.Value.Content {
+				documented.ContentTypes = append(documented.ContentTypes, contentType)
+			}
+			// JSON first, as the default the form sends
+			sort.Slice(documented.ContentTypes, func(i, j int) bool {
+				iJSON, jJSON := documented.ContentTypes[i] == "application/json", documented.ContentTypes[j] == "application/json"
+				if iJSON != jJSON {
+					return iJSON
+				}
+				return documented.ContentTypes[i] < documented.ContentTypes[j]
+			})
+			documented.Example = requestExample(operation.RequestBody.Value.Content.Get(documented.ContentTypes[0]))
+		}
+		for status, responseRef := range operation.Responses.Map() {
+			description := ""
+			if responseRef.Value != nil && responseRef.Value.Description != nil {
+				description = *responseRef.Value.Description
+			}
+			documented.Responses = append(documented.Responses, docsResponse{Status: status, Description: description})
+		}
+		sort.Slice(documented.Responses, func(i, j int) bool {
+			return documented.Responses[i].Status < documented.Responses[j].Status
+		})
+		operations = append(operations, documented)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return methodOrder[operations[i].Method] < methodOrder[operations[j].Method]
+	})
+	return operations
+}
+
+func requestExample(mediaType *openapi3.MediaType) string {
+	// The form starts out with the spec's example body, or one built from the schema's examples
+	if mediaType == nil {
+		return ""
+	}
+	example := mediaType.Example
+	if example == nil && mediaType.Schema != nil {
+		example = schemaExample(mediaType.Schema.Value, 0)
+	}
+	if text, isText := example.(string); isText {
+		return text
+	}
+	data, err := json.MarshalIndent(example, "", "  ")
+	if err != nil || example == nil {
+		return ""
+	}
+	return string(data)
+}
+
+func schemaExample(schema *openapi3.Schema, depth int) interface{} {
+	if schema == nil || depth > 5 {
+		return nil
+	}
+	if schema.Example != nil {
+		return schema.Example
+	}
+	switch {
+	case schema.Type.Is("object"):
+		// Required properties are filled in, or every property when none are required
+		properties := schema.Required
+		if len(properties) == 0 {
+			for name := range schema.Properties {
+				properties = append(properties, name)
+			}
+		}
+		example := map[string]interface{}{}
+		for _, name := range properties {
+			if property := schema.Properties[name]; property != nil && !property.Value.ReadOnly {
+				example[name] = schemaExample(property.Value, depth+1)
+			}
+		}
+		return example
+	case schema.Type.Is("array") && schema.Items != nil:
+		return []interface{}{schemaExample(schema.Items.Value, depth+1)}
+	case schema.Type.Is("integer"), schema.Type.Is("number"):
+		return 0
+	case schema.Type.Is("boolean"):
+		return false
+	case schema.Type.Is("string"):
+		return ""
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package receipt_manager
+
+import (
+	"errors"
+	receipt "receipt_manager/receipt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCampaign = errors.New("campaign is invalid")
+
+type Conditions struct {
+	Retailer string `json:"retailer,omitempty"`
+	MinItems int    `json:"minItems,omitempty"`
+	MinTotal string `json:"minTotal,omitempty"`
+}
+
+type Campaign struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	StartDate  string     `json:"startDate"`
+	EndDate    string     `json:"endDate"`
+	Conditions Conditions `json:"conditions"`
+	Multiplier float64    `json:"multiplier,omitempty"`
+	Bonus      int        `json:"bonus,omitempty"`
+}
+
+func (campaign Campaign) Validate() error {
+	startDate, startErr := time.Parse("2006-01-02", campaign.StartDate)
+	endDate, endErr := time.Parse("2006-01-02", campaign.EndDate)
+	if campaign.Name == "" || startErr != nil || endErr != nil || endDate.Before(startDate) {
+		return ErrInvalidCampaign
+	}
+
+	// A campaign must reward something, and a multiplier can never take points away
+	if campaign.Multiplier <= 1 && campaign.Bonus <= 0 {
+		return ErrInvalidCampaign
+	}
+	if (campaign.Multiplier != 0 && campaign.Multiplier < 1) || campaign.Bonus < 0 {
+		return ErrInvalidCampaign
+	}
+
+	if campaign.Conditions.MinItems < 0 {
+		return ErrInvalidCampaign
+	}
+	if campaign.Conditions.MinTotal != "" {
+		if _, err := strconv.ParseFloat(campaign.Conditions.MinTotal, 64); err != nil {
+			return ErrInvalidCampaign
+		}
+	}
+	return nil
+}
+
+func (campaign Campaign) Applies(receipt receipt.Receipt) bool {
+	// Dates are all YYYY-MM-DD, so the window can be checked as strings
+	if receipt.PurchaseDate < campaign.StartDate || receipt.PurchaseDate > campaign.EndDate {
+		return false
+	}
+
+	conditions := campaign.Conditions
+	if conditions.Retailer != "" && !strings.EqualFold(strings.TrimSpace(receipt.Retailer), conditions.Retailer) {
+		return false
+	}
+	units := 0
+	for _, receiptItem := range receipt.Items {
+		units += receiptItem.Units()
+	}
+	if units < conditions.MinItems {
+		return false
+	}
+	if conditions.MinTotal != "" {
+		minTotal, _ := strconv.ParseFloat(conditions.MinTotal, 64)
+		total, err := strconv.ParseFloat(receipt.Total, 64)
+		if err != nil || total < minTotal {
+			return false
+		}
+	}
+	return true
+}
+
+func (campaign Campaign) BonusPoints(basePoints int) int {
+	// Multipliers scale the base points only, so campaigns never compound on each other
+	bonusPoints := campaign.Bonus
+	if campaign.Multiplier > 1 {
+		bonusPoints += int(float64(basePoints) * (campaign.Multiplier - 1))
+	}
+	return bonusPoints
+}
@@ -0,0 +1,141 @@
+package receipt_manager_test
+
+import (
+	campaign "receipt_manager/campaign"
+	item "receipt_manager/item"
+	receipt "receipt_manager/receipt"
+	"testing"
+)
+
+func TestApplies(test *testing.T) {
+	blackFriday := campaign.Campaign{
+		Name:       "Black Friday at Target",
+		StartDate:  "2022-11-21",
+		EndDate:    "2022-11-27",
+		Conditions: campaign.Conditions{Retailer: "Target", MinItems: 2, MinTotal: "10.00"},
+		Multiplier: 2,
+	}
+	items := []item.Item{
+		{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+		{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+	}
+
+	testCases := []struct {
+		receipt         receipt.Receipt
+		expectedApplies bool
+	}{
+		{
+			receipt:         receipt.Receipt{Retailer: "Target", PurchaseDate: "2022-11-25", Items: items, Total: "18.74"},
+			expectedApplies: true,
+		},
+		{
+			receipt:         receipt.Receipt{Retailer: "target ", PurchaseDate: "2022-11-27", Items: items, Total: "18.74"},
+			expectedApplies: true,
+		},
+		{
+			receipt:         receipt.Receipt{Retailer: "Target", PurchaseDate: "2022-11-28", Items: items, Total: "18.74"},
+			expectedApplies: false,
+		},
+		{
+			receipt:         receipt.Receipt{Retailer: "Walgreens", PurchaseDate: "2022-11-25", Items: items, Total: "18.74"},
+			expectedApplies: false,
+		},
+		{
+			receipt:         receipt.Receipt{Retailer: "Target", PurchaseDate: "2022-11-25", Items: items[:1], Total: "6.49"},
+			expectedApplies: false,
+		},
+		{
+			receipt:         receipt.Receipt{Retailer: "Target", PurchaseDate: "2022-11-25", Items: items, Total: "9.99"},
+			expectedApplies: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		applies := blackFriday.Applies(testCase.receipt)
+		if applies != testCase.expectedApplies {
+			test.Errorf("Receipt %+v, campaign applies is %t, but expected %t",
+				testCase.receipt, applies, testCase.expectedApplies)
+		}
+	}
+}
+
+func TestBonusPoints(test *testing.T) {
+	testCases := []struct {
+		campaign       campaign.Campaign
+		basePoints     int
+		expectedPoints int
+	}{
+		{
+			campaign:       campaign.Campaign{Multiplier: 2},
+			basePoints:     28,
+			expectedPoints: 28,
+		},
+		{
+			campaign:       campaign.Campaign{Multiplier: 1.5},
+			basePoints:     29,
+			expectedPoints: 14,
+		},
+		{
+			campaign:       campaign.Campaign{Bonus: 100},
+			basePoints:     28,
+			expectedPoints: 100,
+		},
+		{
+			campaign:       campaign.Campaign{Multiplier: 2, Bonus: 100},
+			basePoints:     28,
+			expectedPoints: 128,
+		},
+	}
+
+	for _, testCase := range testCases {
+		points := testCase.campaign.BonusPoints(testCase.basePoints)
+		if points != testCase.expectedPoints {
+			test.Errorf("Campaign %+v on %d base points, got %d bonus points, but expected %d",
+				testCase.campaign, testCase.basePoints, points, testCase.expectedPoints)
+		}
+	}
+}
+
+func TestValidate(test *testing.T) {
+	testCases := []struct {
+		campaign    campaign.Campaign
+		expectedErr error
+	}{
+		{
+			campaign:    campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31", Bonus: 100},
+			expectedErr: nil,
+		},
+		{
+			campaign:    campaign.Campaign{Name: "March", StartDate: "2023-03-31", EndDate: "2023-03-01", Bonus: 100},
+			expectedErr: campaign.ErrInvalidCampaign,
+		},
+		{
+			campaign:    campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31"},
+			expectedErr: campaign.ErrInvalidCampaign,
+		},
+		{
+			campaign:    campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31", Multiplier: 0.5},
+			expectedErr: campaign.ErrInvalidCampaign,
+		},
+		{
+			campaign:    campaign.Campaign{StartDate: "2023-03-01", EndDate: "2023-03-31", Bonus: 100},
+			expectedErr: campaign.ErrInvalidCampaign,
+		},
+		{
+			campaign:    campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31", Multiplier: 1},
+			expectedErr: campaign.ErrInvalidCampaign,
+		},
+		{
+			campaign:    campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31", Multiplier: 1, Bonus: 5},
+			expectedErr: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.campaign.Validate()
+		if err != testCase.expectedErr {
+			test.Errorf("Campaign %+v, got error %v, but expected %v",
+				testCase.campaign, err, testCase.expectedErr)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	campaign "receipt_manager/campaign"
+	campaign_store "receipt_manager/campaign_store"
+	response_handler "receipt_manager/response_handler"
+
+	"github.com/gorilla/mux"
+)
+
+func campaignsHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		response_handler.SendCampaignsResponse(campaignStore.List(), response)
+	case http.MethodPost:
+		createCampaign(response, request)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func createCampaign(response http.ResponseWriter, request *http.Request) {
+	newCampaign := campaign.Campaign{}
+	decoderError := json.NewDecoder(request.Body).Decode(&newCampaign)
+	if decoderError != nil {
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Campaign data decoding failed")
+		return
+	}
+
+	storedCampaign, campaignError := campaignStore.Add(newCampaign)
+	if campaignError != nil {
+		handleCampaignError(response, campaignError)
+		return
+	}
+
+	response_handler.SendCampaignResponse(storedCampaign, response)
+}
+
+func campaignHandler(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	switch request.Method {
+	case http.MethodGet:
+		storedCampaign, campaignError := campaignStore.Get(id)
+		if campaignError != nil {
+			handleCampaignError(response, campaignError)
+			return
+		}
+		response_handler.SendCampaignResponse(storedCampaign, response)
+	case http.MethodDelete:
+		campaignError := campaignStore.Delete(id)
+		if campaignError != nil {
+			handleCampaignError(response, campaignError)
+			return
+		}
+		response_handler.SendNoContentResponse(response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func handleCampaignError(response http.ResponseWriter, campaignError error) {
+	switch campaignError {
+	case campaign_store.ErrCampaignNotFound:
+		response_handler.HandleNotFoundError(response, response_handler.ErrorCodeCampaignNotFound, "The requested campaign doesn't exist")
+	case campaign.ErrInvalidCampaign:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidCampaign, "Campaign needs a name, a valid date window and a multiplier or bonus")
+	default:
+		response_handler.HandleInternalServerError(response)
+	}
+}
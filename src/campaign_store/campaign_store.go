@@ -0,0 +1,66 @@
+package receipt_manager
+
+import (
+	"errors"
+	campaign "receipt_manager/campaign"
+	"strconv"
+	"sync"
+)
+
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+type CampaignStore struct {
+	mutex          sync.RWMutex
+	campaigns      []campaign.Campaign
+	lastCampaignId int
+}
+
+func NewCampaignStore() *CampaignStore {
+	return &CampaignStore{}
+}
+
+func (store *CampaignStore) Add(newCampaign campaign.Campaign) (campaign.Campaign, error) {
+	if err := newCampaign.Validate(); err != nil {
+		return campaign.Campaign{}, err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.lastCampaignId++
+	newCampaign.Id = "campaign-" + strconv.Itoa(store.lastCampaignId)
+	store.campaigns = append(store.campaigns, newCampaign)
+	return newCampaign, nil
+}
+
+func (store *CampaignStore) List() []campaign.Campaign {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return append([]campaign.Campaign{}, store.campaigns...)
+}
+
+func (store *CampaignStore) Get(id string) (campaign.Campaign, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, storedCampaign := range store.campaigns {
+		if storedCampaign.Id == id {
+			return storedCampaign, nil
+		}
+	}
+	return campaign.Campaign{}, ErrCampaignNotFound
+}
+
+func (store *CampaignStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for index, storedCampaign := range store.campaigns {
+		if storedCampaign.Id == id {
+			store.campaigns = append(store.campaigns[:index:index], store.campaigns[index+1:]...)
+			return nil
+		}
+	}
+	return ErrCampaignNotFound
+}
@@ -0,0 +1,102 @@
+package receipt_manager_test
+
+import (
+	campaign "receipt_manager/campaign"
+	cs "receipt_manager/campaign_store"
+	receipt "receipt_manager/receipt"
+	"testing"
+)
+
+func TestAddAndGet(test *testing.T) {
+	store := cs.NewCampaignStore()
+	newCampaign := campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31", Bonus: 100}
+
+	storedCampaign, err := store.Add(newCampaign)
+	if err != nil {
+		test.Fatalf("Add failed with error: %v", err)
+	}
+	if storedCampaign.Id != "campaign-1" {
+		test.Errorf("Got id '%s', but expected 'campaign-1'", storedCampaign.Id)
+	}
+
+	fetchedCampaign, err := store.Get(storedCampaign.Id)
+	if err != nil {
+		test.Errorf("Get failed with error: %v", err)
+	}
+	if fetchedCampaign != storedCampaign {
+		test.Errorf("Got campaign %+v, but expected %+v", fetchedCampaign, storedCampaign)
+	}
+
+	if _, err := store.Get("missing"); err != cs.ErrCampaignNotFound {
+		test.Errorf("Get of missing id returned %v, but expected %v", err, cs.ErrCampaignNotFound)
+	}
+
+	// A campaign that would award nothing isn't stored
+	newCampaign.Bonus = 0
+	newCampaign.Multiplier = 1
+	if _, err := store.Add(newCampaign); err != campaign.ErrInvalidCampaign {
+		test.Errorf("Add of a campaign awarding nothing returned %v, but expected %v", err, campaign.ErrInvalidCampaign)
+	}
+	if storedCampaigns := store.List(); len(storedCampaigns) != 1 {
+		test.Errorf("Got %d campaigns, but expected only the valid one", len(storedCampaigns))
+	}
+}
+
+func TestList(test *testing.T) {
+	store := cs.NewCampaignStore()
+	store.Add(campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31", Bonus: 100})
+	store.Add(campaign.Campaign{Name: "April", StartDate: "2023-04-01", EndDate: "2023-04-30", Multiplier: 2})
+	store.Add(campaign.Campaign{Name: "May", StartDate: "2023-05-01", EndDate: "2023-05-31", Bonus: 50})
+	store.Delete("campaign-2")
+
+	storedCampaigns := store.List()
+	if len(storedCampaigns) != 2 || storedCampaigns[0].Name != "March" || storedCampaigns[1].Name != "May" {
+		test.Fatalf("Got campaigns %v, but expected March and May", storedCampaigns)
+	}
+
+	// The list is a copy, so changing it leaves the store alone
+	storedCampaigns[0].Bonus = 1
+	if fetchedCampaign, _ := store.Get("campaign-1"); fetchedCampaign.Bonus != 100 {
+		test.Errorf("Got bonus %d after changing the listed copy, but expected 100", fetchedCampaign.Bonus)
+	}
+
+	if err := store.Delete("campaign-2"); err != cs.ErrCampaignNotFound {
+		test.Errorf("Second delete returned %v, but expected %v", err, cs.ErrCampaignNotFound)
+	}
+}
+
+func TestOverlappingCampaigns(test *testing.T) {
+	// Campaigns may overlap, and a receipt in both windows gets both
+	store := cs.NewCampaignStore()
+	store.Add(campaign.Campaign{Name: "March", StartDate: "2023-03-01", EndDate: "2023-03-31", Multiplier: 2})
+	store.Add(campaign.Campaign{Name: "Spring break", StartDate: "2023-03-25", EndDate: "2023-04-05", Bonus: 10})
+
+	testCases := []struct {
+		purchaseDate    string
+		expectedApplied []string
+	}{
+		{purchaseDate: "2023-03-10", expectedApplied: []string{"March"}},
+		{purchaseDate: "2023-03-28", expectedApplied: []string{"March", "Spring break"}},
+		{purchaseDate: "2023-04-02", expectedApplied: []string{"Spring break"}},
+		{purchaseDate: "2023-04-10", expectedApplied: []string{}},
+	}
+
+	for _, testCase := range testCases {
+		applied := []string{}
+		for _, storedCampaign := range store.List() {
+			if storedCampaign.Applies(receipt.Receipt{PurchaseDate: testCase.purchaseDate}) {
+				applied = append(applied, storedCampaign.Name)
+			}
+		}
+		if len(applied) != len(testCase.expectedApplied) {
+			test.Errorf("Purchase date '%s', got campaigns %v, but expected %v", testCase.purchaseDate, applied, testCase.expectedApplied)
+			continue
+		}
+		for index := range applied {
+			if applied[index] != testCase.expectedApplied[index] {
+				test.Errorf("Purchase date '%s', got campaigns %v, but expected %v", testCase.purchaseDate, applied, testCase.expectedApplied)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package receipt_manager
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency = errors.New("currency is not a supported ISO 4217 code")
+	ErrInvalidAmount   = errors.New("amount is not valid for the currency")
+	ErrRateUnavailable = errors.New("no exchange rate between the currencies")
+)
+
+// Receipts without a currency are in the scoring currency, which the point rules were written for
+const ScoringCurrency = "USD"
+
+type Currency struct {
+	Code   string
+	Digits int
+}
+
+var currencies = map[string]Currency{
+	"AUD": {Code: "AUD", Digits: 2},
+	"CAD": {Code: "CAD", Digits: 2},
+	"CHF": {Code: "CHF", Digits: 2},
+	"DKK": {Code: "DKK", Digits: 2},
+	"EUR": {Code: "EUR", Digits: 2},
+	"GBP": {Code: "GBP", Digits: 2},
+	"JPY": {Code: "JPY", Digits: 0},
+	"KRW": {Code: "KRW", Digits: 0},
+	"MXN": {Code: "MXN", Digits: 2},
+	"NOK": {Code: "NOK", Digits: 2},
+	"SEK": {Code: "SEK", Digits: 2},
+	"USD": {Code: "USD", Digits: 2},
+}
+
+func Lookup(code string) (Currency, error) {
+	// An empty code is the scoring currency
+	if code == "" {
+		code = ScoringCurrency
+	}
+	currency, currencyKnown := currencies[code]
+	if !currencyKnown {
+		return Currency{}, ErrUnknownCurrency
+	}
+	return currency, nil
+}
+
+func (currency Currency) amountPattern() *regexp.Regexp {
+	if currency.Digits == 0 {
+		return regexp.MustCompile(`^\d+$`)
+	}
+	return regexp.MustCompile(`^\d+\.\d{` + strconv.Itoa(currency.Digits) + `}$`)
+}
+
+func (currency Currency) ParseAmount(amount string) (int64, error) {
+	// Amounts are held in minor units, e.g. cents, or whole yen for zero-decimal currencies
+	if !currency.amountPattern().MatchString(amount) {
+		return 0, ErrInvalidAmount
+	}
+	minorUnits, err := strconv.ParseInt(strings.Replace(amount, ".", "", 1), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	return minorUnits, nil
+}
+
+func (currency Currency) FormatAmount(minorUnits int64) string {
+	if currency.Digits == 0 {
+		return strconv.FormatInt(minorUnits, 10)
+	}
+	amount := strconv.FormatInt(minorUnits, 10)
+	for len(amount) <= currency.Digits {
+		amount = "0" + amount
+	}
+	return amount[:len(amount)-currency.Digits] + "." + amount[len(amount)-currency.Digits:]
+}
+
+func (currency Currency) majorUnits(minorUnits int64) float64 {
+	return float64(minorUnits) / math.Pow10(currency.Digits)
+}
+
+type RateProvider interface {
+	// Returns how many units of the target currency one unit of the source buys
+	Rate(from string, to string) (float64, error)
+}
+
+type StaticRates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func NewStaticRates(base string, rates map[string]float64) *StaticRates {
+	return &StaticRates{Base: base, Rates: rates}
+}
+
+func LoadRatesFile(path string) (*StaticRates, error) {
+	// The file holds {"base": "USD", "rates": {"CAD": 1.36, ...}}, units of each currency per base unit
+	ratesData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	staticRates := StaticRates{}
+	if err := json.Unmarshal(ratesData, &staticRates); err != nil {
+		return nil, err
+	}
+	if _, err := Lookup(staticRates.Base); err != nil || staticRates.Base == "" {
+		return nil, ErrUnknownCurrency
+	}
+	for code, rate := range staticRates.Rates {
+		if _, err := Lookup(code); err != nil {
+			return nil, err
+		}
+		if rate <= 0 {
+			return nil, ErrRateUnavailable
+		}
+	}
+	return &staticRates, nil
+}
+
+func (staticRates *StaticRates) Rate(from string, to string) (float64, error) {
+	fromRate, fromKnown := staticRates.baseRate(from)
+	toRate, toKnown := staticRates.baseRate(to)
+	if !fromKnown || !toKnown {
+		return 0, ErrRateUnavailable
+	}
+	return toRate / fromRate, nil
+}
+
+func (staticRates *StaticRates) baseRate(code string) (float64, bool) {
+	if code == staticRates.Base {
+		return 1, true
+	}
+	rate, rateKnown := staticRates.Rates[code]
+	return rate, rateKnown
+}
+
+func Convert(amount string, from Currency, to Currency, rate float64) (string, error) {
+	// Converted amounts are rounded to the nearest minor unit of the target currency
+	minorUnits, err := from.ParseAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	convertedMinorUnits := int64(math.Round(from.majorUnits(minorUnits) * rate * math.Pow10(to.Digits)))
+	return to.FormatAmount(convertedMinorUnits), nil
+}
@@ -0,0 +1,82 @@
+package receipt_manager_test
+
+import (
+	currency "receipt_manager/currency"
+	"testing"
+)
+
+func TestParseAmount(test *testing.T) {
+	testCases := []struct {
+		code               string
+		amount             string
+		expectedMinorUnits int64
+		expectedValid      bool
+	}{
+		{code: "USD", amount: "12.50", expectedMinorUnits: 1250, expectedValid: true},
+		{code: "EUR", amount: "0.05", expectedMinorUnits: 5, expectedValid: true},
+		{code: "JPY", amount: "1250", expectedMinorUnits: 1250, expectedValid: true},
+		{code: "JPY", amount: "1250.00", expectedValid: false},
+		{code: "CAD", amount: "12.5", expectedValid: false},
+		{code: "CAD", amount: "12", expectedValid: false},
+	}
+
+	for _, testCase := range testCases {
+		amountCurrency, err := currency.Lookup(testCase.code)
+		if err != nil {
+			test.Fatalf("Currency '%s' failed lookup with error: %v", testCase.code, err)
+		}
+
+		minorUnits, err := amountCurrency.ParseAmount(testCase.amount)
+		if (err == nil) != testCase.expectedValid {
+			test.Errorf("Amount '%s' in %s, got error %v, but expected valid %t",
+				testCase.amount, testCase.code, err, testCase.expectedValid)
+		}
+		if err == nil && minorUnits != testCase.expectedMinorUnits {
+			test.Errorf("Amount '%s' in %s, got %d minor units, but expected %d",
+				testCase.amount, testCase.code, minorUnits, testCase.expectedMinorUnits)
+		}
+	}
+
+	if _, err := currency.Lookup("XYZ"); err != currency.ErrUnknownCurrency {
+		test.Errorf("Currency 'XYZ', got error %v, but expected %v", err, currency.ErrUnknownCurrency)
+	}
+}
+
+func TestConvert(test *testing.T) {
+	rates := currency.NewStaticRates("USD", map[string]float64{"CAD": 1.25, "JPY": 150, "EUR": 0.8})
+
+	testCases := []struct {
+		amount         string
+		from           string
+		to             string
+		expectedAmount string
+	}{
+		{amount: "12.50", from: "CAD", to: "USD", expectedAmount: "10.00"},
+		{amount: "1500", from: "JPY", to: "USD", expectedAmount: "10.00"},
+		{amount: "10.00", from: "USD", to: "JPY", expectedAmount: "1500"},
+		{amount: "8.00", from: "EUR", to: "CAD", expectedAmount: "12.50"},
+		{amount: "1", from: "JPY", to: "USD", expectedAmount: "0.01"},
+	}
+
+	for _, testCase := range testCases {
+		from, _ := currency.Lookup(testCase.from)
+		to, _ := currency.Lookup(testCase.to)
+		rate, err := rates.Rate(testCase.from, testCase.to)
+		if err != nil {
+			test.Fatalf("Rate %s to %s failed with error: %v", testCase.from, testCase.to, err)
+		}
+
+		convertedAmount, err := currency.Convert(testCase.amount, from, to, rate)
+		if err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+		if convertedAmount != testCase.expectedAmount {
+			test.Errorf("Converting %s %s to %s, got %s, but expected %s",
+				testCase.amount, testCase.from, testCase.to, convertedAmount, testCase.expectedAmount)
+		}
+	}
+
+	if _, err := rates.Rate("GBP", "USD"); err != currency.ErrRateUnavailable {
+		test.Errorf("Rate GBP to USD, got error %v, but expected %v", err, currency.ErrRateUnavailable)
+	}
+}
@@ -0,0 +1,61 @@
+package receipt_manager
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownPolicy = errors.New("unknown points expiry policy")
+
+type Policy interface {
+	// Returns when points earned on the purchase date expire; the zero time means never
+	ExpiresAt(purchaseDate time.Time) time.Time
+}
+
+type MonthsAfterPurchase struct {
+	Months int
+}
+
+func (policy MonthsAfterPurchase) ExpiresAt(purchaseDate time.Time) time.Time {
+	return purchaseDate.AddDate(0, policy.Months, 0)
+}
+
+type EndOfCalendarYear struct{}
+
+func (policy EndOfCalendarYear) ExpiresAt(purchaseDate time.Time) time.Time {
+	// Points last through December 31st, so they expire as the next year begins
+	return time.Date(purchaseDate.Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+type Never struct{}
+
+func (policy Never) ExpiresAt(purchaseDate time.Time) time.Time {
+	return time.Time{}
+}
+
+func ParsePolicy(spec string) (Policy, error) {
+	// Accepts "never", "end-of-year" or "months:<n>", e.g. "months:12"
+	switch {
+	case spec == "never":
+		return Never{}, nil
+	case spec == "end-of-year":
+		return EndOfCalendarYear{}, nil
+	case strings.HasPrefix(spec, "months:"):
+		months, err := strconv.Atoi(strings.TrimPrefix(spec, "months:"))
+		if err != nil || months <= 0 {
+			return nil, ErrUnknownPolicy
+		}
+		return MonthsAfterPurchase{Months: months}, nil
+	}
+	return nil, ErrUnknownPolicy
+}
+
+func ExpiryForPurchaseDate(policy Policy, purchaseDate string) (time.Time, error) {
+	purchaseTime, err := time.Parse("2006-01-02", purchaseDate)
+	if err != nil {
+		return time.Time{}, errors.New("time.Parse() in ExpiryForPurchaseDate() failed")
+	}
+	return policy.ExpiresAt(purchaseTime), nil
+}
@@ -0,0 +1,78 @@
+package receipt_manager_test
+
+import (
+	ep "receipt_manager/expiry_policy"
+	"testing"
+	"time"
+)
+
+func TestExpiryForPurchaseDate(test *testing.T) {
+	testCases := []struct {
+		policySpec        string
+		purchaseDate      string
+		expectedExpiresAt time.Time
+	}{
+		{
+			policySpec:        "months:12",
+			purchaseDate:      "2022-03-20",
+			expectedExpiresAt: time.Date(2023, time.March, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			policySpec:        "months:6",
+			purchaseDate:      "2022-03-20",
+			expectedExpiresAt: time.Date(2022, time.September, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			policySpec:        "end-of-year",
+			purchaseDate:      "2022-03-20",
+			expectedExpiresAt: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			policySpec:        "end-of-year",
+			purchaseDate:      "2022-12-31",
+			expectedExpiresAt: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			policySpec:        "never",
+			purchaseDate:      "2022-03-20",
+			expectedExpiresAt: time.Time{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		policy, err := ep.ParsePolicy(testCase.policySpec)
+		if err != nil {
+			test.Fatalf("Policy '%s' failed to parse with error: %v", testCase.policySpec, err)
+		}
+
+		expiresAt, err := ep.ExpiryForPurchaseDate(policy, testCase.purchaseDate)
+		if err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+		if !expiresAt.Equal(testCase.expectedExpiresAt) {
+			test.Errorf("Policy '%s' and purchase date '%s', got expiry %s, but expected %s",
+				testCase.policySpec, testCase.purchaseDate, expiresAt, testCase.expectedExpiresAt)
+		}
+	}
+}
+
+func TestParsePolicy(test *testing.T) {
+	testCases := []struct {
+		policySpec  string
+		expectedErr error
+	}{
+		{policySpec: "months:12", expectedErr: nil},
+		{policySpec: "end-of-year", expectedErr: nil},
+		{policySpec: "months:0", expectedErr: ep.ErrUnknownPolicy},
+		{policySpec: "months:twelve", expectedErr: ep.ErrUnknownPolicy},
+		{policySpec: "forever", expectedErr: ep.ErrUnknownPolicy},
+	}
+
+	for _, testCase := range testCases {
+		_, err := ep.ParsePolicy(testCase.policySpec)
+		if err != testCase.expectedErr {
+			test.Errorf("Policy '%s', got error %v, but expected %v",
+				testCase.policySpec, err, testCase.expectedErr)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	receipt_processor "receipt_manager/point_calculator"
+	query_limits "receipt_manager/query_limits"
+	receipt "receipt_manager/receipt"
+	receipt_format "receipt_manager/receipt_format"
+	response_handler "receipt_manager/response_handler"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const defaultPageSize = 20
+const maxPageSize = 100
+
+var graphqlLimits = query_limits.Limits{
+	MaxDepth:      8,
+	MaxComplexity: 5000,
+	// A receipt's items aren't paged, so they count as many as a receipt may have
+	PageSizes: map[string]int{"receipts": defaultPageSize, "items": receiptLimits.MaxItems},
+}
+
+type receiptNode struct {
+	Id      string
+	Receipt receipt.Receipt
+}
+
+type accountNode struct {
+	Id string
+}
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// graphqlError carries the same stable code an HTTP problem response would, under extensions.code
+type graphqlError struct {
+	error
+	code response_handler.ErrorCode
+}
+
+func (err graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": string(err.code)}
+}
+
+func newGraphqlError(err error) error {
+	return graphqlError{error: err, code: problemCode(err)}
+}
+
+var itemType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Item",
+	// item.Item's json tags name the fields
+	Fields: graphql.Fields{
+		"shortDescription": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"price":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"quantity":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"unitPrice":        &graphql.Field{Type: graphql.String},
+		"sku":              &graphql.Field{Type: graphql.String},
+		"upc":              &graphql.Field{Type: graphql.String},
+		"category":         &graphql.Field{Type: graphql.String},
+	},
+})
+
+var rulePointsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "RulePoints",
+	Fields: graphql.Fields{
+		"rule":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"points": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var retailerPointsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "RetailerPoints",
+	Fields: graphql.Fields{
+		"retailerId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"name":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"points":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var itemRulePointsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ItemRulePoints",
+	Fields: graphql.Fields{
+		"ruleId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"name":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"items":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"points": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var campaignPointsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CampaignPoints",
+	Fields: graphql.Fields{
+		"campaignId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"name":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"points":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var conversionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Conversion",
+	Fields: graphql.Fields{
+		"currency": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"rate":     &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"total":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var breakdownType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Breakdown",
+	Fields: graphql.Fields{
+		"rules":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(rulePointsType)))},
+		"basePoints": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"retailer":   &graphql.Field{Type: retailerPointsType},
+		"itemRules":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(itemRulePointsType)))},
+		"campaigns":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(campaignPointsType)))},
+		"points":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"purchasedAt": &graphql.Field{
+			Type:        graphql.String,
+			Description: "The purchase instant in RFC 3339, when the receipt or its retailer has a timezone",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				purchasedAt := params.Source.(receipt_processor.Breakdown).PurchasedAt
+				if purchasedAt == nil {
+					return nil, nil
+				}
+				return purchasedAt.Format(time.RFC3339), nil
+			},
+		},
+		"conversion": &graphql.Field{Type: conversionType},
+	},
+})
+
+var receiptFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ReceiptFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"retailer":      &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "The retailer's name, ignoring case"},
+		"accountId":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"purchasedFrom": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "The earliest purchase date, YYYY-MM-DD"},
+		"purchasedTo":   &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "The latest purchase date, YYYY-MM-DD"},
+		"minPoints":     &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+var itemInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ItemInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"shortDescription": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"price":            &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"quantity":         &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"unitPrice":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"sku":              &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"upc":              &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var receiptInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ReceiptInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"retailer":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"purchaseDate": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"purchaseTime": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"items":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(itemInputType)))},
+		"total":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"accountId":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"timezone":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"currency":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+func newGraphqlSchema() (graphql.Schema, error) {
+	/*
+		Receipts and accounts refer to each other, so their fields are built lazily.
+		Lists take a first argument, capped at maxPageSize, which the complexity
+		limit counts against
+	*/
+	firstArgument := &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize}
+	var receiptType, accountType *graphql.Object
+
+	accountType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Account",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					return params.Source.(accountNode).Id, nil
+				}},
+				"balance": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					balance, accountError := accountStore.Balance(params.Source.(accountNode).Id)
+					if accountError != nil {
+						return nil, newGraphqlError(accountError)
+					}
+					return balance, nil
+				}},
+				"receipts": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(receiptType))),
+					Description: "The receipts credited to the account, oldest first",
+					Args:        graphql.FieldConfigArgument{"first": firstArgument},
+					Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+						credits, accountError := accountStore.Credits(params.Source.(accountNode).Id)
+						if accountError != nil {
+							return nil, newGraphqlError(accountError)
+						}
+						receipts := []receiptNode{}
+						for _, credit := range credits {
+							if len(receipts) == pageSize(params.Args) {
+								break
+							}
+							if creditedReceipt, storeError := receiptStore.Get(credit.ReceiptId); storeError == nil {
+								receipts = append(receipts, receiptNode{Id: credit.ReceiptId, Receipt: creditedReceipt})
+							}
+						}
+						return receipts, nil
+					},
+				},
+			}
+		}),
+	})
+
+	receiptType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Receipt",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":           receiptField(graphql.NewNonNull(graphql.ID), func(node receiptNode) interface{} { return node.Id }),
+				"retailer":     receiptField(graphql.NewNonNull(graphql.String), func(node receiptNode) interface{} { return node.Receipt.Retailer }),
+				"purchaseDate": receiptField(graphql.NewNonNull(graphql.String), func(node receiptNode) interface{} { return node.Receipt.PurchaseDate }),
+				"purchaseTime": receiptField(graphql.NewNonNull(graphql.String), func(node receiptNode) interface{} { return node.Receipt.PurchaseTime }),
+				"total":        receiptField(graphql.NewNonNull(graphql.String), func(node receiptNode) interface{} { return node.Receipt.Total }),
+				"timezone":     receiptField(graphql.String, func(node receiptNode) interface{} { return node.Receipt.Timezone }),
+				"currency":     receiptField(graphql.String, func(node receiptNode) interface{} { return node.Receipt.Currency }),
+				"items": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(itemType))),
+					Args: graphql.FieldConfigArgument{"category": &graphql.ArgumentConfig{Type: graphql.String}},
+					Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+						category, _ := params.Args["category"].(string)
+						items := []interface{}{}
+						for _, receiptItem := range params.Source.(receiptNode).Receipt.Items {
+							if category == "" || receiptItem.Category == category {
+								receiptItem.Quantity = receiptItem.Units()
+								items = append(items, receiptItem)
+							}
+						}
+						return items, nil
+					},
+				},
+				"points": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					node := params.Source.(receiptNode)
+					breakdown, processorError := receiptBreakdown(node.Id, node.Receipt)
+					if processorError != nil {
+						return nil, newGraphqlError(processorError)
+					}
+					return breakdown.Points, nil
+				}},
+				"breakdown": &graphql.Field{Type: graphql.NewNonNull(breakdownType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					node := params.Source.(receiptNode)
+					breakdown, processorError := receiptBreakdown(node.Id, node.Receipt)
+					if processorError != nil {
+						return nil, newGraphqlError(processorError)
+					}
+					return breakdown, nil
+				}},
+				"account": &graphql.Field{Type: accountType, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					accountId := params.Source.(receiptNode).Receipt.AccountId
+					if accountId == "" {
+						return nil, nil
+					}
+					return accountNode{Id: accountId}, nil
+				}},
+			}
+		}),
+	})
+
+	submitResultType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SubmitReceiptResult",
+		Fields: graphql.Fields{
+			"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"created": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "False when returnDuplicate found the receipt already stored"},
+			"receipt": &graphql.Field{Type: graphql.NewNonNull(receiptType)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"receipt": &graphql.Field{
+				Type: receiptType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					id := params.Args["id"].(string)
+					storedReceipt, storeError := receiptStore.Get(id)
+					if storeError != nil {
+						return nil, newGraphqlError(storeError)
+					}
+					return receiptNode{Id: id, Receipt: storedReceipt}, nil
+				},
+			},
+			"receipts": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(receiptType))),
+				Description: "Stored receipts matching every filter given, ordered by id",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: receiptFilterType},
+					"first":  firstArgument,
+				},
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					filter, _ := params.Args["filter"].(map[string]interface{})
+					receipts := []receiptNode{}
+					for _, storedReceipt := range receiptStore.List() {
+						if len(receipts) == pageSize(params.Args) {
+							break
+						}
+						node := receiptNode{Id: storedReceipt.Id, Receipt: storedReceipt.Receipt}
+						if receiptMatches(node, filter) {
+							receipts = append(receipts, node)
+						}
+					}
+					return receipts, nil
+				},
+			},
+			"account": &graphql.Field{
+				Type: accountType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					id := params.Args["id"].(string)
+					if _, accountError := accountStore.Balance(id); accountError != nil {
+						return nil, newGraphqlError(accountError)
+					}
+					return accountNode{Id: id}, nil
+				},
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"submitReceipt": &graphql.Field{
+				Type:        graphql.NewNonNull(submitResultType),
+				Description: "Validates, scores and stores a receipt, as POST /v1/receipts/process does",
+				Args: graphql.FieldConfigArgument{
+					"receipt":         &graphql.ArgumentConfig{Type: graphql.NewNonNull(receiptInputType)},
+					"returnDuplicate": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					// The input's field names are receipt.Receipt's json tags
+					var newReceipt receipt.Receipt
+					inputData, _ := json.Marshal(params.Args["receipt"])
+					json.Unmarshal(inputData, &newReceipt)
+					if limitError := receipt_format.CheckLimits(newReceipt, receiptLimits); limitError != nil {
+						return nil, newGraphqlError(limitError)
+					}
+
+					id, created, submitError := submitReceipt(newReceipt, params.Args["returnDuplicate"].(bool))
+					if submitError != nil {
+						return nil, newGraphqlError(submitError)
+					}
+					storedReceipt, storeError := receiptStore.Get(id)
+					if storeError != nil {
+						return nil, newGraphqlError(storeError)
+					}
+					return map[string]interface{}{
+						"id":      id,
+						"created": created,
+						"receipt": receiptNode{Id: id, Receipt: storedReceipt},
+					}, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+}
+
+func receiptField(fieldType graphql.Output, value func(receiptNode) interface{}) *graphql.Field {
+	return &graphql.Field{Type: fieldType, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		return value(params.Source.(receiptNode)), nil
+	}}
+}
+
+func pageSize(args map[string]interface{}) int {
+	first, _ := args["first"].(int)
+	if first < 1 {
+		return 0
+	}
+	if first > maxPageSize {
+		return maxPageSize
+	}
+	return first
+}
+
+func receiptMatches(node receiptNode, filter map[string]interface{}) bool {
+	if retailer, filtered := filter["retailer"].(string); filtered && !strings.EqualFold(node.Receipt.Retailer, retailer) {
+		return false
+	}
+	if accountId, filtered := filter["accountId"].(string); filtered && node.Receipt.AccountId != accountId {
+		return false
+	}
+	// Purchase dates are YYYY-MM-DD, so they compare as strings
+	if purchasedFrom, filtered := filter["purchasedFrom"].(string); filtered && node.Receipt.PurchaseDate < purchasedFrom {
+		return false
+	}
+	if purchasedTo, filtered := filter["purchasedTo"].(string); filtered && node.Receipt.PurchaseDate > purchasedTo {
+		return false
+	}
+	if minPoints, filtered := filter["minPoints"].(int); filtered {
+		breakdown, processorError := receiptBreakdown(node.Id, node.Receipt)
+		if processorError != nil || breakdown.Points < minPoints {
+			return false
+		}
+	}
+	return true
+}
+
+func graphqlHandler(schema graphql.Schema) http.HandlerFunc {
+	/*
+		Queries are parsed and validated, then measured against graphqlLimits before
+		anything resolves. As the GraphQL over HTTP spec has it, a query that can't
+		run is still a 200 with errors; only a body that isn't a GraphQL request is a 400
+	*/
+	return func(response http.ResponseWriter, request *http.Request) {
+		var graphqlBody graphqlRequest
+		decoderError := json.NewDecoder(request.Body).Decode(&graphqlBody)
+		if decoderError != nil || graphqlBody.Query == "" {
+			response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "The body must be a JSON GraphQL request with a query")
+			return
+		}
+
+		document, parseError := parser.Parse(parser.ParseParams{Source: graphqlBody.Query})
+		if parseError != nil {
+			response_handler.Respond(response, http.StatusOK, &graphql.Result{Errors: gqlerrors.FormatErrors(parseError)})
+			return
+		}
+		validation := graphql.ValidateDocument(&schema, document, nil)
+		if !validation.IsValid {
+			response_handler.Respond(response, http.StatusOK, &graphql.Result{Errors: validation.Errors})
+			return
+		}
+		if limitError := query_limits.Check(document, graphqlBody.OperationName, graphqlBody.Variables, graphqlLimits); limitError != nil {
+			code := "query_too_complex"
+			if errors.Is(limitError, query_limits.ErrQueryTooDeep) {
+				code = "query_too_deep"
+			}
+			limitResult := gqlerrors.FormatError(limitError)
+			limitResult.Extensions = map[string]interface{}{"code": code}
+			response_handler.Respond(response, http.StatusOK, &graphql.Result{Errors: []gqlerrors.FormattedError{limitResult}})
+			return
+		}
+
+		result := graphql.Execute(graphql.ExecuteParams{
+			Schema:        schema,
+			AST:           document,
+			OperationName: graphqlBody.OperationName,
+			Args:          graphqlBody.Variables,
+			Context:       request.Context(),
+		})
+		response_handler.Respond(response, http.StatusOK, result)
+	}
+}
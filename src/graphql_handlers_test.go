@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type graphqlTestResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func postGraphql(test *testing.T, router *mux.Router, query string, variables map[string]interface{}) graphqlTestResponse {
+	body, _ := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	request := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		test.Fatalf("Query '%s', got status %d, but expected 200", query, recorder.Code)
+	}
+
+	var response graphqlTestResponse
+	if decodeError := json.Unmarshal(recorder.Body.Bytes(), &response); decodeError != nil {
+		test.Fatalf("Query '%s', decoding the response failed with error: %v", query, decodeError)
+	}
+	return response
+}
+
+func TestGraphqlQueries(test *testing.T) {
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	submit := `mutation Submit($receipt: ReceiptInput!) {
+		submitReceipt(receipt: $receipt) { id created receipt { points } }
+	}`
+	receipts := []map[string]interface{}{
+		{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.75", "accountId": "customer-42",
+			"items": []interface{}{map[string]interface{}{"shortDescription": "Gatorade 32oz", "price": "2.25", "quantity": 3}}},
+		{"retailer": "Walgreens", "purchaseDate": "2022-03-20", "purchaseTime": "14:33", "total": "9.00",
+			"items": []interface{}{map[string]interface{}{"shortDescription": "Pepsi 12PK", "price": "9.00"}}},
+	}
+	ids := []string{}
+	for _, submitted := range receipts {
+		response := postGraphql(test, router, submit, map[string]interface{}{"receipt": submitted})
+		if len(response.Errors) > 0 {
+			test.Fatalf("Submitting %v failed with errors: %v", submitted["retailer"], response.Errors)
+		}
+		result := response.Data["submitReceipt"].(map[string]interface{})
+		if result["created"] != true {
+			test.Errorf("Submitting %v, got created %v, but expected true", submitted["retailer"], result["created"])
+		}
+		ids = append(ids, result["id"].(string))
+	}
+
+	// Receipts are listed by id
+	firstId := ids[0]
+	if ids[1] < firstId {
+		firstId = ids[1]
+	}
+
+	// The mutation stores receipts where the HTTP API sees them
+	if balance, _ := accountStore.Balance("customer-42"); balance != 42 {
+		test.Errorf("Account balance, got %d, but expected 42", balance)
+	}
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "receipt with items and account",
+			query:    `{ receipt(id: "` + ids[0] + `") { retailer items { shortDescription quantity } breakdown { points basePoints } account { id balance } } }`,
+			expected: `{"receipt":{"account":{"balance":42,"id":"customer-42"},"breakdown":{"basePoints":42,"points":42},"items":[{"quantity":3,"shortDescription":"Gatorade 32oz"}],"retailer":"Target"}}`,
+		},
+		{
+			name:     "filtered by retailer",
+			query:    `{ receipts(filter: {retailer: "walgreens"}) { retailer } }`,
+			expected: `{"receipts":[{"retailer":"Walgreens"}]}`,
+		},
+		{
+			name:     "filtered by purchase date",
+			query:    `{ receipts(filter: {purchasedFrom: "2022-02-01", purchasedTo: "2022-12-31"}) { purchaseDate } }`,
+			expected: `{"receipts":[{"purchaseDate":"2022-03-20"}]}`,
+		},
+		{
+			name:     "filtered by points",
+			query:    `{ receipts(filter: {minPoints: 50}) { retailer } }`,
+			expected: `{"receipts":[{"retailer":"Walgreens"}]}`,
+		},
+		{
+			name:     "first",
+			query:    `{ receipts(first: 1) { id } }`,
+			expected: `{"receipts":[{"id":"` + firstId + `"}]}`,
+		},
+		{
+			name:     "account receipts",
+			query:    `{ account(id: "customer-42") { receipts { retailer } } }`,
+			expected: `{"account":{"receipts":[{"retailer":"Target"}]}}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		response := postGraphql(test, router, testCase.query, nil)
+		if len(response.Errors) > 0 {
+			test.Errorf("Case '%s', got errors %v, but expected none", testCase.name, response.Errors)
+			continue
+		}
+		data, _ := json.Marshal(response.Data)
+		if string(data) != testCase.expected {
+			test.Errorf("Case '%s', got %s, but expected %s", testCase.name, data, testCase.expected)
+		}
+	}
+}
+
+func TestGraphqlErrors(test *testing.T) {
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+	invalidReceipt := map[string]interface{}{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "six",
+		"items": []interface{}{map[string]interface{}{"shortDescription": "Gatorade", "price": "2.25"}}}
+
+	testCases := []struct {
+		name         string
+		query        string
+		variables    map[string]interface{}
+		expectedCode string
+	}{
+		{name: "missing receipt", query: `{ receipt(id: "missing") { id } }`, expectedCode: "receipt_not_found"},
+		{name: "missing account", query: `{ account(id: "nobody") { balance } }`, expectedCode: "account_not_found"},
+		{
+			name:         "invalid receipt",
+			query:        `mutation Submit($receipt: ReceiptInput!) { submitReceipt(receipt: $receipt) { id } }`,
+			variables:    map[string]interface{}{"receipt": invalidReceipt},
+			expectedCode: "invalid_fields",
+		},
+		{name: "too deep", query: `{ receipts(first: 1) { account { receipts(first: 1) { account { receipts(first: 1) { account { receipts(first: 1) { account { id } } } } } } } } }`, expectedCode: "query_too_deep"},
+		{name: "too complex", query: `{ receipts(first: 20) { id items { shortDescription price } } }`, expectedCode: "query_too_complex"},
+		{name: "not in the schema", query: `{ receipts { cashier } }`, expectedCode: ""},
+	}
+
+	for _, testCase := range testCases {
+		response := postGraphql(test, router, testCase.query, testCase.variables)
+		if len(response.Errors) == 0 {
+			test.Errorf("Case '%s', got no errors, but expected %s", testCase.name, testCase.expectedCode)
+			continue
+		}
+		code, _ := response.Errors[0].Extensions["code"].(string)
+		if code != testCase.expectedCode {
+			test.Errorf("Case '%s', got code '%s', but expected '%s'", testCase.name, code, testCase.expectedCode)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	item "receipt_manager/item"
+	receipt_processor "receipt_manager/point_calculator"
+	receipt "receipt_manager/receipt"
+	receipt_format "receipt_manager/receipt_format"
+	receipt_grpc "receipt_manager/receipt_grpc"
+	response_handler "receipt_manager/response_handler"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type receiptService struct {
+	receipt_grpc.UnimplementedReceiptServiceServer
+}
+
+func newGrpcServer() *grpc.Server {
+	// Health and reflection let load balancers and tools like grpcurl use the server without the .proto
+	server := grpc.NewServer()
+	receipt_grpc.RegisterReceiptServiceServer(server, receiptService{})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(receipt_grpc.ReceiptService_ServiceDesc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+func (receiptService) ProcessReceipt(ctx context.Context, request *receipt_grpc.ProcessReceiptRequest) (*receipt_grpc.ProcessReceiptResponse, error) {
+	processed, processError := processReceiptMessage(request)
+	if processError != nil {
+		return nil, processError.Err()
+	}
+	return processed, nil
+}
+
+func (receiptService) ProcessReceipts(stream receipt_grpc.ReceiptService_ProcessReceiptsServer) error {
+	for index := int32(0); ; index++ {
+		request, receiveError := stream.Recv()
+		if receiveError == io.EOF {
+			return nil
+		}
+		if receiveError != nil {
+			return receiveError
+		}
+
+		response := &receipt_grpc.ProcessReceiptsResponse{Index: index}
+		processed, processError := processReceiptMessage(request)
+		if processError != nil {
+			response.Result = &receipt_grpc.ProcessReceiptsResponse_Error{Error: &receipt_grpc.Error{
+				Code:    errorReason(processError),
+				Message: processError.Message(),
+			}}
+		} else {
+			response.Result = &receipt_grpc.ProcessReceiptsResponse_Processed{Processed: processed}
+		}
+		if sendError := stream.Send(response); sendError != nil {
+			return sendError
+		}
+	}
+}
+
+func (receiptService) GetPoints(ctx context.Context, request *receipt_grpc.GetPointsRequest) (*receipt_grpc.GetPointsResponse, error) {
+	breakdown, breakdownError := storedBreakdown(request.GetId())
+	if breakdownError != nil {
+		return nil, breakdownError.Err()
+	}
+	return &receipt_grpc.GetPointsResponse{Points: int32(breakdown.Points)}, nil
+}
+
+func (receiptService) GetBreakdown(ctx context.Context, request *receipt_grpc.GetBreakdownRequest) (*receipt_grpc.Breakdown, error) {
+	breakdown, breakdownError := storedBreakdown(request.GetId())
+	if breakdownError != nil {
+		return nil, breakdownError.Err()
+	}
+	return breakdownToProto(request.GetId(), breakdown), nil
+}
+
+func processReceiptMessage(request *receipt_grpc.ProcessReceiptRequest) (*receipt_grpc.ProcessReceiptResponse, *status.Status) {
+	if request.GetReceipt() == nil {
+		return nil, receiptErrorStatus("", ErrMissingFields)
+	}
+	newReceipt := receiptFromProto(request.GetReceipt())
+	if limitError := receipt_format.CheckLimits(newReceipt, receiptLimits); limitError != nil {
+		return nil, receiptErrorStatus("", limitError)
+	}
+
+	id, created, submitError := submitReceipt(newReceipt, request.GetReturnDuplicate())
+	if submitError != nil {
+		return nil, receiptErrorStatus(id, submitError)
+	}
+	breakdown, breakdownError := storedBreakdown(id)
+	if breakdownError != nil {
+		return nil, breakdownError
+	}
+	return &receipt_grpc.ProcessReceiptResponse{Id: id, Created: created, Points: int32(breakdown.Points)}, nil
+}
+
+func storedBreakdown(id string) (receipt_processor.Breakdown, *status.Status) {
+	storedReceipt, storeError := receiptStore.Get(id)
+	if storeError != nil {
+		return receipt_processor.Breakdown{}, receiptErrorStatus(id, storeError)
+	}
+	breakdown, processorError := receiptBreakdown(id, storedReceipt)
+	if processorError != nil {
+		return receipt_processor.Breakdown{}, receiptErrorStatus(id, processorError)
+	}
+	if !fitsInt32(breakdown) {
+		return receipt_processor.Breakdown{}, receiptErrorStatus(id, ErrPointsOutOfRange)
+	}
+	return breakdown, nil
+}
+
+func fitsInt32(breakdown receipt_processor.Breakdown) bool {
+	// The proto's points are int32, so a breakdown with a larger value is refused rather than wrapped around
+	values := []int{breakdown.BasePoints, breakdown.Points}
+	for _, rule := range breakdown.Rules {
+		values = append(values, rule.Points)
+	}
+	if breakdown.Retailer != nil {
+		values = append(values, breakdown.Retailer.Points)
+	}
+	for _, itemRule := range breakdown.ItemRules {
+		values = append(values, itemRule.Items, itemRule.Points)
+	}
+	for _, campaign := range breakdown.Campaigns {
+		values = append(values, campaign.Points)
+	}
+	for _, value := range values {
+		if value > math.MaxInt32 || value < math.MinInt32 {
+			return false
+		}
+	}
+	return true
+}
+
+var grpcCodes = map[response_handler.ErrorCode]codes.Code{
+	response_handler.ErrorCodeMissingFields:       codes.InvalidArgument,
+	response_handler.ErrorCodeInvalidFields:       codes.InvalidArgument,
+	response_handler.ErrorCodeUnsupportedCurrency: codes.InvalidArgument,
+	response_handler.ErrorCodeInvalidPurchaseDate: codes.InvalidArgument,
+	response_handler.ErrorCodeTooManyItems:        codes.InvalidArgument,
+	response_handler.ErrorCodeDuplicateReceipt:    codes.AlreadyExists,
+	response_handler.ErrorCodeReceiptClaimed:      codes.AlreadyExists,
+	response_handler.ErrorCodeReceiptNotFound:     codes.NotFound,
+	response_handler.ErrorCodeReceiptDeleted:      codes.NotFound,
+	response_handler.ErrorCodePointsOutOfRange:    codes.OutOfRange,
+}
+
+func receiptErrorStatus(id string, receiptError error) *status.Status {
+	/*
+		The status carries the same stable code as the HTTP problem response, as the
+		reason of an ErrorInfo detail, along with the receipt id when there is one
+	*/
+	reason := problemCode(receiptError)
+	code, known := grpcCodes[reason]
+	message := receiptError.Error()
+	if !known {
+		code, message = codes.Internal, "internal error"
+	}
+
+	errorInfo := &errdetails.ErrorInfo{Reason: string(reason), Domain: "receipt_manager"}
+	if id != "" {
+		errorInfo.Metadata = map[string]string{"id": id}
+	}
+	receiptStatus, detailsError := status.New(code, message).WithDetails(errorInfo)
+	if detailsError != nil {
+		return status.New(code, message)
+	}
+	return receiptStatus
+}
+
+func errorReason(receiptStatus *status.Status) string {
+	for _, detail := range receiptStatus.Details() {
+		if errorInfo, isErrorInfo := detail.(*errdetails.ErrorInfo); isErrorInfo {
+			return errorInfo.Reason
+		}
+	}
+	return string(response_handler.ErrorCodeInternalError)
+}
+
+func receiptFromProto(message *receipt_grpc.Receipt) receipt.Receipt {
+	converted := receipt.Receipt{
+		Retailer:     message.GetRetailer(),
+		PurchaseDate: message.GetPurchaseDate(),
+		PurchaseTime: message.GetPurchaseTime(),
+		Items:        []item.Item{},
+		Total:        message.GetTotal(),
+		AccountId:    message.GetAccountId(),
+		Timezone:     message.GetTimezone(),
+		Currency:     message.GetCurrency(),
+	}
+	for _, receiptItem := range message.GetItems() {
+		converted.Items = append(converted.Items, item.Item{
+			ShortDescription: receiptItem.GetShortDescription(),
+			Price:            receiptItem.GetPrice(),
+			Quantity:         int(receiptItem.GetQuantity()),
+			UnitPrice:        receiptItem.GetUnitPrice(),
+			Sku:              receiptItem.GetSku(),
+			Upc:              receiptItem.GetUpc(),
+			Category:         receiptItem.GetCategory(),
+		})
+	}
+	return converted
+}
+
+func breakdownToProto(id string, breakdown receipt_processor.Breakdown) *receipt_grpc.Breakdown {
+	converted := &receipt_grpc.Breakdown{
+		Id:         id,
+		BasePoints: int32(breakdown.BasePoints),
+		Points:     int32(breakdown.Points),
+	}
+	for _, rule := range breakdown.Rules {
+		converted.Rules = append(converted.Rules, &receipt_grpc.RulePoints{Rule: rule.Rule, Points: int32(rule.Points)})
+	}
+	if breakdown.Retailer != nil {
+		converted.Retailer = &receipt_grpc.RetailerPoints{
+			RetailerId: breakdown.Retailer.RetailerId,
+			Name:       breakdown.Retailer.Name,
+			Points:     int32(breakdown.Retailer.Points),
+		}
+	}
+	for _, itemRule := range breakdown.ItemRules {
+		converted.ItemRules = append(converted.ItemRules, &receipt_grpc.ItemRulePoints{
+			RuleId: itemRule.RuleId,
+			Name:   itemRule.Name,
+			Items:  int32(itemRule.Items),
+			Points: int32(itemRule.Points),
+		})
+	}
+	for _, campaign := range breakdown.Campaigns {
+		converted.Campaigns = append(converted.Campaigns, &receipt_grpc.CampaignPoints{
+			CampaignId: campaign.CampaignId,
+			Name:       campaign.Name,
+			Points:     int32(campaign.Points),
+		})
+	}
+	if breakdown.PurchasedAt != nil {
+		converted.PurchasedAt = breakdown.PurchasedAt.Format(time.RFC3339)
+	}
+	if breakdown.Conversion != nil {
+		converted.Conversion = &receipt_grpc.Conversion{
+			Currency: breakdown.Conversion.Currency,
+			Rate:     breakdown.Conversion.Rate,
+			Total:    breakdown.Conversion.Total,
+		}
+	}
+	return converted
+}
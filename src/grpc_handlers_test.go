@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	campaign "receipt_manager/campaign"
+	receipt_grpc "receipt_manager/receipt_grpc"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func grpcTestReceipt(purchaseDate string) *receipt_grpc.Receipt {
+	return &receipt_grpc.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: purchaseDate,
+		PurchaseTime: "13:01",
+		Items:        []*receipt_grpc.Item{{ShortDescription: "Gatorade 32oz", Price: "2.25", Quantity: 3}},
+		Total:        "6.75",
+		AccountId:    "customer-42",
+	}
+}
+
+func dialTestServer(test *testing.T) *grpc.ClientConn {
+	resetStores()
+	listener := bufconn.Listen(1 << 20)
+	server := newGrpcServer()
+	go server.Serve(listener)
+	test.Cleanup(server.Stop)
+
+	connection, dialError := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if dialError != nil {
+		test.Fatalf("Dialing the test server failed with error: %v", dialError)
+	}
+	test.Cleanup(func() { connection.Close() })
+	return connection
+}
+
+func TestGrpcReceiptService(test *testing.T) {
+	ctx := context.Background()
+	client := receipt_grpc.NewReceiptServiceClient(dialTestServer(test))
+
+	processed, processError := client.ProcessReceipt(ctx, &receipt_grpc.ProcessReceiptRequest{Receipt: grpcTestReceipt("2022-01-01")})
+	if processError != nil {
+		test.Fatalf("ProcessReceipt failed with error: %v", processError)
+	}
+	if !processed.Created || processed.Points != 42 {
+		test.Errorf("ProcessReceipt, got created %t and %d points, but expected true and 42", processed.Created, processed.Points)
+	}
+
+	// The HTTP API sees the same store, credited account and breakdown
+	if balance, _ := accountStore.Balance("customer-42"); balance != 42 {
+		test.Errorf("Account balance, got %d, but expected 42", balance)
+	}
+	points, pointsError := client.GetPoints(ctx, &receipt_grpc.GetPointsRequest{Id: processed.Id})
+	if pointsError != nil || points.Points != 42 {
+		test.Errorf("GetPoints, got %v and error %v, but expected 42 points", points, pointsError)
+	}
+	breakdown, breakdownError := client.GetBreakdown(ctx, &receipt_grpc.GetBreakdownRequest{Id: processed.Id})
+	if breakdownError != nil || breakdown.Id != processed.Id || len(breakdown.Rules) == 0 || breakdown.Points != 42 {
+		test.Errorf("GetBreakdown, got %v and error %v", breakdown, breakdownError)
+	}
+
+	testCases := []struct {
+		name           string
+		request        *receipt_grpc.ProcessReceiptRequest
+		expectedCode   codes.Code
+		expectedReason string
+	}{
+		{"duplicate", &receipt_grpc.ProcessReceiptRequest{Receipt: grpcTestReceipt("2022-01-01")}, codes.AlreadyExists, "duplicate_receipt"},
+		{"duplicate returned", &receipt_grpc.ProcessReceiptRequest{Receipt: grpcTestReceipt("2022-01-01"), ReturnDuplicate: true}, codes.OK, ""},
+		{"no receipt", &receipt_grpc.ProcessReceiptRequest{}, codes.InvalidArgument, "missing_fields"},
+		{"invalid date", &receipt_grpc.ProcessReceiptRequest{Receipt: grpcTestReceipt("2022-13-01")}, codes.InvalidArgument, "invalid_purchase_date"},
+		{"invalid total", &receipt_grpc.ProcessReceiptRequest{Receipt: &receipt_grpc.Receipt{
+			Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "six",
+			Items: []*receipt_grpc.Item{{ShortDescription: "Gatorade", Price: "2.25"}},
+		}}, codes.InvalidArgument, "invalid_fields"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := client.ProcessReceipt(ctx, testCase.request)
+		receiptStatus := status.Convert(err)
+		if receiptStatus.Code() != testCase.expectedCode {
+			test.Errorf("Case '%s', got code %s, but expected %s", testCase.name, receiptStatus.Code(), testCase.expectedCode)
+			continue
+		}
+		reason := ""
+		for _, detail := range receiptStatus.Details() {
+			if errorInfo, isErrorInfo := detail.(*errdetails.ErrorInfo); isErrorInfo {
+				reason = errorInfo.Reason
+			}
+		}
+		if reason != testCase.expectedReason {
+			test.Errorf("Case '%s', got reason '%s', but expected '%s'", testCase.name, reason, testCase.expectedReason)
+		}
+	}
+
+	if _, err := client.GetPoints(ctx, &receipt_grpc.GetPointsRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		test.Errorf("GetPoints of a missing receipt, got code %s, but expected NotFound", status.Code(err))
+	}
+}
+
+func TestGrpcProcessReceiptsStream(test *testing.T) {
+	client := receipt_grpc.NewReceiptServiceClient(dialTestServer(test))
+	stream, streamError := client.ProcessReceipts(context.Background())
+	if streamError != nil {
+		test.Fatalf("ProcessReceipts failed with error: %v", streamError)
+	}
+
+	// A failing receipt is answered in its place without ending the stream
+	requests := []*receipt_grpc.ProcessReceiptRequest{
+		{Receipt: grpcTestReceipt("2022-01-01")},
+		{Receipt: &receipt_grpc.Receipt{Retailer: "Target"}},
+		{Receipt: grpcTestReceipt("2022-01-02")},
+	}
+	for _, request := range requests {
+		if sendError := stream.Send(request); sendError != nil {
+			test.Fatalf("Sending a receipt failed with error: %v", sendError)
+		}
+	}
+	stream.CloseSend()
+
+	expectedErrorCodes := []string{"", "missing_fields", ""}
+	for index, expectedErrorCode := range expectedErrorCodes {
+		response, receiveError := stream.Recv()
+		if receiveError != nil {
+			test.Fatalf("Receiving response %d failed with error: %v", index, receiveError)
+		}
+		if response.Index != int32(index) || response.GetError().GetCode() != expectedErrorCode {
+			test.Errorf("Response %d, got index %d and error code '%s', but expected '%s'", index, response.Index, response.GetError().GetCode(), expectedErrorCode)
+		}
+		if expectedErrorCode == "" && response.GetProcessed().GetId() == "" {
+			test.Errorf("Response %d has no receipt id", index)
+		}
+	}
+}
+
+func TestGrpcHealthAndReflection(test *testing.T) {
+	connection := dialTestServer(test)
+	ctx := context.Background()
+
+	health, healthError := grpc_health_v1.NewHealthClient(connection).Check(ctx, &grpc_health_v1.HealthCheckRequest{
+		Service: receipt_grpc.ReceiptService_ServiceDesc.ServiceName,
+	})
+	if healthError != nil || health.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		test.Errorf("Health check, got %v and error %v, but expected SERVING", health, healthError)
+	}
+
+	reflectionStream, reflectionError := grpc_reflection_v1.NewServerReflectionClient(connection).ServerReflectionInfo(ctx)
+	if reflectionError != nil {
+		test.Fatalf("Reflection failed with error: %v", reflectionError)
+	}
+	reflectionStream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{},
+	})
+	reflectionResponse, receiveError := reflectionStream.Recv()
+	if receiveError != nil {
+		test.Fatalf("Listing services failed with error: %v", receiveError)
+	}
+	listed := false
+	for _, service := range reflectionResponse.GetListServicesResponse().GetService() {
+		listed = listed || service.Name == "receipt_manager.v1.ReceiptService"
+	}
+	if !listed {
+		test.Errorf("Reflection doesn't list receipt_manager.v1.ReceiptService")
+	}
+}
+
+func TestGrpcPointsOutOfRange(test *testing.T) {
+	// Points the int32 fields can't carry are refused, with the stored receipt's id, rather than wrapped around
+	ctx := context.Background()
+	client := receipt_grpc.NewReceiptServiceClient(dialTestServer(test))
+	campaignStore.Add(campaign.Campaign{Name: "Jackpot", StartDate: "2022-01-01", EndDate: "2022-01-31", Bonus: math.MaxInt32})
+
+	_, processError := client.ProcessReceipt(ctx, &receipt_grpc.ProcessReceiptRequest{Receipt: grpcTestReceipt("2022-01-01")})
+	receiptStatus := status.Convert(processError)
+	if receiptStatus.Code() != codes.OutOfRange {
+		test.Fatalf("ProcessReceipt, got code %s, but expected OutOfRange", receiptStatus.Code())
+	}
+	id := ""
+	for _, detail := range receiptStatus.Details() {
+		if errorInfo, isErrorInfo := detail.(*errdetails.ErrorInfo); isErrorInfo && errorInfo.Reason == "points_out_of_range" {
+			id = errorInfo.Metadata["id"]
+		}
+	}
+	if id == "" {
+		test.Fatalf("ProcessReceipt, got details %v, but expected points_out_of_range with the receipt id", receiptStatus.Details())
+	}
+
+	if _, err := client.GetPoints(ctx, &receipt_grpc.GetPointsRequest{Id: id}); status.Code(err) != codes.OutOfRange {
+		test.Errorf("GetPoints, got code %s, but expected OutOfRange", status.Code(err))
+	}
+	if _, err := client.GetBreakdown(ctx, &receipt_grpc.GetBreakdownRequest{Id: id}); status.Code(err) != codes.OutOfRange {
+		test.Errorf("GetBreakdown, got code %s, but expected OutOfRange", status.Code(err))
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	idempotency_store "receipt_manager/idempotency_store"
+	response_handler "receipt_manager/response_handler"
+	"regexp"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+var idempotencyKeyPattern = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
+
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	response idempotency_store.Response
+}
+
+func (writer *recordingResponseWriter) WriteHeader(statusCode int) {
+	// The headers the client actually receives are the ones present when the status is written
+	if writer.response.StatusCode == 0 {
+		writer.response.StatusCode = statusCode
+		writer.response.Header = writer.Header().Clone()
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (writer *recordingResponseWriter) Write(body []byte) (int, error) {
+	if writer.response.StatusCode == 0 {
+		writer.WriteHeader(http.StatusOK)
+	}
+	writer.response.Body = append(writer.response.Body, body...)
+	return writer.ResponseWriter.Write(body)
+}
+
+func (writer *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+func idempotentRequests(next http.HandlerFunc) http.HandlerFunc {
+	/*
+		A request with an Idempotency-Key is handled once; retries with the same body
+		replay the first response for as long as the key is kept. Requests without
+		a key are handled as usual
+	*/
+	return func(response http.ResponseWriter, request *http.Request) {
+		key := request.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(response, request)
+			return
+		}
+		if !idempotencyKeyPattern.MatchString(key) {
+			response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidIdempotencyKey,
+				"Idempotency-Key must be 1 to 255 visible ASCII characters")
+			return
+		}
+
+		body, readError := io.ReadAll(request.Body)
+		if readError != nil {
+			handleDecodeError(response, readError)
+			return
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+
+		storedResponse, keyError := idempotencyStore.Begin(key, requestFingerprint(request, body))
+		switch keyError {
+		case idempotency_store.ErrKeyMismatch:
+			response_handler.HandleUnprocessableEntityError(response, response_handler.ErrorCodeIdempotencyKeyReused,
+				"This Idempotency-Key was already used with a different request")
+			return
+		case idempotency_store.ErrKeyInProgress:
+			response_handler.HandleConflictError(response, response_handler.ErrorCodeIdempotencyKeyInProgress,
+				"A request with this Idempotency-Key is still being processed")
+			return
+		}
+		if storedResponse != nil {
+			replayResponse(response, *storedResponse)
+			return
+		}
+
+		// The key is released unless the response is kept, even if the handler panics,
+		// so a retry isn't locked out until the key expires
+		completed := false
+		defer func() {
+			if !completed {
+				idempotencyStore.Release(key)
+			}
+		}()
+
+		recorder := &recordingResponseWriter{ResponseWriter: response}
+		next(recorder, request)
+		if recorder.response.StatusCode >= http.StatusInternalServerError {
+			return
+		}
+		idempotencyStore.Complete(key, recorder.response)
+		completed = true
+	}
+}
+
+func requestFingerprint(request *http.Request, body []byte) string {
+	/*
+		The same key on another endpoint, in another format or with other options is a
+		different request. Accept and Accept-Encoding count too, since the stored body
+		is already encoded for them
+	*/
+	fingerprint := sha256.New()
+	fingerprint.Write([]byte(request.Method + " " + request.URL.RequestURI() + "\n"))
+	fingerprint.Write([]byte(request.Header.Get("Content-Type") + "\n"))
+	fingerprint.Write([]byte(request.Header.Get("Accept") + "\n"))
+	fingerprint.Write([]byte(request.Header.Get("Accept-Encoding") + "\n"))
+	fingerprint.Write(body)
+	return hex.EncodeToString(fingerprint.Sum(nil))
+}
+
+func replayResponse(response http.ResponseWriter, storedResponse idempotency_store.Response) {
+	// The replay keeps this request's own X-Request-Id
+	for name, values := range storedResponse.Header {
+		if name != response_handler.RequestIdHeader {
+			response.Header()[name] = values
+		}
+	}
+	response.Header().Set("Idempotent-Replayed", "true")
+	response.WriteHeader(storedResponse.StatusCode)
+	response.Write(storedResponse.Body)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyKeyReleasedAfterPanic(test *testing.T) {
+	// A handler that panics leaves nothing kept under the key, so the retry is handled afresh
+	resetStores()
+	panicking := true
+	handler := idempotentRequests(func(response http.ResponseWriter, request *http.Request) {
+		if panicking {
+			panic("handler failed")
+		}
+		response.WriteHeader(http.StatusOK)
+	})
+
+	newRequest := func() *http.Request {
+		request := httptest.NewRequest("POST", "/v1/receipts/process", strings.NewReader(`{}`))
+		request.Header.Set(idempotencyKeyHeader, "order-1")
+		return request
+	}
+	func() {
+		defer func() { recover() }()
+		handler(httptest.NewRecorder(), newRequest())
+	}()
+
+	panicking = false
+	recorder := httptest.NewRecorder()
+	handler(recorder, newRequest())
+	if recorder.Code != http.StatusOK {
+		test.Errorf("Retrying after a panic, got status %d, but expected %d", recorder.Code, http.StatusOK)
+	}
+}
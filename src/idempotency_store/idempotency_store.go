@@ -0,0 +1,116 @@
+package receipt_manager
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrKeyMismatch   = errors.New("idempotency key was already used with a different request")
+)
+
+// The response first sent for a key, replayed verbatim to retries
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type entry struct {
+	fingerprint string
+	response    *Response
+	expiresAt   time.Time
+}
+
+type IdempotencyStore struct {
+	mutex   sync.Mutex
+	now     func() time.Time
+	ttl     time.Duration
+	entries map[string]entry
+}
+
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		now:     time.Now,
+		ttl:     ttl,
+		entries: make(map[string]entry),
+	}
+}
+
+func (store *IdempotencyStore) SetClock(now func() time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.now = now
+}
+
+func (store *IdempotencyStore) Begin(key string, fingerprint string) (*Response, error) {
+	/*
+		The first request for a key reserves it and gets no response back. Retries of
+		the same request get the stored response, or ErrKeyInProgress while the first
+		is still being handled; any other request under the key gets ErrKeyMismatch
+	*/
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	store.pruneExpired(now)
+
+	existingEntry, keyUsed := store.entries[key]
+	if !keyUsed {
+		store.entries[key] = entry{fingerprint: fingerprint, expiresAt: now.Add(store.ttl)}
+		return nil, nil
+	}
+	if existingEntry.fingerprint != fingerprint {
+		return nil, ErrKeyMismatch
+	}
+	if existingEntry.response == nil {
+		return nil, ErrKeyInProgress
+	}
+	return existingEntry.response, nil
+}
+
+func (store *IdempotencyStore) Complete(key string, response Response) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// The TTL runs from when the response was stored, not when the key was reserved
+	reservedEntry, keyReserved := store.entries[key]
+	if !keyReserved {
+		return
+	}
+	reservedEntry.response = &response
+	reservedEntry.expiresAt = store.now().Add(store.ttl)
+	store.entries[key] = reservedEntry
+}
+
+func (store *IdempotencyStore) Release(key string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// A request that failed on the server's side frees its key so the client can retry it
+	delete(store.entries, key)
+}
+
+func (store *IdempotencyStore) Forget(matches func(Response) bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Kept responses that match are dropped; keys still in progress are left alone
+	for key, storedEntry := range store.entries {
+		if storedEntry.response != nil && matches(*storedEntry.response) {
+			delete(store.entries, key)
+		}
+	}
+}
+
+func (store *IdempotencyStore) pruneExpired(now time.Time) {
+	for key, storedEntry := range store.entries {
+		if !storedEntry.expiresAt.After(now) {
+			delete(store.entries, key)
+		}
+	}
+}
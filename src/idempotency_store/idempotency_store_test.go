@@ -0,0 +1,54 @@
+package receipt_manager_test
+
+import (
+	"net/http"
+	is "receipt_manager/idempotency_store"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore(test *testing.T) {
+	now := time.Date(2022, time.March, 20, 12, 0, 0, 0, time.UTC)
+	store := is.NewIdempotencyStore(time.Hour)
+	store.SetClock(func() time.Time { return now })
+
+	if response, err := store.Begin("key-1", "body-a"); response != nil || err != nil {
+		test.Fatalf("First request, got %v and error %v, but expected neither", response, err)
+	}
+	if _, err := store.Begin("key-1", "body-a"); err != is.ErrKeyInProgress {
+		test.Errorf("Retry in progress, got error %v, but expected %v", err, is.ErrKeyInProgress)
+	}
+
+	store.Complete("key-1", is.Response{StatusCode: http.StatusOK, Body: []byte(`{"id":"abc"}`)})
+
+	testCases := []struct {
+		key         string
+		fingerprint string
+		expectedErr error
+		expectedHit bool
+	}{
+		{key: "key-1", fingerprint: "body-a", expectedErr: nil, expectedHit: true},
+		{key: "key-1", fingerprint: "body-b", expectedErr: is.ErrKeyMismatch, expectedHit: false},
+		{key: "key-2", fingerprint: "body-b", expectedErr: nil, expectedHit: false},
+	}
+
+	for _, testCase := range testCases {
+		response, err := store.Begin(testCase.key, testCase.fingerprint)
+		if err != testCase.expectedErr {
+			test.Errorf("Key '%s' with '%s', got error %v, but expected %v", testCase.key, testCase.fingerprint, err, testCase.expectedErr)
+		}
+		if (response != nil) != testCase.expectedHit {
+			test.Errorf("Key '%s' with '%s', got response %v, but expected a replay %t", testCase.key, testCase.fingerprint, response, testCase.expectedHit)
+		}
+	}
+
+	// Released and expired keys can be used afresh
+	store.Release("key-2")
+	if response, err := store.Begin("key-2", "body-c"); response != nil || err != nil {
+		test.Errorf("Released key, got %v and error %v, but expected neither", response, err)
+	}
+	now = now.Add(2 * time.Hour)
+	if response, err := store.Begin("key-1", "body-b"); response != nil || err != nil {
+		test.Errorf("Expired key, got %v and error %v, but expected neither", response, err)
+	}
+}
@@ -1,6 +1,19 @@
 package item
 
 type Item struct {
-	ShortDescription  string    `json:"shortDescription"`
-	Price             string    `json:"price"`
+	ShortDescription string `json:"shortDescription"        xml:"shortDescription"`
+	Price            string `json:"price"                   xml:"price"`
+	Quantity         int    `json:"quantity,omitempty"      xml:"quantity,omitempty"`
+	UnitPrice        string `json:"unitPrice,omitempty"     xml:"unitPrice,omitempty"`
+	Sku              string `json:"sku,omitempty"           xml:"sku,omitempty"`
+	Upc              string `json:"upc,omitempty"           xml:"upc,omitempty"`
+	Category         string `json:"category,omitempty"      xml:"category,omitempty"`
+}
+
+// An item without a quantity is a single unit, so older receipts score as before
+func (item Item) Units() int {
+	if item.Quantity < 1 {
+		return 1
+	}
+	return item.Quantity
 }
@@ -1,19 +1,72 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	_ "embed"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	account_store "receipt_manager/account_store"
+	api_spec "receipt_manager/api_spec"
+	campaign_store "receipt_manager/campaign_store"
+	currency "receipt_manager/currency"
+	expiry_policy "receipt_manager/expiry_policy"
+	idempotency_store "receipt_manager/idempotency_store"
+	item "receipt_manager/item"
+	name_policy "receipt_manager/name_policy"
 	receipt_processor "receipt_manager/point_calculator"
+	product_catalog "receipt_manager/product_catalog"
 	receipt "receipt_manager/receipt"
+	receipt_format "receipt_manager/receipt_format"
+	receipt_parser "receipt_manager/receipt_parser"
+	receipt_store "receipt_manager/receipt_store"
 	receipt_validator "receipt_manager/receipt_validator"
 	response_handler "receipt_manager/response_handler"
+	retailer_catalog "receipt_manager/retailer_catalog"
+	webhook "receipt_manager/webhook"
+	webhook_dispatcher "receipt_manager/webhook_dispatcher"
+	webhook_store "receipt_manager/webhook_store"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/gorilla/mux"
 )
 
-var receiptMap = make(map[string]receipt.Receipt)
+var receiptStore = receipt_store.NewReceiptStore()
+var accountStore = account_store.NewAccountStore()
+var campaignStore = campaign_store.NewCampaignStore()
+var retailerCatalog = retailer_catalog.NewCatalog()
+var productCatalog = product_catalog.NewCatalog()
+var expiryPolicy expiry_policy.Policy = expiry_policy.MonthsAfterPurchase{Months: 12}
+var idempotencyStore = idempotency_store.NewIdempotencyStore(24 * time.Hour)
+var webhookStore = webhook_store.NewWebhookStore()
+var webhookDispatcher = webhook_dispatcher.NewDispatcher(webhookStore, webhook.DefaultBackoff)
+var receiptLimits = receipt_format.Limits{MaxBodyBytes: 1 << 20, MaxItems: 500}
+var requestIdPattern = regexp.MustCompile(`^[\w\-.]{1,128}$`)
+var exchangeRates currency.RateProvider = currency.NewStaticRates(currency.ScoringCurrency, nil)
+
+//go:embed api.yml
+var apiSpecData []byte
+
+var (
+	ErrMissingFields       = errors.New("receipt is missing required data fields")
+	ErrInvalidFields       = errors.New("receipt data has invalid field(s)")
+	ErrUnsupportedCurrency = errors.New("no exchange rate for the receipt currency")
+	ErrInvalidPurchaseDate = errors.New("receipt purchase date is not a calendar date")
+	ErrReceiptClaimed      = errors.New("receipt was already submitted for another account")
+	ErrAccountChanged      = errors.New("a receipt's account can't be changed")
+	ErrPointsOutOfRange    = errors.New("receipt points don't fit in 32 bits")
+)
 
 func idGenerator(receipt receipt.Receipt) string {
 	receiptData := ""
@@ -23,8 +76,18 @@ func idGenerator(receipt receipt.Receipt) string {
 	for _, item := range receipt.Items {
 		receiptData += item.ShortDescription
 		receiptData += item.Price
+		// Only hashed when present, so receipts without quantities keep their ids
+		if item.Quantity != 0 || item.UnitPrice != "" {
+			receiptData += strconv.Itoa(item.Quantity) + "@" + item.UnitPrice
+		}
 	}
 	receiptData += receipt.Total
+	if receipt.Timezone != "" {
+		receiptData += receipt.Timezone
+	}
+	if receipt.Currency != "" {
+		receiptData += receipt.Currency
+	}
 
 	idHash := sha256.New()
 	idHash.Write([]byte(receiptData))
@@ -32,70 +95,772 @@ func idGenerator(receipt receipt.Receipt) string {
 	return hex.EncodeToString(idHash.Sum(nil))
 }
 
+func validateReceipt(receipt receipt.Receipt) error {
+	receiptMissingFields := receipt_validator.ReceiptMissingFields(receipt)
+	if receiptMissingFields {
+		return ErrMissingFields
+	}
+
+	receiptValid := receipt_validator.ReceiptFieldsValid(receipt)
+	if !receiptValid {
+		return ErrInvalidFields
+	}
+
+	if receipt.Currency != "" && receipt.Currency != currency.ScoringCurrency {
+		if _, rateError := exchangeRates.Rate(receipt.Currency, currency.ScoringCurrency); rateError != nil {
+			return ErrUnsupportedCurrency
+		}
+	}
+	return nil
+}
+
+func receiptIsValid(response http.ResponseWriter, receipt receipt.Receipt) bool {
+	validationError := validateReceipt(receipt)
+	if validationError != nil {
+		handleReceiptError(response, "", validationError)
+		return false
+	}
+	return true
+}
+
+func problemCode(receiptError error) response_handler.ErrorCode {
+	// The stable code for an error from submitting or looking up a receipt, for transports other than HTTP
+	switch {
+	case errors.Is(receiptError, ErrMissingFields):
+		return response_handler.ErrorCodeMissingFields
+	case errors.Is(receiptError, ErrInvalidFields):
+		return response_handler.ErrorCodeInvalidFields
+	case errors.Is(receiptError, ErrUnsupportedCurrency):
+		return response_handler.ErrorCodeUnsupportedCurrency
+	case errors.Is(receiptError, ErrInvalidPurchaseDate):
+		return response_handler.ErrorCodeInvalidPurchaseDate
+	case errors.Is(receiptError, receipt_format.ErrTooManyItems):
+		return response_handler.ErrorCodeTooManyItems
+	case errors.Is(receiptError, receipt_store.ErrReceiptExists):
+		return response_handler.ErrorCodeDuplicateReceipt
+	case errors.Is(receiptError, ErrReceiptClaimed):
+		return response_handler.ErrorCodeReceiptClaimed
+	case errors.Is(receiptError, receipt_store.ErrReceiptNotFound):
+		return response_handler.ErrorCodeReceiptNotFound
+	case errors.Is(receiptError, receipt_store.ErrReceiptDeleted):
+		return response_handler.ErrorCodeReceiptDeleted
+	case errors.Is(receiptError, account_store.ErrAccountNotFound):
+		return response_handler.ErrorCodeAccountNotFound
+	case errors.Is(receiptError, ErrPointsOutOfRange):
+		return response_handler.ErrorCodePointsOutOfRange
+	}
+	return response_handler.ErrorCodeInternalError
+}
+
+func handleReceiptError(response http.ResponseWriter, id string, receiptError error) {
+	switch receiptError {
+	case ErrMissingFields:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeMissingFields, "Receipt is missing required data fields")
+	case ErrInvalidFields:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidFields, "Receipt data has invalid field(s)")
+	case ErrUnsupportedCurrency:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeUnsupportedCurrency, "No exchange rate for the receipt currency")
+	case ErrInvalidPurchaseDate:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidPurchaseDate, "Receipt purchase date is not a calendar date")
+	case receipt_store.ErrReceiptExists:
+		response_handler.HandleDuplicateReceipt(response, id, "Receipt already exists")
+	case ErrReceiptClaimed:
+		response_handler.HandleConflictError(response, response_handler.ErrorCodeReceiptClaimed, "Receipt was already submitted for another account")
+	case ErrAccountChanged:
+		response_handler.HandleConflictError(response, response_handler.ErrorCodeAccountChanged, "A receipt's account can't be changed")
+	default:
+		response_handler.HandleInternalServerError(response)
+	}
+}
+
 func newReceiptHandler(response http.ResponseWriter, request *http.Request) {
+	id, _, processed := processReceipt(response, request, false)
+	if processed {
+		response_handler.SendIdResponse(id, response)
+	}
+}
+
+func newReceiptV2Handler(response http.ResponseWriter, request *http.Request) {
+	// v2 answers with what the receipt earned, and the account balance it now counts toward
+	id, created, processed := processReceipt(response, request, true)
+	if !processed {
+		return
+	}
+
+	storedReceipt, storeError := receiptStore.Get(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
+		return
+	}
+	breakdown, processorError := receiptBreakdown(id, storedReceipt)
+	if processorError != nil {
+		response_handler.HandleInternalServerError(response)
+		return
+	}
+
+	var account *response_handler.BalanceResponse
+	if storedReceipt.AccountId != "" {
+		balance, accountError := accountStore.Balance(storedReceipt.AccountId)
+		if accountError == nil {
+			account = &response_handler.BalanceResponse{AccountId: storedReceipt.AccountId, Balance: balance}
+		}
+	}
+	response_handler.SendProcessedReceiptResponse(id, breakdown, account, created, response)
+}
+
+func processReceipt(response http.ResponseWriter, request *http.Request, strictMediaType bool) (string, bool, bool) {
+	/*
+		Stores and credits a submitted receipt, returning its id, whether it was stored
+		by this request, and whether the caller still has to respond. Errors and
+		duplicates that aren't returned are answered here. v1 ignored the Content-Type
+		before formats were negotiated, so unless strictMediaType is set, a type that
+		isn't JSON, XML or CSV is still read as JSON rather than answered with 415
+	*/
 	if request.Method != http.MethodPost {
 		response_handler.HandleMethodNotAllowed(response)
-		return 
+		return "", false, false
 	}
 
-	newReceipt := receipt.Receipt{}
-
-	decoderError := json.NewDecoder(request.Body).Decode(&newReceipt)
+	contentType := request.Header.Get("Content-Type")
+	if !strictMediaType && !receipt_format.Supported(contentType) {
+		contentType = ""
+	}
+	newReceipt, decoderError := receipt_format.DecodeReceipt(contentType, request.Body, receiptLimits)
 	if decoderError != nil {
-		response_handler.HandleBadRequestError(response, "Receipt data decoding failed")
-		return
+		handleDecodeError(response, decoderError)
+		return "", false, false
 	}
 
-	receiptMissingFields := receipt_validator.ReceiptMissingFields(newReceipt)
-	if receiptMissingFields {
-		response_handler.HandleBadRequestError(response, "Receipt is missing required data fields")
+	returnDuplicate := request.URL.Query().Get("onDuplicate") == "return"
+	id, created, submitError := submitReceipt(newReceipt, returnDuplicate)
+	if id != "" {
+		response.Header().Set("Location", strings.TrimSuffix(api_spec.ServerURL(request), "/")+"/receipts/"+id)
+	}
+	if submitError != nil {
+		handleReceiptError(response, id, submitError)
+		return "", false, false
+	}
+	return id, created, true
+}
+
+func submitReceipt(newReceipt receipt.Receipt, returnDuplicate bool) (string, bool, error) {
+	/*
+		Validates, scores, stores and credits a decoded receipt for any transport,
+		returning its id and whether it was stored by this call. A duplicate returns
+		the existing id, with ErrReceiptExists unless returnDuplicate is set
+	*/
+	newReceipt = normalizeNames(newReceipt)
+	if validationError := validateReceipt(newReceipt); validationError != nil {
+		return "", false, validationError
+	}
+	// The id hashes the retailer as submitted, so catalog edits can't change it or defeat dedup
+	id := idGenerator(newReceipt)
+	newReceipt = categorizeItems(newReceipt)
+
+	breakdown, scoringContext, processorError := scoreReceipt(newReceipt)
+	if processorError != nil {
+		return "", false, processorError
+	}
+
+	expiresAt, expiryError := receiptExpiry(newReceipt)
+	if expiryError != nil {
+		return "", false, expiryError
+	}
+
+	/*
+		A duplicate is a conflict with the stored receipt, unless the client opted into
+		idempotent resubmission, in which case it gets the same answer as the first time.
+		Either way nothing is credited twice
+	*/
+	storeError := receiptStore.Add(id, newReceipt)
+	if storeError == receipt_store.ErrReceiptExists {
+		// Points for a receipt go to one account only, and its id isn't revealed to any other
+		storedReceipt, _ := receiptStore.Get(id)
+		if storedReceipt.AccountId != newReceipt.AccountId {
+			return "", false, ErrReceiptClaimed
+		}
+	}
+	if storeError == receipt_store.ErrReceiptExists && returnDuplicate {
+		return id, false, nil
+	}
+	if storeError != nil {
+		return id, false, storeError
+	}
+	receiptStore.CacheBreakdown(id, breakdown, scoringContext)
+	retailerCatalog.RecordUnknown(newReceipt.Retailer)
+
+	if newReceipt.AccountId != "" {
+		accountStore.PostReceipt(newReceipt.AccountId, id, breakdown.Points, expiresAt)
+	}
+	publishReceiptScored(id, newReceipt, breakdown)
+
+	return id, true, nil
+}
+
+func getPointsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
 		return
 	}
 
-	receiptValid := receipt_validator.ReceiptFieldsValid(newReceipt)
-	if !receiptValid {
-		response_handler.HandleBadRequestError(response, "Receipt data has invalid field(s)")
+	id := mux.Vars(request)["id"]
+	receipt, storeError := receiptStore.Get(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
 		return
 	}
 
-	id := idGenerator(newReceipt)
-	_, receiptExists := receiptMap[id]
-	if receiptExists {
-		response_handler.HandleDuplicateReceipt(response, "Receipt already exists")
+	breakdown, processorError := receiptBreakdown(id, receipt)
+	if processorError != nil {
+		response_handler.HandleInternalServerError(response)
 		return
 	}
 
-	receiptMap[id] = newReceipt
-	response_handler.SendIdResponse(id, response)
+	response_handler.SendPointsResponse(breakdown.Points, response)
 }
 
-func getPointsHandler(response http.ResponseWriter, request *http.Request) {
+func getBreakdownHandler(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
 		response_handler.HandleMethodNotAllowed(response)
-		return 
+		return
 	}
 
 	id := mux.Vars(request)["id"]
-	receipt, receiptExists := receiptMap[id]
-	if !receiptExists {
-		response_handler.HandleNotFoundError(response, "The requested receipt doesn't exist")
+	receipt, storeError := receiptStore.Get(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
 		return
 	}
 
-	points, processorError := receipt_processor.ProcessReceipt(receipt)
+	breakdown, processorError := receiptBreakdown(id, receipt)
 	if processorError != nil {
 		response_handler.HandleInternalServerError(response)
 		return
 	}
 
-	response_handler.SendPointsResponse(points, response)
+	response_handler.SendBreakdownResponse(id, breakdown, response)
 }
 
-func main() {
+func normalizeNames(receipt receipt.Receipt) receipt.Receipt {
+	// Names are validated, hashed and stored in NFC, so differently composed accents can't split a receipt
+	receipt.Retailer = name_policy.Normalize(receipt.Retailer)
+	normalizedItems := []item.Item{}
+	for _, receiptItem := range receipt.Items {
+		receiptItem.ShortDescription = name_policy.Normalize(receiptItem.ShortDescription)
+		normalizedItems = append(normalizedItems, receiptItem)
+	}
+	receipt.Items = normalizedItems
+	return receipt
+}
+
+func categorizeItems(receipt receipt.Receipt) receipt.Receipt {
+	categorizedItems := []item.Item{}
+	for _, receiptItem := range receipt.Items {
+		categorizedItems = append(categorizedItems, productCatalog.Categorize(receiptItem))
+	}
+	receipt.Items = categorizedItems
+	return receipt
+}
+
+func scoreReceipt(receipt receipt.Receipt) (receipt_processor.Breakdown, receipt_processor.ScoringContext, error) {
+	/*
+		Every receipt is scored here, whether it's new, amended or missing from the cache,
+		against the current campaigns, catalog and rates. The context is returned with the
+		breakdown so the receipt can later be rescored exactly as it was
+	*/
+	context := receipt_processor.ScoringContext{
+		Campaigns: campaignStore.List(),
+		ItemRules: productCatalog.Rules(),
+		Rates:     exchangeRates,
+	}
+	retailer, retailerKnown := retailerCatalog.Lookup(receipt.Retailer)
+	if retailerKnown {
+		context.Retailer = &retailer
+	}
+	breakdown, processorError := rescoreReceipt(receipt, context)
+	return breakdown, context, processorError
+}
+
+func rescoreReceipt(receipt receipt.Receipt, context receipt_processor.ScoringContext) (receipt_processor.Breakdown, error) {
+	// Aliases score as their catalog retailer; receipts keep the name they were submitted with
+	if context.Retailer != nil {
+		receipt.Retailer = context.Retailer.CanonicalName
+	}
+	return receipt_processor.BreakdownReceipt(receipt, context)
+}
+
+func receiptBreakdown(id string, receipt receipt.Receipt) (receipt_processor.Breakdown, error) {
+	// Points are fixed when a receipt is scored, so later campaign changes don't alter them
+	breakdown, breakdownCached := receiptStore.CachedBreakdown(id)
+	if breakdownCached {
+		return breakdown, nil
+	}
+
+	breakdown, scoringContext, processorError := scoreReceipt(receipt)
+	if processorError != nil {
+		return receipt_processor.Breakdown{}, processorError
+	}
+	receiptStore.CacheBreakdown(id, breakdown, scoringContext)
+	return breakdown, nil
+}
+
+func receiptHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		getReceipt(response, request)
+	case http.MethodPut, http.MethodPatch:
+		amendReceipt(response, request)
+	case http.MethodDelete:
+		deleteReceipt(response, request)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func getReceipt(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	storedReceipt, storeError := receiptStore.Get(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
+		return
+	}
+
+	response_handler.SendReceiptResponse(id, storedReceipt, response)
+}
+
+func receiptExpiry(creditedReceipt receipt.Receipt) (time.Time, error) {
+	// Only points credited to an account expire, so only those receipts need a calendar purchase date
+	if creditedReceipt.AccountId == "" {
+		return time.Time{}, nil
+	}
+	expiresAt, expiryError := expiry_policy.ExpiryForPurchaseDate(expiryPolicy, creditedReceipt.PurchaseDate)
+	if expiryError != nil {
+		return time.Time{}, ErrInvalidPurchaseDate
+	}
+	return expiresAt, nil
+}
+
+func amendReceipt(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	storedReceipt, storeError := receiptStore.Get(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
+		return
+	}
+	amendedReceipt := storedReceipt
+
+	// PUT replaces the receipt outright; PATCH decodes over the current revision,
+	// which must not share its items with the stored one
+	if request.Method == http.MethodPut {
+		amendedReceipt = receipt.Receipt{}
+	} else {
+		amendedReceipt.Items = append([]item.Item(nil), amendedReceipt.Items...)
+	}
+
+	decoderError := receipt_format.DecodeJSON(request.Body, &amendedReceipt)
+	if decoderError == nil {
+		decoderError = receipt_format.CheckLimits(amendedReceipt, receiptLimits)
+	}
+	if decoderError != nil {
+		handleDecodeError(response, decoderError)
+		return
+	}
+
+	amendedReceipt = normalizeNames(amendedReceipt)
+	if !receiptIsValid(response, amendedReceipt) {
+		return
+	}
+	// The id is global, so moving a receipt to another account would hand it that account's claim
+	if amendedReceipt.AccountId != storedReceipt.AccountId {
+		handleReceiptError(response, id, ErrAccountChanged)
+		return
+	}
+	amendedReceipt = categorizeItems(amendedReceipt)
+
+	breakdown, scoringContext, processorError := scoreReceipt(amendedReceipt)
+	if processorError != nil {
+		response_handler.HandleInternalServerError(response)
+		return
+	}
+
+	expiresAt, expiryError := receiptExpiry(amendedReceipt)
+	if expiryError != nil {
+		handleReceiptError(response, id, expiryError)
+		return
+	}
+
+	// The ledger moves first, since it refuses changes that would overdraw an account
+	accountError := accountStore.PostReceipt(amendedReceipt.AccountId, id, breakdown.Points, expiresAt)
+	if accountError != nil {
+		handleAccountError(response, accountError)
+		return
+	}
+
+	revision, storeError := receiptStore.Amend(id, amendedReceipt)
+	if storeError != nil {
+		// The receipt was deleted since it was read, so the credit just posted is taken back
+		accountStore.ReverseReceipt(id)
+		handleStoreError(response, storeError)
+		return
+	}
+	receiptStore.CacheBreakdown(id, breakdown, scoringContext)
+	publishReceiptScored(id, amendedReceipt, breakdown)
+
+	response_handler.SendRevisionResponse(id, revision.Number, breakdown.Points, response)
+}
+
+func deleteReceipt(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	storedReceipt, storeError := receiptStore.Get(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
+		return
+	}
+
+	// The receipt is tombstoned before its points are reversed, so an amendment
+	// racing the deletion can't credit it again afterwards. Deletion always
+	// succeeds; points the account already spent are written off
+	storeError = receiptStore.Delete(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
+		return
+	}
+	accountStore.ReverseReceipt(id)
+	publishReceiptDeleted(id, storedReceipt.AccountId)
+
+	response_handler.SendNoContentResponse(response)
+}
+
+type parseRequest struct {
+	Text string `json:"text"`
+}
+
+func parseReceiptHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	// OCR output is accepted as plain text or as JSON {"text": "..."}
+	body, readError := io.ReadAll(request.Body)
+	if readError != nil {
+		handleDecodeError(response, readError)
+		return
+	}
+	text := string(body)
+	if !strings.HasPrefix(request.Header.Get("Content-Type"), "text/plain") {
+		textRequest := parseRequest{}
+		if decoderError := json.Unmarshal(body, &textRequest); decoderError != nil {
+			response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Receipt text decoding failed")
+			return
+		}
+		text = textRequest.Text
+	}
+
+	// The draft is returned for the user to correct and submit; nothing is stored
+	draft := receipt_parser.Parse(text)
+	draftReceipt := normalizeNames(draft.Receipt)
+	draftValid := !receipt_validator.ReceiptMissingFields(draftReceipt) && receipt_validator.ReceiptFieldsValid(draftReceipt)
+	response_handler.SendDraftResponse(draft, draftValid, response)
+}
+
+func getRevisionsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	id := mux.Vars(request)["id"]
+	revisions, storeError := receiptStore.Revisions(id)
+	if storeError != nil {
+		handleStoreError(response, storeError)
+		return
+	}
+
+	response_handler.SendRevisionsResponse(id, revisions, response)
+}
+
+func handleDecodeError(response http.ResponseWriter, decoderError error) {
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.Is(decoderError, receipt_format.ErrUnsupportedMediaType):
+		response_handler.HandleUnsupportedMediaTypeError(response, "Receipts must be JSON, XML or CSV")
+	case errors.Is(decoderError, receipt_format.ErrBodyTooLarge), errors.As(decoderError, &maxBytesError):
+		response_handler.HandlePayloadTooLargeError(response,
+			fmt.Sprintf("Request body exceeds %d bytes", receiptLimits.MaxBodyBytes))
+	case errors.Is(decoderError, receipt_format.ErrUnknownField):
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeUnknownField,
+			"Receipt data decoding failed: "+decoderError.Error())
+	case errors.Is(decoderError, receipt_format.ErrWrongFieldType):
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeWrongFieldType,
+			"Receipt data decoding failed: "+decoderError.Error())
+	case errors.Is(decoderError, receipt_format.ErrTrailingData):
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeTrailingData,
+			"Receipt data decoding failed: "+decoderError.Error())
+	case errors.Is(decoderError, receipt_format.ErrTooManyItems):
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeTooManyItems,
+			"Receipt data decoding failed: "+decoderError.Error())
+	default:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Receipt data decoding failed")
+	}
+}
+
+func routeNotFoundHandler(response http.ResponseWriter, request *http.Request) {
+	response_handler.HandleRouteNotFound(response)
+}
+
+func assignRequestIds(next http.Handler) http.Handler {
+	// Clients may supply their own request id for tracing; anything unusable is replaced
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		requestId := request.Header.Get(response_handler.RequestIdHeader)
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		response.Header().Set(response_handler.RequestIdHeader, requestId)
+		next.ServeHTTP(response, request)
+	})
+}
+
+func newRequestId() string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
+
+func limitRequestBodies(next http.Handler) http.Handler {
+	// Every body is capped, so no endpoint can be made to buffer an arbitrarily large request
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		request.Body = http.MaxBytesReader(response, request.Body, receiptLimits.MaxBodyBytes)
+		next.ServeHTTP(response, request)
+	})
+}
+
+func handleStoreError(response http.ResponseWriter, storeError error) {
+	switch storeError {
+	case receipt_store.ErrReceiptNotFound:
+		response_handler.HandleNotFoundError(response, response_handler.ErrorCodeReceiptNotFound, "The requested receipt doesn't exist")
+	case receipt_store.ErrReceiptDeleted:
+		response_handler.HandleGoneError(response, response_handler.ErrorCodeReceiptDeleted, "The requested receipt has been deleted")
+	default:
+		response_handler.HandleInternalServerError(response)
+	}
+}
+
+func expirePointsPeriodically(interval time.Duration) {
+	for range time.Tick(interval) {
+		expiryEntries := accountStore.ExpirePoints()
+		if len(expiryEntries) > 0 {
+			log.Printf("Expired points on %d receipt credit(s)", len(expiryEntries))
+		}
+	}
+}
+
+func newRouter() (*mux.Router, error) {
+	/*
+		Routes come from api.yml: each operationId there is served by the handler
+		listed here, and startup fails if the two lists don't match
+	*/
+	spec, specError := api_spec.Load(apiSpecData)
+	if specError != nil {
+		return nil, specError
+	}
+
+	specJSONHandler, specJSONError := api_spec.JSONHandler(spec)
+	if specJSONError != nil {
+		return nil, specJSONError
+	}
+
+	graphqlSchema, schemaError := newGraphqlSchema()
+	if schemaError != nil {
+		return nil, schemaError
+	}
+
 	router := mux.NewRouter()
-	router.HandleFunc("/receipts/process", newReceiptHandler)
-	router.HandleFunc("/receipts/{id}/points", getPointsHandler)
-	
+	router.Use(assignRequestIds, limitRequestBodies, response_handler.NegotiateResponses)
+	router.NotFoundHandler = assignRequestIds(http.HandlerFunc(routeNotFoundHandler))
+	/*
+		v1 keeps the original behavior and v2 carries the richer model: typed
+		errors, breakdowns and accounts. The unversioned paths are deprecated
+		aliases of v1, per the servers in api.yml
+	*/
+	v1Handlers := api_spec.Handlers{
+		"processReceipt":       idempotentRequests(newReceiptHandler),
+		"parseReceipt":         parseReceiptHandler,
+		"getReceiptPoints":     getPointsHandler,
+		"getReceiptRevisions":  getRevisionsHandler,
+		"getReceipt":           receiptHandler,
+		"replaceReceipt":       receiptHandler,
+		"amendReceipt":         receiptHandler,
+		"deleteReceipt":        receiptHandler,
+		"listCampaigns":        campaignsHandler,
+		"createCampaign":       campaignsHandler,
+		"getCampaign":          campaignHandler,
+		"deleteCampaign":       campaignHandler,
+		"listRetailers":        retailersHandler,
+		"createRetailer":       retailersHandler,
+		"listUnknownRetailers": getUnknownRetailersHandler,
+		"getRetailer":          retailerHandler,
+		"replaceRetailer":      retailerHandler,
+		"deleteRetailer":       retailerHandler,
+		"listProducts":         productsHandler,
+		"createProduct":        productsHandler,
+		"getProduct":           productHandler,
+		"deleteProduct":        productHandler,
+		"listItemRules":        itemRulesHandler,
+		"createItemRule":       itemRulesHandler,
+		"getItemRule":          itemRuleHandler,
+		"deleteItemRule":       itemRuleHandler,
+		"listWebhooks":         webhooksHandler,
+		"createWebhook":        webhooksHandler,
+		"getWebhook":           webhookHandler,
+		"deleteWebhook":        webhookHandler,
+		"getWebhookDeliveries": getWebhookDeliveriesHandler,
+		"listDeadLetters":      getDeadLettersHandler,
+	}
+	v2Handlers := api_spec.Handlers{
+		"processReceipt":        idempotentRequests(newReceiptV2Handler),
+		"getReceiptBreakdown":   getBreakdownHandler,
+		"getAccountBalance":     getBalanceHandler,
+		"getAccountReceipts":    getAccountReceiptsHandler,
+		"getAccountLedger":      getLedgerHandler,
+		"redeemPoints":          redemptionHandler,
+		"reconcileAccount":      reconciliationHandler,
+		"getAccountExpirations": getExpirationsHandler,
+		"eraseAccount":          eraseAccountHandler,
+	}
+	rootHandlers := api_spec.Handlers{
+		"getSpecYaml":  api_spec.YAMLHandler(apiSpecData),
+		"getSpecJson":  specJSONHandler,
+		"getDocs":      api_spec.DocsHandler(spec, router),
+		"graphqlQuery": graphqlHandler(graphqlSchema),
+	}
+	for operationId, handler := range v1Handlers {
+		if v2Handlers[operationId] == nil {
+			v2Handlers[operationId] = handler
+		}
+		rootHandlers[operationId] = handler
+	}
+	routeError := api_spec.Route(router, spec, api_spec.Servers{"/v1": v1Handlers, "/v2": v2Handlers, "/": rootHandlers})
+	return router, routeError
+}
+
+func main() {
+	// POINTS_EXPIRY_POLICY is "never", "end-of-year" or "months:<n>"
+	policySpec := os.Getenv("POINTS_EXPIRY_POLICY")
+	if policySpec != "" {
+		policy, policyError := expiry_policy.ParsePolicy(policySpec)
+		if policyError != nil {
+			log.Fatalf("Invalid POINTS_EXPIRY_POLICY %q: %v", policySpec, policyError)
+		}
+		expiryPolicy = policy
+	}
+
+	catalogPath := os.Getenv("RETAILER_CATALOG_FILE")
+	if catalogPath != "" {
+		catalog, catalogError := retailer_catalog.LoadCatalogFile(catalogPath)
+		if catalogError != nil {
+			log.Fatalf("Loading retailer catalog %q failed: %v", catalogPath, catalogError)
+		}
+		retailerCatalog = catalog
+	}
+
+	// NAME_POLICY_FILE overrides which characters and lengths retailer names and item descriptions allow
+	namePolicyPath := os.Getenv("NAME_POLICY_FILE")
+	if namePolicyPath != "" {
+		policies, policyError := name_policy.LoadPoliciesFile(namePolicyPath)
+		if policyError != nil {
+			log.Fatalf("Loading name policy %q failed: %v", namePolicyPath, policyError)
+		}
+		receipt_validator.NamePolicies = policies
+	}
+
+	// MAX_BODY_BYTES and MAX_RECEIPT_ITEMS bound how much a single request can submit
+	if maxBodyBytes := os.Getenv("MAX_BODY_BYTES"); maxBodyBytes != "" {
+		limit, limitError := strconv.ParseInt(maxBodyBytes, 10, 64)
+		if limitError != nil || limit <= 0 {
+			log.Fatalf("Invalid MAX_BODY_BYTES %q", maxBodyBytes)
+		}
+		receiptLimits.MaxBodyBytes = limit
+	}
+	if maxItems := os.Getenv("MAX_RECEIPT_ITEMS"); maxItems != "" {
+		limit, limitError := strconv.Atoi(maxItems)
+		if limitError != nil || limit <= 0 {
+			log.Fatalf("Invalid MAX_RECEIPT_ITEMS %q", maxItems)
+		}
+		receiptLimits.MaxItems = limit
+		graphqlLimits.PageSizes["items"] = limit
+	}
+
+	// GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY bound how much a single GraphQL query can resolve
+	if maxDepth := os.Getenv("GRAPHQL_MAX_DEPTH"); maxDepth != "" {
+		limit, limitError := strconv.Atoi(maxDepth)
+		if limitError != nil || limit <= 0 {
+			log.Fatalf("Invalid GRAPHQL_MAX_DEPTH %q", maxDepth)
+		}
+		graphqlLimits.MaxDepth = limit
+	}
+	if maxComplexity := os.Getenv("GRAPHQL_MAX_COMPLEXITY"); maxComplexity != "" {
+		limit, limitError := strconv.Atoi(maxComplexity)
+		if limitError != nil || limit <= 0 {
+			log.Fatalf("Invalid GRAPHQL_MAX_COMPLEXITY %q", maxComplexity)
+		}
+		graphqlLimits.MaxComplexity = limit
+	}
+
+	// IDEMPOTENCY_KEY_TTL is how long responses are kept for retries, e.g. "24h"
+	if idempotencyTTL := os.Getenv("IDEMPOTENCY_KEY_TTL"); idempotencyTTL != "" {
+		ttl, ttlError := time.ParseDuration(idempotencyTTL)
+		if ttlError != nil || ttl <= 0 {
+			log.Fatalf("Invalid IDEMPOTENCY_KEY_TTL %q", idempotencyTTL)
+		}
+		idempotencyStore = idempotency_store.NewIdempotencyStore(ttl)
+	}
+
+	// WEBHOOK_MAX_ATTEMPTS and WEBHOOK_RETRY_DELAY set how often, and how soon, a failed delivery is retried
+	backoff := webhook.DefaultBackoff
+	if maxAttempts := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); maxAttempts != "" {
+		attempts, attemptsError := strconv.Atoi(maxAttempts)
+		if attemptsError != nil || attempts <= 0 {
+			log.Fatalf("Invalid WEBHOOK_MAX_ATTEMPTS %q", maxAttempts)
+		}
+		backoff.MaxAttempts = attempts
+	}
+	if retryDelay := os.Getenv("WEBHOOK_RETRY_DELAY"); retryDelay != "" {
+		delay, delayError := time.ParseDuration(retryDelay)
+		if delayError != nil || delay <= 0 {
+			log.Fatalf("Invalid WEBHOOK_RETRY_DELAY %q", retryDelay)
+		}
+		backoff.InitialDelay = delay
+	}
+	webhookDispatcher = webhook_dispatcher.NewDispatcher(webhookStore, backoff)
+
+	// EXCHANGE_RATES_FILE holds the rates used to score receipts in other currencies
+	ratesPath := os.Getenv("EXCHANGE_RATES_FILE")
+	if ratesPath != "" {
+		rates, ratesError := currency.LoadRatesFile(ratesPath)
+		if ratesError != nil {
+			log.Fatalf("Loading exchange rates %q failed: %v", ratesPath, ratesError)
+		}
+		exchangeRates = rates
+	}
+	go expirePointsPeriodically(time.Hour)
+
+	router, routerError := newRouter()
+	if routerError != nil {
+		log.Fatalf("Routing the API spec failed: %v", routerError)
+	}
+
+	// GRPC_ADDRESS is where the gRPC service listens, next to the HTTP API on :8080
+	grpcAddress := os.Getenv("GRPC_ADDRESS")
+	if grpcAddress == "" {
+		grpcAddress = ":9090"
+	}
+	grpcListener, listenError := net.Listen("tcp", grpcAddress)
+	if listenError != nil {
+		log.Fatalf("Listening for gRPC on %q failed: %v", grpcAddress, listenError)
+	}
+	go newGrpcServer().Serve(grpcListener)
+
 	http.Handle("/", router)
 	http.ListenAndServe(":8080", nil)
 }
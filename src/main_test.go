@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	account_store "receipt_manager/account_store"
+	api_spec "receipt_manager/api_spec"
+	campaign_store "receipt_manager/campaign_store"
+	idempotency_store "receipt_manager/idempotency_store"
+	product_catalog "receipt_manager/product_catalog"
+	receipt_store "receipt_manager/receipt_store"
+	retailer_catalog "receipt_manager/retailer_catalog"
+	webhook "receipt_manager/webhook"
+	webhook_dispatcher "receipt_manager/webhook_dispatcher"
+	webhook_store "receipt_manager/webhook_store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+)
+
+const specReceipt = `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01",
+	"items": [{"shortDescription": "Gatorade 32oz", "price": "2.25", "quantity": 3}], "total": "6.75"`
+
+type specTestCase struct {
+	method             string
+	path               string
+	contentType        string
+	body               string
+	expectedStatusCode int
+	capture            string
+}
+
+func TestHandlersMatchSpec(test *testing.T) {
+	/*
+		Walks every operation in api.yml through the real router, checking each request
+		and response against the spec. An endpoint whose handler drifts from its spec,
+		or a spec operation with no passing request here, fails the test. Ids returned
+		by earlier steps fill the {placeholders} of later ones. The steps run under
+		every server in api.yml, each against empty stores
+	*/
+	// kin-openapi has no decoder for HTML, so the docs page is checked as a plain string
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.FileBodyDecoder)
+
+	spec, specError := api_spec.Load(apiSpecData)
+	if specError != nil {
+		test.Fatalf("Loading api.yml failed with error: %v", specError)
+	}
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	// Receipt events go to a local receiver, so the delivery log has entries
+	receiver := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	webhookSubscription := `{"url": "` + receiver.URL + `", "events": ["receipt.processed", "receipt.flagged", "receipt.deleted"]}`
+
+	testCases := []specTestCase{
+		{"POST", "/webhooks", "application/json", webhookSubscription, 200, "webhook"},
+		{"POST", "/webhooks", "application/json", `{"url": "/relative", "events": ["receipt.processed"]}`, 400, ""},
+		{"POST", "/campaigns", "application/json", `{"name": "New year bonus", "startDate": "2022-01-01", "endDate": "2022-01-31", "bonus": 10}`, 200, "campaign"},
+		{"GET", "/campaigns", "", "", 200, ""},
+		{"GET", "/campaigns/{campaign}", "", "", 200, ""},
+		{"POST", "/retailers", "application/json", `{"canonicalName": "Walgreens", "aliases": ["Walgreens Pharmacy"], "timezone": "America/New_York"}`, 200, "retailer"},
+		{"GET", "/retailers", "", "", 200, ""},
+		{"GET", "/retailers/{retailer}", "", "", 200, ""},
+		{"PUT", "/retailers/{retailer}", "application/json", `{"canonicalName": "Walgreens", "category": "pharmacy", "multiplier": 1.5}`, 200, ""},
+		{"POST", "/products", "application/json", `{"name": "Gatorade", "category": "beverages", "keywords": ["gatorade"]}`, 200, "product"},
+		{"GET", "/products", "", "", 200, ""},
+		{"GET", "/products/{product}", "", "", 200, ""},
+		{"POST", "/item-rules", "application/json", `{"name": "10 points per Gatorade", "keyword": "gatorade", "pointsPerItem": 10}`, 200, "itemRule"},
+		{"GET", "/item-rules", "", "", 200, ""},
+		{"GET", "/item-rules/{itemRule}", "", "", 200, ""},
+		{"POST", "/receipts/parse", "text/plain", "Target\n2022-01-01 13:01\nGatorade 32oz 2.25\nTOTAL 2.25", 200, ""},
+		{"POST", "/receipts/process", "application/json", specReceipt + `, "accountId": "customer-42"}`, 200, "receipt"},
+		{"POST", "/receipts/process", "application/json", specReceipt + `, "accountId": "customer-42"}`, 409, ""},
+		{"POST", "/receipts/process?onDuplicate=return", "application/json", specReceipt + `, "accountId": "customer-42"}`, 200, ""},
+		{"GET", "/receipts/{receipt}", "", "", 200, ""},
+		{"GET", "/receipts/{receipt}/points", "", "", 200, ""},
+		{"PATCH", "/receipts/{receipt}", "application/json", `{"purchaseTime": "14:33"}`, 200, ""},
+		{"PUT", "/receipts/{receipt}", "application/json", specReceipt + `, "accountId": "customer-42", "timezone": "America/Chicago"}`, 200, ""},
+		{"GET", "/receipts/{receipt}/revisions", "", "", 200, ""},
+		{"GET", "/retailers/unknown", "", "", 200, ""},
+		{"POST", "/receipts/process", "application/json", `{"retailer": "Target"}`, 400, ""},
+		{"POST", "/receipts/process", "application/json", strings.Replace(specReceipt, "2022-01-01", "2022-01-02", 1) + `}`, 200, "otherReceipt"},
+		{"DELETE", "/receipts/{otherReceipt}", "", "", 204, ""},
+		{"GET", "/receipts/{otherReceipt}", "", "", 410, ""},
+		{"GET", "/webhooks", "", "", 200, ""},
+		{"GET", "/webhooks/{webhook}", "", "", 200, ""},
+		{"GET", "/webhooks/{webhook}/deliveries", "", "", 200, ""},
+		{"GET", "/webhooks/dead-letters", "", "", 200, ""},
+		{"DELETE", "/webhooks/{webhook}", "", "", 204, ""},
+		{"DELETE", "/campaigns/{campaign}", "", "", 204, ""},
+		{"DELETE", "/retailers/{retailer}", "", "", 204, ""},
+		{"DELETE", "/products/{product}", "", "", 204, ""},
+		{"DELETE", "/item-rules/{itemRule}", "", "", 204, ""},
+	}
+	// Breakdowns and accounts are only served under /v2, after the steps every server shares
+	v2TestCases := []specTestCase{
+		{"GET", "/receipts/{receipt}/breakdown", "", "", 200, ""},
+		{"GET", "/accounts/customer-42/balance", "", "", 200, ""},
+		{"GET", "/accounts/customer-42/receipts", "", "", 200, ""},
+		{"POST", "/accounts/customer-42/redemptions", "application/json", `{"points": 1, "redemptionKey": "order-1"}`, 200, ""},
+		{"GET", "/accounts/customer-42/ledger", "", "", 200, ""},
+		{"GET", "/accounts/customer-42/reconciliation", "", "", 200, ""},
+		{"GET", "/accounts/customer-42/expirations", "", "", 200, ""},
+		{"DELETE", "/accounts/customer-42", "", "", 204, ""},
+		{"GET", "/accounts/not.an.id/balance", "", "", 400, ""},
+	}
+	metaTestCases := []specTestCase{
+		{"GET", "/openapi.yaml", "", "", 200, ""},
+		{"GET", "/openapi.json", "", "", 200, ""},
+		{"GET", "/docs", "", "", 200, ""},
+		{"POST", "/graphql", "application/json", `{"query": "{ receipts { id points } }"}`, 200, ""},
+		{"POST", "/graphql", "application/json", `{"variables": {}}`, 400, ""},
+	}
+	// v2 answers a newly stored receipt with 201 Created
+	v2StatusCodes := map[string]int{"POST /receipts/process": http.StatusCreated}
+
+	exercised := map[string]bool{}
+	for _, server := range []string{"/v1", "/v2", "/"} {
+		resetStores()
+		captured := map[string]string{}
+		serverTestCases := testCases
+		if server == "/v2" {
+			serverTestCases = append(serverTestCases, v2TestCases...)
+		}
+		if server == "/" {
+			serverTestCases = append(serverTestCases, metaTestCases...)
+		}
+		for _, testCase := range serverTestCases {
+			if statusCode, changed := v2StatusCodes[testCase.method+" "+testCase.path]; changed && server == "/v2" && testCase.expectedStatusCode == http.StatusOK {
+				testCase.expectedStatusCode = statusCode
+			}
+			checkSpecTestCase(test, router, spec, server, testCase, captured, exercised)
+		}
+		webhookDispatcher.Wait()
+	}
+
+	for _, path := range spec.Paths.InMatchingOrder() {
+		pathItem := spec.Paths.Value(path)
+		for _, server := range api_spec.PathServers(spec, pathItem) {
+			for method, operation := range pathItem.Operations() {
+				if !exercised[server.URL+" "+operation.OperationID] {
+					test.Errorf("Operation '%s' (%s %s on %s) has no successful request in this test", operation.OperationID, method, path, server.URL)
+				}
+			}
+		}
+	}
+}
+
+func resetStores() {
+	receiptStore = receipt_store.NewReceiptStore()
+	accountStore = account_store.NewAccountStore()
+	campaignStore = campaign_store.NewCampaignStore()
+	retailerCatalog = retailer_catalog.NewCatalog()
+	productCatalog = product_catalog.NewCatalog()
+	idempotencyStore = idempotency_store.NewIdempotencyStore(24 * time.Hour)
+	webhookStore = webhook_store.NewWebhookStore()
+	webhookDispatcher = webhook_dispatcher.NewDispatcher(webhookStore, webhook.DefaultBackoff)
+	webhookDispatcher.SetAllowLoopback(true)
+}
+
+func checkSpecTestCase(test *testing.T, router *mux.Router, spec *openapi3.T, server string, testCase specTestCase, captured map[string]string, exercised map[string]bool) {
+	prefix := strings.TrimSuffix(server, "/")
+	path := prefix + testCase.path
+	for name, id := range captured {
+		path = strings.ReplaceAll(path, "{"+name+"}", id)
+	}
+	name := testCase.method + " " + path
+	newRequest := func() *http.Request {
+		request := httptest.NewRequest(testCase.method, path, strings.NewReader(testCase.body))
+		if testCase.contentType != "" {
+			request.Header.Set("Content-Type", testCase.contentType)
+		}
+		return request
+	}
+
+	routeMatch := mux.RouteMatch{}
+	if !router.Match(newRequest(), &routeMatch) || routeMatch.Route == nil {
+		test.Errorf("Request '%s' matched no route", name)
+		return
+	}
+	template, _ := routeMatch.Route.GetPathTemplate()
+	template = strings.TrimPrefix(template, prefix)
+	pathItem := spec.Paths.Value(template)
+	operation := pathItem.GetOperation(testCase.method)
+	if operation == nil {
+		test.Errorf("Request '%s' has no operation in api.yml", name)
+		return
+	}
+	input := &openapi3filter.RequestValidationInput{
+		Request:    newRequest(),
+		PathParams: routeMatch.Vars,
+		Route:      &routers.Route{Spec: spec, Path: template, PathItem: pathItem, Method: testCase.method, Operation: operation},
+		Options:    &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	if testCase.expectedStatusCode < 300 {
+		if err := openapi3filter.ValidateRequest(input.Request.Context(), input); err != nil {
+			test.Errorf("Request '%s' doesn't match api.yml: %v", name, err)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, newRequest())
+	if recorder.Code != testCase.expectedStatusCode {
+		test.Errorf("Request '%s', got status %d, but expected %d: %s", name, recorder.Code, testCase.expectedStatusCode, recorder.Body.String())
+		return
+	}
+	responseError := openapi3filter.ValidateResponse(input.Request.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 recorder.Code,
+		Header:                 recorder.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(recorder.Body.Bytes())),
+		Options:                input.Options,
+	})
+	if responseError != nil {
+		test.Errorf("Response to '%s' doesn't match api.yml: %v", name, responseError)
+	}
+
+	// Unversioned paths are deprecated aliases of v1, and every other response isn't deprecated
+	if deprecated := recorder.Header().Get("Deprecation") != ""; deprecated != (server == "/" && len(pathItem.Servers) == 0) {
+		test.Errorf("Response to '%s', got Deprecation '%s'", name, recorder.Header().Get("Deprecation"))
+	}
+	if location := recorder.Header().Get("Location"); location != "" && !strings.HasPrefix(location, prefix+"/receipts/") {
+		test.Errorf("Response to '%s', got Location '%s', but expected it under '%s'", name, location, prefix+"/receipts/")
+	}
+
+	if testCase.expectedStatusCode < 300 {
+		exercised[server+" "+operation.OperationID] = true
+	}
+	if testCase.capture != "" {
+		created := struct {
+			Id string `json:"id"`
+		}{}
+		json.Unmarshal(recorder.Body.Bytes(), &created)
+		captured[testCase.capture] = created.Id
+	}
+}
+
+func TestDuplicateAfterCatalogChange(test *testing.T) {
+	// Adding an alias scores the retailer differently, but doesn't change the receipt's id
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+	submitted := strings.Replace(specReceipt, "Target", "Walgreens Pharmacy", 1) + `, "accountId": "customer-42"}`
+
+	first := serveTestRequest(router, "POST", "/v1/receipts/process", submitted)
+	serveTestRequest(router, "POST", "/v1/retailers", `{"canonicalName": "Walgreens", "aliases": ["Walgreens Pharmacy"], "multiplier": 2}`)
+	second := serveTestRequest(router, "POST", "/v1/receipts/process", submitted)
+
+	if first.Code != http.StatusOK || second.Code != http.StatusConflict {
+		test.Fatalf("Submitting twice, got statuses %d and %d, but expected 200 and 409", first.Code, second.Code)
+	}
+	if first.Header().Get("Location") != second.Header().Get("Location") {
+		test.Errorf("Submitting twice, got locations '%s' and '%s', but expected the same", first.Header().Get("Location"), second.Header().Get("Location"))
+	}
+	credits, _ := accountStore.Credits("customer-42")
+	if len(credits) != 1 {
+		test.Errorf("Got %d credits, but expected 1", len(credits))
+	}
+}
+
+func TestUncachedBreakdownResolvesAliases(test *testing.T) {
+	// A breakdown missing from the cache is scored the same way as when the receipt was submitted
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+	serveTestRequest(router, "POST", "/v1/retailers", `{"canonicalName": "Walgreens", "aliases": ["Walgreens Pharmacy"], "multiplier": 2}`)
+	recorder := serveTestRequest(router, "POST", "/v1/receipts/process", strings.Replace(specReceipt, "Target", "Walgreens Pharmacy", 1)+`}`)
+	var processed struct{ Id string }
+	json.Unmarshal(recorder.Body.Bytes(), &processed)
+	id := processed.Id
+	submitted, breakdownCached := receiptStore.CachedBreakdown(id)
+	if !breakdownCached {
+		test.Fatalf("Submitting the receipt, got status %d and %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Amending the store directly drops the cached breakdown without rescoring
+	storedReceipt, _ := receiptStore.Get(id)
+	receiptStore.Amend(id, storedReceipt)
+	rescored, processorError := receiptBreakdown(id, storedReceipt)
+	if processorError != nil {
+		test.Fatalf("Rescoring failed with error: %v", processorError)
+	}
+	if rescored.Points != submitted.Points || rescored.Retailer == nil {
+		test.Errorf("Got %d points after a cache miss, but expected %d with the retailer's bonus", rescored.Points, submitted.Points)
+	}
+}
+
+func TestUnacceptableBeforeSideEffects(test *testing.T) {
+	// A client that can't read the answer gets 406 before anything is stored, and may retry with the same key
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	request := httptest.NewRequest("POST", "/v1/receipts/process", strings.NewReader(specReceipt+`, "accountId": "customer-42"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "text/html")
+	request.Header.Set(idempotencyKeyHeader, "retry-1")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotAcceptable {
+		test.Fatalf("Accept 'text/html', got status %d, but expected 406", recorder.Code)
+	}
+	credits, _ := accountStore.Credits("customer-42")
+	if stored := receiptStore.List(); len(stored) != 0 || len(credits) != 0 {
+		test.Errorf("Accept 'text/html', got %d receipts and %d credits, but expected none", len(stored), len(credits))
+	}
+
+	request = httptest.NewRequest("POST", "/v1/receipts/process", strings.NewReader(specReceipt+`, "accountId": "customer-42"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(idempotencyKeyHeader, "retry-1")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		test.Errorf("Retrying without Accept, got status %d, but expected 200", recorder.Code)
+	}
+
+	// The docs serve HTML whatever the API formats are
+	request = httptest.NewRequest("GET", "/docs", nil)
+	request.Header.Set("Accept", "text/html")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		test.Errorf("Docs with Accept 'text/html', got status %d, but expected 200", recorder.Code)
+	}
+}
+
+func TestDuplicateAcrossAccounts(test *testing.T) {
+	// A receipt earns points once: another account claiming it is refused without learning its id
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	if recorder := serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`, "accountId": "customer-42"}`); recorder.Code != http.StatusOK {
+		test.Fatalf("First account, got status %d, but expected 200", recorder.Code)
+	}
+	for _, path := range []string{"/v2/receipts/process", "/v2/receipts/process?onDuplicate=return"} {
+		recorder := serveTestRequest(router, "POST", path, specReceipt+`, "accountId": "customer-43"}`)
+		if recorder.Code != http.StatusConflict || !strings.Contains(recorder.Body.String(), `"receipt_claimed"`) {
+			test.Errorf("Case '%s', got status %d and %s, but expected 409 receipt_claimed", path, recorder.Code, recorder.Body.String())
+		}
+		if location := recorder.Header().Get("Location"); location != "" {
+			test.Errorf("Case '%s', got location '%s', but expected none", path, location)
+		}
+	}
+	if _, accountError := accountStore.Credits("customer-43"); accountError == nil {
+		test.Errorf("The second account was credited, but expected no account")
+	}
+}
+
+func TestAmendAccountChange(test *testing.T) {
+	// Amending can't move a receipt's points to another account
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	location := serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`, "accountId": "customer-42"}`).Header().Get("Location")
+	testCases := []struct {
+		method       string
+		body         string
+		expectedCode int
+	}{
+		{method: "PATCH", body: `{"accountId": "customer-43"}`, expectedCode: http.StatusConflict},
+		{method: "PUT", body: specReceipt + `}`, expectedCode: http.StatusConflict},
+		{method: "PATCH", body: `{"accountId": "customer-42"}`, expectedCode: http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		recorder := serveTestRequest(router, testCase.method, location, testCase.body)
+		if recorder.Code != testCase.expectedCode {
+			test.Errorf("Case '%s %s', got status %d, but expected %d",
+				testCase.method, testCase.body, recorder.Code, testCase.expectedCode)
+		}
+	}
+	if credits, _ := accountStore.Credits("customer-42"); len(credits) != 1 {
+		test.Errorf("Got %d credits on the original account, but expected 1", len(credits))
+	}
+}
+
+func TestDeleteAfterRedemption(test *testing.T) {
+	// A receipt whose points were spent can still be deleted; the spent points are written off
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	location := serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`, "accountId": "customer-42"}`).Header().Get("Location")
+	serveTestRequest(router, "POST", "/v2/accounts/customer-42/redemptions", `{"points": 20, "redemptionKey": "order-1"}`)
+
+	if recorder := serveTestRequest(router, "DELETE", location, ""); recorder.Code != http.StatusNoContent {
+		test.Fatalf("Deleting after a redemption, got status %d, but expected 204", recorder.Code)
+	}
+	if recorder := serveTestRequest(router, "GET", location, ""); recorder.Code != http.StatusGone {
+		test.Errorf("Getting the deleted receipt, got status %d, but expected 410", recorder.Code)
+	}
+	if balance, _ := accountStore.Balance("customer-42"); balance != 0 || !accountStore.Balanced() {
+		test.Errorf("Got balance %d after deleting, but expected 0 and a balanced ledger", balance)
+	}
+}
+
+func TestBodyValidation(test *testing.T) {
+	// Bodies that don't match api.yml are turned away with the codes the handlers give the same mistakes
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		body         string
+		expectedCode string
+	}{
+		{"receipt total as a number", "/v2/receipts/process", strings.Replace(specReceipt, `"6.75"`, `6.75`, 1) + `}`, "wrong_field_type"},
+		{"receipt without a total", "/v2/receipts/process", strings.Replace(specReceipt, `, "total": "6.75"`, ``, 1) + `}`, "missing_fields"},
+		{"receipt with an unknown currency", "/v2/receipts/process", specReceipt + `, "currency": "XYZ"}`, "invalid_fields"},
+		{"redemption of no points", "/v2/accounts/customer-42/redemptions", `{"points": 0, "redemptionKey": "order-1"}`, "invalid_redemption"},
+		{"campaign name as a number", "/v2/campaigns", `{"name": 5}`, "request_decoding_failed"},
+	}
+
+	for _, testCase := range testCases {
+		recorder := serveTestRequest(router, "POST", testCase.path, testCase.body)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), `"code":"`+testCase.expectedCode+`"`) {
+			test.Errorf("Case '%s', got status %d and %s, but expected 400 %s", testCase.name, recorder.Code, recorder.Body.String(), testCase.expectedCode)
+		}
+	}
+	if stored := receiptStore.List(); len(stored) != 0 {
+		test.Errorf("Got %d receipts stored, but expected none", len(stored))
+	}
+}
+
+func TestVersionedErrors(test *testing.T) {
+	// Only v2 has typed errors and accounts; v1 and its aliases keep the original error body
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+	receiptWithoutTotal := strings.Replace(specReceipt, `, "total": "6.75"`, ``, 1) + `}`
+
+	testCases := []struct {
+		method              string
+		path                string
+		body                string
+		expectedStatusCode  int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{"POST", "/receipts/process", receiptWithoutTotal, http.StatusBadRequest, "application/json", `{"Error":"The request body field \"total\" is invalid: property \"total\" is missing"}`},
+		{"POST", "/v1/receipts/process", receiptWithoutTotal, http.StatusBadRequest, "application/json", `{"Error":"The request body field \"total\" is invalid: property \"total\" is missing"}`},
+		{"POST", "/v2/receipts/process", receiptWithoutTotal, http.StatusBadRequest, "application/problem+json", `"code":"missing_fields"`},
+		{"GET", "/v1/receipts/missing/breakdown", "", http.StatusNotFound, "application/problem+json", `"code":"route_not_found"`},
+		{"GET", "/v1/accounts/customer-42/balance", "", http.StatusNotFound, "application/problem+json", `"code":"route_not_found"`},
+		{"GET", "/v2/accounts/customer-42/balance", "", http.StatusNotFound, "application/problem+json", `"code":"account_not_found"`},
+	}
+
+	for _, testCase := range testCases {
+		recorder := serveTestRequest(router, testCase.method, testCase.path, testCase.body)
+		if recorder.Code != testCase.expectedStatusCode {
+			test.Errorf("Case '%s %s', got status %d, but expected %d", testCase.method, testCase.path, recorder.Code, testCase.expectedStatusCode)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != testCase.expectedContentType {
+			test.Errorf("Case '%s %s', got content type '%s', but expected '%s'", testCase.method, testCase.path, contentType, testCase.expectedContentType)
+		}
+		if !strings.Contains(recorder.Body.String(), testCase.expectedBody) {
+			test.Errorf("Case '%s %s', got %s, but expected %s", testCase.method, testCase.path, recorder.Body.String(), testCase.expectedBody)
+		}
+	}
+}
+
+func TestUnrecognizedContentType(test *testing.T) {
+	// v1 and the unversioned paths read an unrecognized Content-Type as JSON, and check it against the spec as JSON
+	testCases := []struct {
+		path               string
+		body               string
+		expectedStatusCode int
+	}{
+		{"/receipts/process", specReceipt + `}`, http.StatusOK},
+		{"/v1/receipts/process", specReceipt + `}`, http.StatusOK},
+		{"/v2/receipts/process", specReceipt + `}`, http.StatusUnsupportedMediaType},
+		{"/v1/receipts/process", specReceipt + `, "currency": "XYZ"}`, http.StatusBadRequest},
+		{"/v2/receipts/process", specReceipt + `, "currency": "XYZ"}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, testCase := range testCases {
+		resetStores()
+		router, routerError := newRouter()
+		if routerError != nil {
+			test.Fatalf("Routing api.yml failed with error: %v", routerError)
+		}
+		request := httptest.NewRequest("POST", testCase.path, strings.NewReader(testCase.body))
+		request.Header.Set("Content-Type", "text/plain")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != testCase.expectedStatusCode {
+			test.Errorf("Path '%s' with %s, got status %d, but expected %d", testCase.path, testCase.body, recorder.Code, testCase.expectedStatusCode)
+		}
+		if testCase.expectedStatusCode == http.StatusBadRequest && !strings.Contains(recorder.Body.String(), `field \"currency\" is invalid`) {
+			test.Errorf("Path '%s', got %s, but expected the spec to reject the currency", testCase.path, recorder.Body.String())
+		}
+	}
+}
+
+func TestNonCalendarPurchaseDate(test *testing.T) {
+	// Only receipts credited to an account need a purchase date their points can expire from
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+	receiptData := strings.Replace(specReceipt, "2022-01-01", "2022-02-30", 1)
+
+	if recorder := serveTestRequest(router, "POST", "/v1/receipts/process", receiptData+`}`); recorder.Code != http.StatusOK {
+		test.Errorf("Without an account, got status %d, but expected 200", recorder.Code)
+	}
+	recorder := serveTestRequest(router, "POST", "/v2/receipts/process", receiptData+`, "accountId": "customer-42"}`)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), `"code":"invalid_purchase_date"`) {
+		test.Errorf("With an account, got status %d and %s, but expected 400 invalid_purchase_date", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,128 @@
+package receipt_manager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var ErrInvalidPolicy = errors.New("name policy is invalid")
+
+// Character classes a policy can allow, by the name used in policy files
+var characterClasses = map[string]*unicode.RangeTable{
+	"letters":     unicode.L,
+	"marks":       unicode.M,
+	"digits":      unicode.Nd,
+	"spaces":      unicode.Zs,
+	"punctuation": unicode.P,
+	"symbols":     unicode.S,
+}
+
+type Policy struct {
+	Classes    []string `json:"classes"`
+	Characters string   `json:"characters,omitempty"`
+	MaxLength  int      `json:"maxLength"`
+}
+
+type Policies struct {
+	Retailer    Policy `json:"retailer"`
+	Description Policy `json:"description"`
+}
+
+func DefaultPolicies() Policies {
+	/*
+		Letters in any script, plus the punctuation that turns up in real store and
+		product names. Underscores stay allowed, as the original ^[\w\s\-]+$ allowed
+		them, and there's no length limit unless a policy file sets one
+	*/
+	return Policies{
+		Retailer: Policy{
+			Classes:    []string{"letters", "marks", "digits", "spaces"},
+			Characters: "-_&'’.",
+		},
+		Description: Policy{
+			Classes:    []string{"letters", "marks", "digits", "spaces"},
+			Characters: "-_&'’./%",
+		},
+	}
+}
+
+func LoadPoliciesFile(path string) (Policies, error) {
+	// Policies missing from the file keep their defaults
+	policiesData, err := os.ReadFile(path)
+	if err != nil {
+		return Policies{}, err
+	}
+
+	policies := DefaultPolicies()
+	if err := json.Unmarshal(policiesData, &policies); err != nil {
+		return Policies{}, err
+	}
+	if err := policies.Retailer.validate(); err != nil {
+		return Policies{}, err
+	}
+	if err := policies.Description.validate(); err != nil {
+		return Policies{}, err
+	}
+	return policies, nil
+}
+
+func Normalize(name string) string {
+	// Names are compared, counted and stored in NFC, so "é" is one character however it was typed
+	return norm.NFC.String(name)
+}
+
+func (policy Policy) Valid(name string) bool {
+	/*
+		A valid name is non-empty after normalization, fits the length limit in
+		characters rather than bytes, has at least one letter or digit, and only
+		uses the policy's character classes and extra characters
+	*/
+	name = Normalize(name)
+	if strings.TrimSpace(name) == "" || !utf8.ValidString(name) {
+		return false
+	}
+	if policy.MaxLength > 0 && utf8.RuneCountInString(name) > policy.MaxLength {
+		return false
+	}
+
+	hasLetterOrDigit := false
+	for _, char := range name {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			hasLetterOrDigit = true
+		}
+		if !policy.allows(char) {
+			return false
+		}
+	}
+	return hasLetterOrDigit
+}
+
+func (policy Policy) allows(char rune) bool {
+	if strings.ContainsRune(policy.Characters, char) {
+		return true
+	}
+	for _, class := range policy.Classes {
+		if unicode.Is(characterClasses[class], char) {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy Policy) validate() error {
+	if policy.MaxLength < 0 || len(policy.Classes) == 0 {
+		return ErrInvalidPolicy
+	}
+	for _, class := range policy.Classes {
+		if _, classKnown := characterClasses[class]; !classKnown {
+			return ErrInvalidPolicy
+		}
+	}
+	return nil
+}
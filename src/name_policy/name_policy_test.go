@@ -0,0 +1,52 @@
+package receipt_manager_test
+
+import (
+	np "receipt_manager/name_policy"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(test *testing.T) {
+	testCases := []struct {
+		name         string
+		expectedName string
+	}{
+		{name: "Cafe\u0301", expectedName: "Caf\u00e9"},
+		{name: "Caf\u00e9", expectedName: "Caf\u00e9"},
+		{name: "Target", expectedName: "Target"},
+	}
+
+	for _, testCase := range testCases {
+		normalizedName := np.Normalize(testCase.name)
+		if normalizedName != testCase.expectedName {
+			test.Errorf("Name %q, got %q, but expected %q", testCase.name, normalizedName, testCase.expectedName)
+		}
+	}
+}
+
+func TestPolicyValid(test *testing.T) {
+	strictPolicy := np.Policy{Classes: []string{"letters", "spaces"}, MaxLength: 5}
+
+	testCases := []struct {
+		policy           np.Policy
+		name             string
+		expectedValidity bool
+	}{
+		{policy: np.DefaultPolicies().Retailer, name: "Café Olé", expectedValidity: true},
+		{policy: np.DefaultPolicies().Description, name: "2% Milk 1/2 gal", expectedValidity: true},
+		{policy: np.DefaultPolicies().Description, name: "4,000 fish sticks", expectedValidity: false},
+		{policy: np.DefaultPolicies().Retailer, name: "Joe_s Market", expectedValidity: true},
+		{policy: np.DefaultPolicies().Retailer, name: strings.Repeat("Market ", 30), expectedValidity: true},
+		{policy: strictPolicy, name: "Café", expectedValidity: true},
+		{policy: strictPolicy, name: "Café Olé", expectedValidity: false},
+		{policy: strictPolicy, name: "Ca 7", expectedValidity: false},
+		{policy: strictPolicy, name: "   ", expectedValidity: false},
+	}
+
+	for _, testCase := range testCases {
+		validity := testCase.policy.Valid(testCase.name)
+		if validity != testCase.expectedValidity {
+			test.Errorf("Name '%s', validity is %t, but expected %t", testCase.name, validity, testCase.expectedValidity)
+		}
+	}
+}
@@ -3,16 +3,25 @@ package receipt_manager
 import (
 	"errors"
 	"math"
+	campaign "receipt_manager/campaign"
+	currency "receipt_manager/currency"
+	item "receipt_manager/item"
+	name_policy "receipt_manager/name_policy"
+	product_catalog "receipt_manager/product_catalog"
+	purchase_time "receipt_manager/purchase_time"
 	receipt "receipt_manager/receipt"
+	retailer_catalog "receipt_manager/retailer_catalog"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 func RetailerNamePoints(receipt receipt.Receipt) (int, error) {
-	// One point for every alphanumeric character in the retailer name
+	// One point for every alphanumeric character in the NFC-normalized retailer name
 	addedPoints := 0
-	for _, char := range receipt.Retailer {
+	for _, char := range name_policy.Normalize(receipt.Retailer) {
 		if unicode.IsLetter(char) || unicode.IsDigit(char) {
 			addedPoints++
 		}
@@ -37,22 +46,24 @@ func MultipleOfQuarterPoints(receipt receipt.Receipt) (int, error) {
 		return -1, errors.New("strconv.ParseFloat() in multipleOfQuarterPoints() failed")
 	}
 
-	if math.Mod(totalFloat / .25, 1.0) == 0 {
+	if math.Mod(totalFloat/.25, 1.0) == 0 {
 		return 25, nil
 	}
 	return 0, nil
 }
 
 func EveryTwoItemsPoints(receipt receipt.Receipt) (int, error) {
-	// 5 points for every two items on the receipt
-	return (len(receipt.Items) / 2) * 5, nil
+	// 5 points for every two items on the receipt, counting each unit of a quantity
+	return (countUnits(receipt) / 2) * 5, nil
 }
 
 func DescriptionLengthPoints(receipt receipt.Receipt) (int, error) {
 	/*
-	If the trimmed length of the item description is a multiple of 3,
-	multiply the price by `0.2` and round up to the nearest integer
-	The result is the number of points earned
+		If the trimmed length of the item description is a multiple of 3,
+		multiply the price by `0.2` and round up to the nearest integer
+		The result is the number of points earned
+		An item with a quantity scores each unit at its unit price, the same as
+		if every unit had been listed separately
 	*/
 	addedPoints := 0
 	for _, item := range receipt.Items {
@@ -61,8 +72,17 @@ func DescriptionLengthPoints(receipt receipt.Receipt) (int, error) {
 			return -1, errors.New("strconv.ParseFloat() in descriptionLengthPoints() failed")
 		}
 
-		if len(strings.TrimSpace(item.ShortDescription)) % 3 == 0 {
-			addedPoints += int(math.Ceil(priceFloat * .2))
+		unitPriceFloat := priceFloat / float64(item.Units())
+		if item.UnitPrice != "" {
+			unitPriceFloat, err = strconv.ParseFloat(item.UnitPrice, 64)
+			if err != nil {
+				return -1, errors.New("strconv.ParseFloat() in descriptionLengthPoints() failed")
+			}
+		}
+
+		description := strings.TrimSpace(name_policy.Normalize(item.ShortDescription))
+		if utf8.RuneCountInString(description)%3 == 0 {
+			addedPoints += item.Units() * int(math.Ceil(unitPriceFloat*.2))
 		}
 	}
 	return addedPoints, nil
@@ -82,37 +102,62 @@ func OddPurchaseDatePoints(receipt receipt.Receipt) (int, error) {
 		return -1, errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed")
 	}
 
-	if int(dateInt) % 2 == 1 {
+	if int(dateInt)%2 == 1 {
 		return 6, nil
 	}
 	return 0, nil
 }
 
 func PurchaseTimePoints(receipt receipt.Receipt) (int, error) {
+	// A time that isn't on the clock, such as 25:00, can't fall in the window
+	purchasedAt, err := time.Parse("15:04", receipt.PurchaseTime)
+	if err != nil {
+		return 0, nil
+	}
+	return purchaseTimeOfDayPoints(purchasedAt), nil
+}
+
+func purchaseTimeOfDayPoints(purchasedAt time.Time) int {
 	// 10 points if the time of purchase is after 2:00pm and before 4:00pm
-	if receipt.PurchaseTime > "14:00" && receipt.PurchaseTime < "16:00" {
-		return 10, nil
+	minuteOfDay := purchasedAt.Hour()*60 + purchasedAt.Minute()
+	if minuteOfDay > 14*60 && minuteOfDay < 16*60 {
+		return 10
 	}
-	return 0, nil
+	return 0
+}
+
+func countUnits(receipt receipt.Receipt) int {
+	units := 0
+	for _, item := range receipt.Items {
+		units += item.Units()
+	}
+	return units
 }
 
 type pointCalculators func(receipt receipt.Receipt) (int, error)
 
-func pointRuleFunctions() []pointCalculators {
-	return []pointCalculators {
-		RetailerNamePoints,
-		RoundDollarAmountPoints,
-		MultipleOfQuarterPoints, 
-		EveryTwoItemsPoints,
-		DescriptionLengthPoints, 
-		OddPurchaseDatePoints, 
-		PurchaseTimePoints}
+type pointRule struct {
+	name      string
+	calculate pointCalculators
+	// Rules on the time of day are judged on the purchase timestamp whenever it's known
+	calculateAt func(purchasedAt time.Time) int
+}
+
+func pointRules() []pointRule {
+	return []pointRule{
+		{"retailerName", RetailerNamePoints, nil},
+		{"roundDollarAmount", RoundDollarAmountPoints, nil},
+		{"multipleOfQuarter", MultipleOfQuarterPoints, nil},
+		{"everyTwoItems", EveryTwoItemsPoints, nil},
+		{"descriptionLength", DescriptionLengthPoints, nil},
+		{"oddPurchaseDate", OddPurchaseDatePoints, nil},
+		{"purchaseTime", PurchaseTimePoints, purchaseTimeOfDayPoints}}
 }
 
 func ProcessReceipt(receipt receipt.Receipt) (int, error) {
-	  totalPoints := 0
-	  for _, function := range pointRuleFunctions() {
-		points, err := function(receipt)
+	totalPoints := 0
+	for _, rule := range pointRules() {
+		points, err := rule.calculate(receipt)
 		if err != nil {
 			return -1, err
 		}
@@ -120,3 +165,179 @@ func ProcessReceipt(receipt receipt.Receipt) (int, error) {
 	}
 	return totalPoints, nil
 }
+
+type RulePoints struct {
+	Rule   string `json:"rule"`
+	Points int    `json:"points"`
+}
+
+type CampaignPoints struct {
+	CampaignId string `json:"campaignId"`
+	Name       string `json:"name"`
+	Points     int    `json:"points"`
+}
+
+type RetailerPoints struct {
+	RetailerId string `json:"retailerId"`
+	Name       string `json:"name"`
+	Points     int    `json:"points"`
+}
+
+type ItemRulePoints struct {
+	RuleId string `json:"ruleId"`
+	Name   string `json:"name"`
+	Items  int    `json:"items"`
+	Points int    `json:"points"`
+}
+
+type Conversion struct {
+	Currency string  `json:"currency"`
+	Rate     float64 `json:"rate"`
+	Total    string  `json:"total"`
+}
+
+type Breakdown struct {
+	Rules       []RulePoints     `json:"rules"`
+	BasePoints  int              `json:"basePoints"`
+	Retailer    *RetailerPoints  `json:"retailer,omitempty"`
+	ItemRules   []ItemRulePoints `json:"itemRules"`
+	Campaigns   []CampaignPoints `json:"campaigns"`
+	Points      int              `json:"points"`
+	PurchasedAt *time.Time       `json:"purchasedAt,omitempty"`
+	Conversion  *Conversion      `json:"conversion,omitempty"`
+}
+
+type ScoringContext struct {
+	Campaigns []campaign.Campaign
+	Retailer  *retailer_catalog.Retailer
+	ItemRules []product_catalog.ItemRule
+	Rates     currency.RateProvider
+}
+
+func BreakdownReceipt(receipt receipt.Receipt, context ScoringContext) (Breakdown, error) {
+	/*
+		Catalog retailers may scale or switch off individual base rules, and their
+		multiplier, item rules and campaigns are layered on once every base rule has been scored
+	*/
+	breakdown := Breakdown{Rules: []RulePoints{}, ItemRules: []ItemRulePoints{}, Campaigns: []CampaignPoints{}}
+
+	// Time-based rules and campaign windows are judged on the store's local clock
+	storeZone := ""
+	if context.Retailer != nil {
+		storeZone = context.Retailer.Timezone
+	}
+	receipt, err := purchase_time.Localize(receipt, storeZone)
+	if err != nil {
+		return Breakdown{}, err
+	}
+	purchasedAt, timestampErr := purchase_time.Timestamp(receipt, storeZone)
+	if timestampErr == nil {
+		breakdown.PurchasedAt = &purchasedAt
+	}
+
+	receipt, breakdown.Conversion, err = convertReceipt(receipt, context.Rates)
+	if err != nil {
+		return Breakdown{}, err
+	}
+
+	for _, rule := range pointRules() {
+		points, err := rule.calculate(receipt)
+		if rule.calculateAt != nil && breakdown.PurchasedAt != nil {
+			points, err = rule.calculateAt(*breakdown.PurchasedAt), nil
+		}
+		if err != nil {
+			return Breakdown{}, err
+		}
+		if context.Retailer != nil {
+			ruleMultiplier, ruleOverridden := context.Retailer.RuleMultipliers[rule.name]
+			if ruleOverridden {
+				points = int(float64(points) * ruleMultiplier)
+			}
+		}
+		breakdown.Rules = append(breakdown.Rules, RulePoints{Rule: rule.name, Points: points})
+		breakdown.BasePoints += points
+	}
+
+	breakdown.Points = breakdown.BasePoints
+	if context.Retailer != nil && context.Retailer.Multiplier > 1 {
+		breakdown.Retailer = &RetailerPoints{
+			RetailerId: context.Retailer.Id,
+			Name:       context.Retailer.CanonicalName,
+			Points:     int(float64(breakdown.BasePoints) * (context.Retailer.Multiplier - 1)),
+		}
+		breakdown.Points += breakdown.Retailer.Points
+	}
+
+	for _, itemRule := range context.ItemRules {
+		itemRulePoints := ItemRulePoints{RuleId: itemRule.Id, Name: itemRule.Name}
+		for _, receiptItem := range receipt.Items {
+			if itemRule.Matches(receiptItem) {
+				itemRulePoints.Items += receiptItem.Units()
+				itemRulePoints.Points += itemRule.Points(receiptItem, breakdown.BasePoints, receipt.Total)
+			}
+		}
+		if itemRulePoints.Items > 0 {
+			breakdown.ItemRules = append(breakdown.ItemRules, itemRulePoints)
+			breakdown.Points += itemRulePoints.Points
+		}
+	}
+
+	for _, activeCampaign := range context.Campaigns {
+		if !activeCampaign.Applies(receipt) {
+			continue
+		}
+		campaignPoints := CampaignPoints{
+			CampaignId: activeCampaign.Id,
+			Name:       activeCampaign.Name,
+			Points:     activeCampaign.BonusPoints(breakdown.BasePoints),
+		}
+		breakdown.Campaigns = append(breakdown.Campaigns, campaignPoints)
+		breakdown.Points += campaignPoints.Points
+	}
+	return breakdown, nil
+}
+
+func convertReceipt(receipt receipt.Receipt, rates currency.RateProvider) (receipt.Receipt, *Conversion, error) {
+	/*
+		The rules are written for dollar amounts, so a receipt in another currency
+		has its total and prices converted to the scoring currency before it is scored
+	*/
+	if receipt.Currency == "" || receipt.Currency == currency.ScoringCurrency {
+		return receipt, nil, nil
+	}
+	if rates == nil {
+		return receipt, nil, currency.ErrRateUnavailable
+	}
+
+	from, err := currency.Lookup(receipt.Currency)
+	if err != nil {
+		return receipt, nil, err
+	}
+	to, _ := currency.Lookup(currency.ScoringCurrency)
+	rate, err := rates.Rate(from.Code, to.Code)
+	if err != nil {
+		return receipt, nil, err
+	}
+
+	receipt.Total, err = currency.Convert(receipt.Total, from, to, rate)
+	if err != nil {
+		return receipt, nil, err
+	}
+	convertedItems := make([]item.Item, len(receipt.Items))
+	for index, receiptItem := range receipt.Items {
+		receiptItem.Price, err = currency.Convert(receiptItem.Price, from, to, rate)
+		if err != nil {
+			return receipt, nil, err
+		}
+		if receiptItem.UnitPrice != "" {
+			receiptItem.UnitPrice, err = currency.Convert(receiptItem.UnitPrice, from, to, rate)
+			if err != nil {
+				return receipt, nil, err
+			}
+		}
+		convertedItems[index] = receiptItem
+	}
+	receipt.Items = convertedItems
+	receipt.Currency = to.Code
+	return receipt, &Conversion{Currency: from.Code, Rate: rate, Total: receipt.Total}, nil
+}
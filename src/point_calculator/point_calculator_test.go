@@ -1,161 +1,171 @@
-package receipt_manager
+package receipt_manager_test
 
 import (
 	"errors"
+	campaign "receipt_manager/campaign"
+	currency "receipt_manager/currency"
 	item "receipt_manager/item"
 	pc "receipt_manager/point_calculator"
 	receipt "receipt_manager/receipt"
+	retailer_catalog "receipt_manager/retailer_catalog"
+	"reflect"
 	"testing"
 )
 
 func TestRetailerNamePoints(test *testing.T) {
-    testCases := []struct {
-        receipt receipt.Receipt
-        expectedPoints int
-		expectedErr error
-    }{
-        {
-            receipt: receipt.Receipt{Retailer: "The Stuff Store"},
-            expectedPoints: 13,
-			expectedErr: nil,
-        },
-        {
-            receipt: receipt.Receipt{Retailer: "22 Aghast!?!?!"},
-            expectedPoints: 8,
-			expectedErr: nil,
-		},
-		{
-            receipt: receipt.Receipt{Retailer: ""},
-            expectedPoints: 0,
-			expectedErr: nil,
-   	 	},
-		{
-            receipt: receipt.Receipt{Retailer: "(@*& #$)(* @)#$(*/)"},
-            expectedPoints: 0,
-			expectedErr: nil,
-   	 	},
+	testCases := []struct {
+		receipt        receipt.Receipt
+		expectedPoints int
+		expectedErr    error
+	}{
+		{
+			receipt:        receipt.Receipt{Retailer: "The Stuff Store"},
+			expectedPoints: 13,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Retailer: "22 Aghast!?!?!"},
+			expectedPoints: 8,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Retailer: ""},
+			expectedPoints: 0,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Retailer: "(@*& #$)(* @)#$(*/)"},
+			expectedPoints: 0,
+			expectedErr:    nil,
+		},
+		{
+			// Hangul typed as separate jamo composes to a single syllable
+			receipt:        receipt.Receipt{Retailer: "\u1112\u1161\u11ab Mart"},
+			expectedPoints: 5,
+			expectedErr:    nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		actualPoints, err := pc.RetailerNamePoints(testCase.receipt)
+
+		if err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if actualPoints != testCase.expectedPoints {
+			test.Errorf("Retailer '%s', got %d points, but expected %d",
+				testCase.receipt.Retailer, actualPoints, testCase.expectedPoints)
+		}
 	}
-		
-    for _, testCase := range testCases {
-        actualPoints, err := pc.RetailerNamePoints(testCase.receipt)
-        
-        if err != nil {
-            test.Errorf("Test failed with error: %v", err)
-        }
-        
-        if actualPoints != testCase.expectedPoints {
-            test.Errorf("Retailer '%s', got %d points, but expected %d",
-                testCase.receipt.Retailer, actualPoints, testCase.expectedPoints)
-        }
-    }
 }
 
 func TestRoundDollarAmountPoints(test *testing.T) {
-    testCases := []struct {
-        receipt receipt.Receipt
-        expectedPoints int
-        expectedErr error
-    }{
-        {
-            receipt: receipt.Receipt{Total: "100.00"},
-            expectedPoints: 50,
-            expectedErr: nil,
-        },
-		{
-            receipt: receipt.Receipt{Total: "-100.00"},
-            expectedPoints: 50,
-            expectedErr: nil,
-        },
-		{
-            receipt: receipt.Receipt{Total: "200.50"},
-            expectedPoints: 0,
-            expectedErr: nil,
-        },
-		{
-            receipt: receipt.Receipt{Total: "150.75"},
-            expectedPoints: 0,
-            expectedErr: nil,
-        },
-		{
-            receipt: receipt.Receipt{Total: ""},
-            expectedPoints: 0,
-            expectedErr: nil,
-        },
-    }
-		
-    for _, testCase := range testCases {
-        actualPoints, err := pc.RoundDollarAmountPoints(testCase.receipt)
-        
-        if testCase.expectedErr == nil && err != nil {
-            test.Errorf("Test failed with error: %v", err)
-        }
-        
-        if actualPoints != testCase.expectedPoints {
-            test.Errorf("Total '%s', got %d points, but expected %d",
-                testCase.receipt.Total, actualPoints, testCase.expectedPoints)
-        }
-    }
+	testCases := []struct {
+		receipt        receipt.Receipt
+		expectedPoints int
+		expectedErr    error
+	}{
+		{
+			receipt:        receipt.Receipt{Total: "100.00"},
+			expectedPoints: 50,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: "-100.00"},
+			expectedPoints: 50,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: "200.50"},
+			expectedPoints: 0,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: "150.75"},
+			expectedPoints: 0,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: ""},
+			expectedPoints: 0,
+			expectedErr:    nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		actualPoints, err := pc.RoundDollarAmountPoints(testCase.receipt)
+
+		if testCase.expectedErr == nil && err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if actualPoints != testCase.expectedPoints {
+			test.Errorf("Total '%s', got %d points, but expected %d",
+				testCase.receipt.Total, actualPoints, testCase.expectedPoints)
+		}
+	}
 }
 
 func TestMultipleOfQuarterPoints(test *testing.T) {
 	testCases := []struct {
-        receipt receipt.Receipt
-        expectedPoints int
-        expectedErr error
-    }{
-        {
-            receipt: receipt.Receipt{Total: "1.50"},
-            expectedPoints: 25,
-            expectedErr: nil,
-        },
-        {
-            receipt: receipt.Receipt{Total: "1.78"},
-            expectedPoints: 0,
-            expectedErr: nil,
-        },
-		{
-            receipt: receipt.Receipt{Total: "0.00"},
-            expectedPoints: 25,
-            expectedErr: nil,
-        },
-        {
-            receipt: receipt.Receipt{Total: "-2.00"},
-            expectedPoints: 25,
-            expectedErr: nil,
-        },
-        {
-            receipt: receipt.Receipt{Total: "abc"},
-            expectedPoints: -1,
-            expectedErr: errors.New("strconv.ParseFloat() in multipleOfQuarterPoints() failed"),
-        },
-        {
-            receipt: receipt.Receipt{Total: ""},
-            expectedPoints: -1,
-            expectedErr: errors.New("strconv.ParseFloat() in multipleOfQuarterPoints() failed"),
-        },
-    }
+		receipt        receipt.Receipt
+		expectedPoints int
+		expectedErr    error
+	}{
+		{
+			receipt:        receipt.Receipt{Total: "1.50"},
+			expectedPoints: 25,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: "1.78"},
+			expectedPoints: 0,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: "0.00"},
+			expectedPoints: 25,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: "-2.00"},
+			expectedPoints: 25,
+			expectedErr:    nil,
+		},
+		{
+			receipt:        receipt.Receipt{Total: "abc"},
+			expectedPoints: -1,
+			expectedErr:    errors.New("strconv.ParseFloat() in multipleOfQuarterPoints() failed"),
+		},
+		{
+			receipt:        receipt.Receipt{Total: ""},
+			expectedPoints: -1,
+			expectedErr:    errors.New("strconv.ParseFloat() in multipleOfQuarterPoints() failed"),
+		},
+	}
 
 	for _, testCase := range testCases {
-        actualPoints, err := pc.MultipleOfQuarterPoints(testCase.receipt)
-        
-        if testCase.expectedErr == nil && err != nil {
-            test.Errorf("Test failed with error: %v", err)
-        }
-        
-        if actualPoints != testCase.expectedPoints {
-            test.Errorf("Total '%s', got %d points, but expected %d",
-                testCase.receipt.Total, actualPoints, testCase.expectedPoints)
-        }
-    }
+		actualPoints, err := pc.MultipleOfQuarterPoints(testCase.receipt)
+
+		if testCase.expectedErr == nil && err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if actualPoints != testCase.expectedPoints {
+			test.Errorf("Total '%s', got %d points, but expected %d",
+				testCase.receipt.Total, actualPoints, testCase.expectedPoints)
+		}
+	}
 }
 
 func TestEveryTwoItemsPoints(test *testing.T) {
 	testCases := []struct {
-        receipt receipt.Receipt
-        expectedPoints int
-        expectedErr error
-    }{
-        {
+		receipt        receipt.Receipt
+		expectedPoints int
+		expectedErr    error
+	}{
+		{
 			receipt: receipt.Receipt{
 				Items: []item.Item{
 					{ShortDescription: "Item1", Price: "10.00"},
@@ -165,7 +175,7 @@ func TestEveryTwoItemsPoints(test *testing.T) {
 				},
 			},
 			expectedPoints: 10,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
 		{
 			receipt: receipt.Receipt{
@@ -176,7 +186,7 @@ func TestEveryTwoItemsPoints(test *testing.T) {
 				},
 			},
 			expectedPoints: 5,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
 		{
 			receipt: receipt.Receipt{
@@ -185,34 +195,44 @@ func TestEveryTwoItemsPoints(test *testing.T) {
 				},
 			},
 			expectedPoints: 0,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
-			{
-			receipt: receipt.Receipt{Items: []item.Item{}},
+		{
+			receipt:        receipt.Receipt{Items: []item.Item{}},
 			expectedPoints: 0,
-			expectedErr: nil,
+			expectedErr:    nil,
+		},
+		{
+			receipt: receipt.Receipt{
+				Items: []item.Item{
+					{ShortDescription: "Doritos", Price: "4.50", Quantity: 3, UnitPrice: "1.50"},
+					{ShortDescription: "Item2", Price: "15.00"},
+				},
+			},
+			expectedPoints: 10,
+			expectedErr:    nil,
 		},
 	}
 
 	for _, testCase := range testCases {
-        actualPoints, err := pc.EveryTwoItemsPoints(testCase.receipt)
-        
-        if testCase.expectedErr == nil && err != nil {
-            test.Errorf("Test failed with error: %v", err)
-        }
-        
-        if actualPoints != testCase.expectedPoints {
-            test.Errorf("Items '%s', got %d points, but expected %d",
-                testCase.receipt.Items, actualPoints, testCase.expectedPoints)
-        }
-    }
+		actualPoints, err := pc.EveryTwoItemsPoints(testCase.receipt)
+
+		if testCase.expectedErr == nil && err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if actualPoints != testCase.expectedPoints {
+			test.Errorf("Items '%v', got %d points, but expected %d",
+				testCase.receipt.Items, actualPoints, testCase.expectedPoints)
+		}
+	}
 }
 
 func TestDescriptionLengthPoints(test *testing.T) {
 	testCases := []struct {
-		receipt receipt.Receipt
+		receipt        receipt.Receipt
 		expectedPoints int
-		expectedErr error
+		expectedErr    error
 	}{
 		{
 			receipt: receipt.Receipt{Items: []item.Item{
@@ -221,7 +241,7 @@ func TestDescriptionLengthPoints(test *testing.T) {
 				{Price: "20.00", ShortDescription: "Grapefruit"},
 			}},
 			expectedPoints: 3,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
 		{
 			receipt: receipt.Receipt{Items: []item.Item{
@@ -230,19 +250,19 @@ func TestDescriptionLengthPoints(test *testing.T) {
 				{Price: "20.00", ShortDescription: "Grapefruit"},
 			}},
 			expectedPoints: 0,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
 		{
 			receipt: receipt.Receipt{Items: []item.Item{
 				{Price: "1.00", ShortDescription: "Hat"},
 			}},
 			expectedPoints: 1,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
 		{
-			receipt: receipt.Receipt{Items: []item.Item{}},
+			receipt:        receipt.Receipt{Items: []item.Item{}},
 			expectedPoints: 0,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
 		{
 			receipt: receipt.Receipt{Items: []item.Item{
@@ -250,67 +270,317 @@ func TestDescriptionLengthPoints(test *testing.T) {
 				{Price: "-15.00", ShortDescription: "Banana"},
 			}},
 			expectedPoints: -3,
-			expectedErr: nil,
+			expectedErr:    nil,
+		},
+		{
+			receipt: receipt.Receipt{Items: []item.Item{
+				{Price: "4.50", ShortDescription: "Doritos Cool", Quantity: 3, UnitPrice: "1.50"},
+			}},
+			expectedPoints: 3,
+			expectedErr:    nil,
+		},
+		{
+			receipt: receipt.Receipt{Items: []item.Item{
+				{Price: "4.50", ShortDescription: "Doritos Cool", Quantity: 3},
+			}},
+			expectedPoints: 3,
+			expectedErr:    nil,
 		},
 	}
 
 	for _, testCase := range testCases {
 		actualPoints, err := pc.DescriptionLengthPoints(testCase.receipt)
-        
-        if testCase.expectedErr == nil && err != nil {
-            test.Errorf("Test failed with error: %v", err)
-        }
-        
-        if actualPoints != testCase.expectedPoints {
-            test.Errorf("Items '%s', got %d points, but expected %d",
-                testCase.receipt.Items, actualPoints, testCase.expectedPoints)
-        }
+
+		if testCase.expectedErr == nil && err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if actualPoints != testCase.expectedPoints {
+			test.Errorf("Items '%v', got %d points, but expected %d",
+				testCase.receipt.Items, actualPoints, testCase.expectedPoints)
+		}
 	}
 }
 
 func TestOddPurchaseDatePoints(test *testing.T) {
 	testCases := []struct {
-		receipt receipt.Receipt
+		receipt        receipt.Receipt
 		expectedPoints int
-		expectedErr error
+		expectedErr    error
 	}{
 		{
-			receipt: receipt.Receipt{PurchaseDate: "2024-02-15"},
+			receipt:        receipt.Receipt{PurchaseDate: "2024-02-15"},
 			expectedPoints: 6,
-			expectedErr: nil,
+			expectedErr:    nil,
 		},
 		{
-			receipt: receipt.Receipt{PurchaseDate: "asdf-02-16"},
+			receipt:        receipt.Receipt{PurchaseDate: "asdf-02-16"},
 			expectedPoints: 0,
-			expectedErr: errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
+			expectedErr:    errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
 		},
 		{
-			receipt: receipt.Receipt{PurchaseDate: ""},
+			receipt:        receipt.Receipt{PurchaseDate: ""},
 			expectedPoints: -1,
-			expectedErr: errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
+			expectedErr:    errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
 		},
 		{
-			receipt: receipt.Receipt{PurchaseDate: "2024-02-"},
+			receipt:        receipt.Receipt{PurchaseDate: "2024-02-"},
 			expectedPoints: -1,
-			expectedErr: errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
+			expectedErr:    errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
 		},
 		{
-			receipt: receipt.Receipt{PurchaseDate: "2024-02-5"},
+			receipt:        receipt.Receipt{PurchaseDate: "2024-02-5"},
 			expectedPoints: -1,
-			expectedErr: errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
+			expectedErr:    errors.New("strconv.Atoi() in oddPurchaseDatePoints() failed"),
 		},
 	}
 
 	for _, testCase := range testCases {
 		actualPoints, err := pc.OddPurchaseDatePoints(testCase.receipt)
-        
-        if testCase.expectedErr == nil && err != nil {
-            test.Errorf("Test failed with error: %v", err)
-        }
-        
-        if actualPoints != testCase.expectedPoints {
-            test.Errorf("Purchase date '%s', got %d points, but expected %d",
-                testCase.receipt.PurchaseDate, actualPoints, testCase.expectedPoints)
-        }
+
+		if testCase.expectedErr == nil && err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if actualPoints != testCase.expectedPoints {
+			test.Errorf("Purchase date '%s', got %d points, but expected %d",
+				testCase.receipt.PurchaseDate, actualPoints, testCase.expectedPoints)
+		}
+	}
+}
+
+func TestPurchaseTimePoints(test *testing.T) {
+	testCases := []struct {
+		purchaseTime   string
+		expectedPoints int
+	}{
+		{purchaseTime: "14:00", expectedPoints: 0},
+		{purchaseTime: "14:01", expectedPoints: 10},
+		{purchaseTime: "15:59", expectedPoints: 10},
+		{purchaseTime: "16:00", expectedPoints: 0},
+		{purchaseTime: "25:00", expectedPoints: 0},
+		{purchaseTime: "15:5", expectedPoints: 0},
+		{purchaseTime: "", expectedPoints: 0},
+	}
+
+	for _, testCase := range testCases {
+		actualPoints, err := pc.PurchaseTimePoints(receipt.Receipt{PurchaseTime: testCase.purchaseTime})
+		if err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if actualPoints != testCase.expectedPoints {
+			test.Errorf("Purchase time '%s', got %d points, but expected %d",
+				testCase.purchaseTime, actualPoints, testCase.expectedPoints)
+		}
+	}
+}
+
+func TestBreakdownReceipt(test *testing.T) {
+	targetReceipt := receipt.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []item.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+			{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+		},
+		Total: "35.35",
+	}
+
+	testCases := []struct {
+		campaigns         []campaign.Campaign
+		expectedPoints    int
+		expectedCampaigns int
+	}{
+		{
+			campaigns:         nil,
+			expectedPoints:    28,
+			expectedCampaigns: 0,
+		},
+		{
+			campaigns: []campaign.Campaign{
+				{Id: "campaign-1", StartDate: "2022-01-01", EndDate: "2022-01-31", Multiplier: 2},
+				{Id: "campaign-2", StartDate: "2022-01-01", EndDate: "2022-01-31", Bonus: 100},
+			},
+			expectedPoints:    156,
+			expectedCampaigns: 2,
+		},
+		{
+			campaigns: []campaign.Campaign{
+				{Id: "campaign-1", StartDate: "2022-02-01", EndDate: "2022-02-28", Bonus: 100},
+			},
+			expectedPoints:    28,
+			expectedCampaigns: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		breakdown, err := pc.BreakdownReceipt(targetReceipt, pc.ScoringContext{Campaigns: testCase.campaigns})
+		if err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if breakdown.BasePoints != 28 {
+			test.Errorf("Got %d base points, but expected %d", breakdown.BasePoints, 28)
+		}
+		if breakdown.Points != testCase.expectedPoints {
+			test.Errorf("Got %d points, but expected %d", breakdown.Points, testCase.expectedPoints)
+		}
+		if len(breakdown.Campaigns) != testCase.expectedCampaigns {
+			test.Errorf("Got campaigns %+v, but expected %d to apply",
+				breakdown.Campaigns, testCase.expectedCampaigns)
+		}
+	}
+}
+
+func TestBreakdownReceiptRetailerOverrides(test *testing.T) {
+	targetReceipt := receipt.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items:        []item.Item{{ShortDescription: "Pepsi 12PK", Price: "1.25"}},
+		Total:        "1.25",
+	}
+
+	testCases := []struct {
+		retailer       *retailer_catalog.Retailer
+		expectedBase   int
+		expectedPoints int
+	}{
+		{
+			retailer:       nil,
+			expectedBase:   37,
+			expectedPoints: 37,
+		},
+		{
+			retailer:       &retailer_catalog.Retailer{Id: "retailer-1", CanonicalName: "Target", Multiplier: 2},
+			expectedBase:   37,
+			expectedPoints: 74,
+		},
+		{
+			retailer: &retailer_catalog.Retailer{
+				Id:              "retailer-1",
+				CanonicalName:   "Target",
+				RuleMultipliers: map[string]float64{"retailerName": 0, "multipleOfQuarter": 2},
+			},
+			expectedBase:   56,
+			expectedPoints: 56,
+		},
+	}
+
+	for _, testCase := range testCases {
+		breakdown, err := pc.BreakdownReceipt(targetReceipt, pc.ScoringContext{Retailer: testCase.retailer})
+		if err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if breakdown.BasePoints != testCase.expectedBase || breakdown.Points != testCase.expectedPoints {
+			test.Errorf("Retailer %+v, got %d base and %d total points, but expected %d and %d",
+				testCase.retailer, breakdown.BasePoints, breakdown.Points,
+				testCase.expectedBase, testCase.expectedPoints)
+		}
+	}
+}
+
+func TestBreakdownRuleNames(test *testing.T) {
+	// The catalog only accepts multipliers for rules the breakdown actually has
+	breakdown, err := pc.BreakdownReceipt(receipt.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items:        []item.Item{{ShortDescription: "Pepsi 12PK", Price: "1.25"}},
+		Total:        "1.25",
+	}, pc.ScoringContext{})
+	if err != nil {
+		test.Fatalf("Test failed with error: %v", err)
+	}
+
+	ruleNames := []string{}
+	for _, rulePoints := range breakdown.Rules {
+		ruleNames = append(ruleNames, rulePoints.Rule)
+	}
+	if !reflect.DeepEqual(ruleNames, retailer_catalog.RuleNames) {
+		test.Errorf("Got rules %v, but the retailer catalog expects %v", ruleNames, retailer_catalog.RuleNames)
+	}
+}
+
+func TestBreakdownReceiptStoreTimezone(test *testing.T) {
+	// 19:30 UTC is 14:30 in New York, inside the happy hour window
+	testCases := []struct {
+		timezone       string
+		storeZone      string
+		expectedPoints int
+	}{
+		{timezone: "", storeZone: "", expectedPoints: 31},
+		{timezone: "Z", storeZone: "", expectedPoints: 31},
+		{timezone: "Z", storeZone: "America/New_York", expectedPoints: 41},
+		{timezone: "-05:00", storeZone: "America/New_York", expectedPoints: 31},
+	}
+
+	for _, testCase := range testCases {
+		targetReceipt := receipt.Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-02",
+			PurchaseTime: "19:30",
+			Items:        []item.Item{{ShortDescription: "Pepsi 12PK", Price: "1.25"}},
+			Total:        "1.25",
+			Timezone:     testCase.timezone,
+		}
+		context := pc.ScoringContext{}
+		if testCase.storeZone != "" {
+			context.Retailer = &retailer_catalog.Retailer{Id: "retailer-1", CanonicalName: "Target", Timezone: testCase.storeZone}
+		}
+
+		breakdown, err := pc.BreakdownReceipt(targetReceipt, context)
+		if err != nil {
+			test.Errorf("Test failed with error: %v", err)
+		}
+
+		if breakdown.Points != testCase.expectedPoints {
+			test.Errorf("Zone '%s' in store zone '%s', got %d points, but expected %d",
+				testCase.timezone, testCase.storeZone, breakdown.Points, testCase.expectedPoints)
+		}
+	}
+}
+
+func TestBreakdownReceiptCurrency(test *testing.T) {
+	// 12.50 CAD converts to 10.00 USD, which earns the round dollar and quarter bonuses
+	rates := currency.NewStaticRates("USD", map[string]float64{"CAD": 1.25})
+	testCases := []struct {
+		currency       string
+		rates          currency.RateProvider
+		expectedPoints int
+		expectedValid  bool
+	}{
+		{currency: "", rates: nil, expectedPoints: 31, expectedValid: true},
+		{currency: "CAD", rates: rates, expectedPoints: 81, expectedValid: true},
+		{currency: "CAD", rates: nil, expectedValid: false},
+		{currency: "EUR", rates: rates, expectedValid: false},
+	}
+
+	for _, testCase := range testCases {
+		targetReceipt := receipt.Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-02",
+			PurchaseTime: "13:01",
+			Items:        []item.Item{{ShortDescription: "Pepsi 12PK", Price: "12.50"}},
+			Total:        "12.50",
+			Currency:     testCase.currency,
+		}
+
+		breakdown, err := pc.BreakdownReceipt(targetReceipt, pc.ScoringContext{Rates: testCase.rates})
+		if (err == nil) != testCase.expectedValid {
+			test.Errorf("Currency '%s', got error %v, but expected valid %t", testCase.currency, err, testCase.expectedValid)
+			continue
+		}
+
+		if err == nil && breakdown.Points != testCase.expectedPoints {
+			test.Errorf("Currency '%s', got %d points, but expected %d",
+				testCase.currency, breakdown.Points, testCase.expectedPoints)
+		}
 	}
 }
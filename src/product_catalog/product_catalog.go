@@ -0,0 +1,220 @@
+package receipt_manager
+
+import (
+	"errors"
+	item "receipt_manager/item"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrProductNotFound  = errors.New("product not found")
+	ErrInvalidProduct   = errors.New("product is invalid")
+	ErrItemRuleNotFound = errors.New("item rule not found")
+	ErrInvalidItemRule  = errors.New("item rule is invalid")
+)
+
+type Product struct {
+	Id       string   `json:"id"`
+	Name     string   `json:"name"`
+	Sku      string   `json:"sku,omitempty"`
+	Upc      string   `json:"upc,omitempty"`
+	Category string   `json:"category"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+func (product Product) Matches(receiptItem item.Item) bool {
+	// Identifiers win; otherwise any of the product's keywords in the description is a match
+	if receiptItem.Sku != "" && strings.EqualFold(receiptItem.Sku, product.Sku) {
+		return true
+	}
+	if receiptItem.Upc != "" && receiptItem.Upc == product.Upc {
+		return true
+	}
+	description := strings.ToLower(receiptItem.ShortDescription)
+	for _, keyword := range product.Keywords {
+		if keyword != "" && strings.Contains(description, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+type ItemRule struct {
+	Id            string  `json:"id"`
+	Name          string  `json:"name"`
+	Sku           string  `json:"sku,omitempty"`
+	Category      string  `json:"category,omitempty"`
+	Keyword       string  `json:"keyword,omitempty"`
+	PointsPerItem int     `json:"pointsPerItem,omitempty"`
+	Multiplier    float64 `json:"multiplier,omitempty"`
+}
+
+func (rule ItemRule) Matches(receiptItem item.Item) bool {
+	// Every condition the rule sets has to hold for the item
+	if rule.Sku != "" && !strings.EqualFold(rule.Sku, receiptItem.Sku) {
+		return false
+	}
+	if rule.Category != "" && !strings.EqualFold(rule.Category, receiptItem.Category) {
+		return false
+	}
+	if rule.Keyword != "" && !strings.Contains(strings.ToLower(receiptItem.ShortDescription), strings.ToLower(rule.Keyword)) {
+		return false
+	}
+	return true
+}
+
+func (rule ItemRule) Points(receiptItem item.Item, basePoints int, total string) int {
+	/*
+		Points per item are awarded for every unit. A multiplier scales the share of the
+		base points earned by the item's price, so "2x points on produce" doubles
+		whatever part of the receipt produce paid for
+	*/
+	points := rule.PointsPerItem * receiptItem.Units()
+	if rule.Multiplier > 1 {
+		price, priceErr := strconv.ParseFloat(receiptItem.Price, 64)
+		totalFloat, totalErr := strconv.ParseFloat(total, 64)
+		if priceErr == nil && totalErr == nil && totalFloat > 0 {
+			itemShare := float64(basePoints) * price / totalFloat
+			points += int(itemShare * (rule.Multiplier - 1))
+		}
+	}
+	return points
+}
+
+type Catalog struct {
+	mutex         sync.RWMutex
+	products      []Product
+	rules         []ItemRule
+	lastProductId int
+	lastRuleId    int
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{}
+}
+
+func (catalog *Catalog) Categorize(receiptItem item.Item) item.Item {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	// Fields the submitter supplied are kept; only missing ones are filled from the catalog
+	for _, product := range catalog.products {
+		if !product.Matches(receiptItem) {
+			continue
+		}
+		if receiptItem.Category == "" {
+			receiptItem.Category = product.Category
+		}
+		if receiptItem.Sku == "" {
+			receiptItem.Sku = product.Sku
+		}
+		if receiptItem.Upc == "" {
+			receiptItem.Upc = product.Upc
+		}
+		break
+	}
+	return receiptItem
+}
+
+func (catalog *Catalog) AddProduct(product Product) (Product, error) {
+	if product.Name == "" || product.Category == "" {
+		return Product{}, ErrInvalidProduct
+	}
+	if product.Sku == "" && product.Upc == "" && len(product.Keywords) == 0 {
+		return Product{}, ErrInvalidProduct
+	}
+
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	catalog.lastProductId++
+	product.Id = "product-" + strconv.Itoa(catalog.lastProductId)
+	catalog.products = append(catalog.products, product)
+	return product, nil
+}
+
+func (catalog *Catalog) Products() []Product {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	return append([]Product{}, catalog.products...)
+}
+
+func (catalog *Catalog) Product(id string) (Product, error) {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	for _, product := range catalog.products {
+		if product.Id == id {
+			return product, nil
+		}
+	}
+	return Product{}, ErrProductNotFound
+}
+
+func (catalog *Catalog) DeleteProduct(id string) error {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	for index, product := range catalog.products {
+		if product.Id == id {
+			catalog.products = append(catalog.products[:index:index], catalog.products[index+1:]...)
+			return nil
+		}
+	}
+	return ErrProductNotFound
+}
+
+func (catalog *Catalog) AddRule(rule ItemRule) (ItemRule, error) {
+	if rule.Name == "" || (rule.Sku == "" && rule.Category == "" && rule.Keyword == "") {
+		return ItemRule{}, ErrInvalidItemRule
+	}
+	if rule.PointsPerItem < 0 || (rule.Multiplier != 0 && rule.Multiplier < 1) {
+		return ItemRule{}, ErrInvalidItemRule
+	}
+	if rule.PointsPerItem == 0 && rule.Multiplier == 0 {
+		return ItemRule{}, ErrInvalidItemRule
+	}
+
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	catalog.lastRuleId++
+	rule.Id = "item-rule-" + strconv.Itoa(catalog.lastRuleId)
+	catalog.rules = append(catalog.rules, rule)
+	return rule, nil
+}
+
+func (catalog *Catalog) Rules() []ItemRule {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	return append([]ItemRule{}, catalog.rules...)
+}
+
+func (catalog *Catalog) Rule(id string) (ItemRule, error) {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	for _, rule := range catalog.rules {
+		if rule.Id == id {
+			return rule, nil
+		}
+	}
+	return ItemRule{}, ErrItemRuleNotFound
+}
+
+func (catalog *Catalog) DeleteRule(id string) error {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	for index, rule := range catalog.rules {
+		if rule.Id == id {
+			catalog.rules = append(catalog.rules[:index:index], catalog.rules[index+1:]...)
+			return nil
+		}
+	}
+	return ErrItemRuleNotFound
+}
@@ -0,0 +1,121 @@
+package receipt_manager_test
+
+import (
+	item "receipt_manager/item"
+	pc "receipt_manager/product_catalog"
+	"testing"
+)
+
+func TestCategorize(test *testing.T) {
+	catalog := pc.NewCatalog()
+	catalog.AddProduct(pc.Product{Name: "Gatorade", Sku: "GAT-32", Category: "beverages", Keywords: []string{"gatorade"}})
+	catalog.AddProduct(pc.Product{Name: "Bananas", Upc: "4011", Category: "produce", Keywords: []string{"banana"}})
+
+	testCases := []struct {
+		item             item.Item
+		expectedCategory string
+		expectedSku      string
+	}{
+		{
+			item:             item.Item{ShortDescription: "GATORADE Lemon Lime 32oz", Price: "2.25"},
+			expectedCategory: "beverages",
+			expectedSku:      "GAT-32",
+		},
+		{
+			item:             item.Item{ShortDescription: "Sports drink", Price: "2.25", Sku: "gat-32"},
+			expectedCategory: "beverages",
+			expectedSku:      "gat-32",
+		},
+		{
+			item:             item.Item{ShortDescription: "Organic Bananas", Price: "1.25", Category: "organic"},
+			expectedCategory: "organic",
+			expectedSku:      "",
+		},
+		{
+			item:             item.Item{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			expectedCategory: "",
+			expectedSku:      "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		categorizedItem := catalog.Categorize(testCase.item)
+		if categorizedItem.Category != testCase.expectedCategory || categorizedItem.Sku != testCase.expectedSku {
+			test.Errorf("Item '%s', got category '%s' and SKU '%s', but expected '%s' and '%s'",
+				testCase.item.ShortDescription, categorizedItem.Category, categorizedItem.Sku,
+				testCase.expectedCategory, testCase.expectedSku)
+		}
+	}
+}
+
+func TestItemRulePoints(test *testing.T) {
+	gatorade := item.Item{ShortDescription: "Gatorade 32oz", Price: "2.50", Category: "beverages"}
+	bananas := item.Item{ShortDescription: "Bananas", Price: "7.50", Category: "produce"}
+
+	testCases := []struct {
+		rule           pc.ItemRule
+		item           item.Item
+		expectedMatch  bool
+		expectedPoints int
+	}{
+		{
+			rule:           pc.ItemRule{Keyword: "gatorade", PointsPerItem: 10},
+			item:           gatorade,
+			expectedMatch:  true,
+			expectedPoints: 10,
+		},
+		{
+			rule:           pc.ItemRule{Keyword: "gatorade", PointsPerItem: 10},
+			item:           bananas,
+			expectedMatch:  false,
+			expectedPoints: 10,
+		},
+		{
+			rule:           pc.ItemRule{Category: "Produce", Multiplier: 2},
+			item:           bananas,
+			expectedMatch:  true,
+			expectedPoints: 30,
+		},
+		{
+			rule:           pc.ItemRule{Category: "produce", Keyword: "apple", Multiplier: 2},
+			item:           bananas,
+			expectedMatch:  false,
+			expectedPoints: 30,
+		},
+	}
+
+	for _, testCase := range testCases {
+		matches := testCase.rule.Matches(testCase.item)
+		if matches != testCase.expectedMatch {
+			test.Errorf("Rule %+v on item '%s', match is %t, but expected %t",
+				testCase.rule, testCase.item.ShortDescription, matches, testCase.expectedMatch)
+		}
+
+		// Base points of 40 on a 10.00 total, so each dollar carries 4 points
+		points := testCase.rule.Points(testCase.item, 40, "10.00")
+		if points != testCase.expectedPoints {
+			test.Errorf("Rule %+v on item '%s', got %d points, but expected %d",
+				testCase.rule, testCase.item.ShortDescription, points, testCase.expectedPoints)
+		}
+	}
+}
+
+func TestAddRule(test *testing.T) {
+	testCases := []struct {
+		rule        pc.ItemRule
+		expectedErr error
+	}{
+		{rule: pc.ItemRule{Name: "Gatorade bonus", Keyword: "gatorade", PointsPerItem: 10}, expectedErr: nil},
+		{rule: pc.ItemRule{Name: "Produce", Category: "produce", Multiplier: 2}, expectedErr: nil},
+		{rule: pc.ItemRule{Name: "Everything", PointsPerItem: 10}, expectedErr: pc.ErrInvalidItemRule},
+		{rule: pc.ItemRule{Name: "Nothing", Category: "produce"}, expectedErr: pc.ErrInvalidItemRule},
+		{rule: pc.ItemRule{Name: "Halved", Category: "produce", Multiplier: 0.5}, expectedErr: pc.ErrInvalidItemRule},
+	}
+
+	for _, testCase := range testCases {
+		_, err := pc.NewCatalog().AddRule(testCase.rule)
+		if err != testCase.expectedErr {
+			test.Errorf("Rule %+v, got error %v, but expected %v", testCase.rule, err, testCase.expectedErr)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	product_catalog "receipt_manager/product_catalog"
+	response_handler "receipt_manager/response_handler"
+
+	"github.com/gorilla/mux"
+)
+
+func productsHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		response_handler.SendProductsResponse(productCatalog.Products(), response)
+	case http.MethodPost:
+		product := product_catalog.Product{}
+		decoderError := json.NewDecoder(request.Body).Decode(&product)
+		if decoderError != nil {
+			response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Product data decoding failed")
+			return
+		}
+		storedProduct, catalogError := productCatalog.AddProduct(product)
+		if catalogError != nil {
+			handleProductCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendProductResponse(storedProduct, response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func productHandler(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	switch request.Method {
+	case http.MethodGet:
+		product, catalogError := productCatalog.Product(id)
+		if catalogError != nil {
+			handleProductCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendProductResponse(product, response)
+	case http.MethodDelete:
+		catalogError := productCatalog.DeleteProduct(id)
+		if catalogError != nil {
+			handleProductCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendNoContentResponse(response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func itemRulesHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		response_handler.SendItemRulesResponse(productCatalog.Rules(), response)
+	case http.MethodPost:
+		rule := product_catalog.ItemRule{}
+		decoderError := json.NewDecoder(request.Body).Decode(&rule)
+		if decoderError != nil {
+			response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Item rule data decoding failed")
+			return
+		}
+		storedRule, catalogError := productCatalog.AddRule(rule)
+		if catalogError != nil {
+			handleProductCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendItemRuleResponse(storedRule, response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func itemRuleHandler(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	switch request.Method {
+	case http.MethodGet:
+		rule, catalogError := productCatalog.Rule(id)
+		if catalogError != nil {
+			handleProductCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendItemRuleResponse(rule, response)
+	case http.MethodDelete:
+		catalogError := productCatalog.DeleteRule(id)
+		if catalogError != nil {
+			handleProductCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendNoContentResponse(response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func handleProductCatalogError(response http.ResponseWriter, catalogError error) {
+	switch catalogError {
+	case product_catalog.ErrProductNotFound:
+		response_handler.HandleNotFoundError(response, response_handler.ErrorCodeProductNotFound, "The requested product doesn't exist")
+	case product_catalog.ErrItemRuleNotFound:
+		response_handler.HandleNotFoundError(response, response_handler.ErrorCodeItemRuleNotFound, "The requested item rule doesn't exist")
+	case product_catalog.ErrInvalidProduct:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidProduct, "Product needs a name, a category and a SKU, UPC or keywords")
+	case product_catalog.ErrInvalidItemRule:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidItemRule, "Item rule needs a name, a SKU, category or keyword, and points per item or a multiplier")
+	default:
+		response_handler.HandleInternalServerError(response)
+	}
+}
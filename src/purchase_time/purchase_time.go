@@ -0,0 +1,86 @@
+package receipt_manager
+
+import (
+	"errors"
+	receipt "receipt_manager/receipt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidZone = errors.New("timezone is neither an IANA name nor a UTC offset")
+
+var offsetPattern = regexp.MustCompile(`^([+-])(\d{2}):(\d{2})$`)
+
+func ParseZone(zone string) (*time.Location, error) {
+	// Accepts IANA names such as "America/Chicago", "UTC"/"Z", or offsets such as "-05:00"
+	if zone == "Z" {
+		return time.UTC, nil
+	}
+
+	offsetParts := offsetPattern.FindStringSubmatch(zone)
+	if offsetParts != nil {
+		hours, _ := strconv.Atoi(offsetParts[2])
+		minutes, _ := strconv.Atoi(offsetParts[3])
+		if hours > 14 || minutes > 59 {
+			return nil, ErrInvalidZone
+		}
+		offsetSeconds := (hours*60 + minutes) * 60
+		if offsetParts[1] == "-" {
+			offsetSeconds = -offsetSeconds
+		}
+		return time.FixedZone("UTC"+zone, offsetSeconds), nil
+	}
+
+	if zone == "" || zone == "Local" {
+		return nil, ErrInvalidZone
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, ErrInvalidZone
+	}
+	return location, nil
+}
+
+func Timestamp(receipt receipt.Receipt, defaultZone string) (time.Time, error) {
+	// The printed date and time are read in the receipt's own zone, falling back to the store's
+	zone := receipt.Timezone
+	if zone == "" {
+		zone = defaultZone
+	}
+	location, err := ParseZone(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	timestamp, err := time.ParseInLocation("2006-01-02 15:04", receipt.PurchaseDate+" "+receipt.PurchaseTime, location)
+	if err != nil {
+		return time.Time{}, errors.New("time.ParseInLocation() in Timestamp() failed")
+	}
+	return timestamp, nil
+}
+
+func Localize(receipt receipt.Receipt, storeZone string) (receipt.Receipt, error) {
+	/*
+		A receipt whose time was given in a zone other than the store's has its
+		purchase date and time rewritten to the store's local clock
+	*/
+	if receipt.Timezone == "" || storeZone == "" || receipt.Timezone == storeZone {
+		return receipt, nil
+	}
+
+	timestamp, err := Timestamp(receipt, "")
+	if err != nil {
+		return receipt, err
+	}
+	storeLocation, err := ParseZone(storeZone)
+	if err != nil {
+		return receipt, err
+	}
+
+	localTimestamp := timestamp.In(storeLocation)
+	receipt.PurchaseDate = localTimestamp.Format("2006-01-02")
+	receipt.PurchaseTime = localTimestamp.Format("15:04")
+	receipt.Timezone = storeZone
+	return receipt, nil
+}
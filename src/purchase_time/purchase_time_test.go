@@ -0,0 +1,100 @@
+package receipt_manager_test
+
+import (
+	pt "receipt_manager/purchase_time"
+	receipt "receipt_manager/receipt"
+	"testing"
+	"time"
+)
+
+func TestParseZone(test *testing.T) {
+	testCases := []struct {
+		zone          string
+		expectedValid bool
+	}{
+		{zone: "America/Chicago", expectedValid: true},
+		{zone: "UTC", expectedValid: true},
+		{zone: "Z", expectedValid: true},
+		{zone: "-05:00", expectedValid: true},
+		{zone: "+05:30", expectedValid: true},
+		{zone: "+15:00", expectedValid: false},
+		{zone: "-05:60", expectedValid: false},
+		{zone: "Mars/Olympus_Mons", expectedValid: false},
+		{zone: "Local", expectedValid: false},
+		{zone: "", expectedValid: false},
+	}
+
+	for _, testCase := range testCases {
+		_, err := pt.ParseZone(testCase.zone)
+		if (err == nil) != testCase.expectedValid {
+			test.Errorf("For zone '%s', got error %v, but expected valid %t", testCase.zone, err, testCase.expectedValid)
+		}
+	}
+}
+
+func TestTimestamp(test *testing.T) {
+	testCases := []struct {
+		timezone          string
+		defaultZone       string
+		expectedTimestamp time.Time
+	}{
+		{
+			timezone:          "-05:00",
+			defaultZone:       "",
+			expectedTimestamp: time.Date(2022, time.March, 20, 19, 33, 0, 0, time.UTC),
+		},
+		{
+			timezone:          "",
+			defaultZone:       "America/Los_Angeles",
+			expectedTimestamp: time.Date(2022, time.March, 20, 21, 33, 0, 0, time.UTC),
+		},
+		{
+			timezone:          "Z",
+			defaultZone:       "America/Los_Angeles",
+			expectedTimestamp: time.Date(2022, time.March, 20, 14, 33, 0, 0, time.UTC),
+		},
+	}
+
+	for _, testCase := range testCases {
+		testReceipt := receipt.Receipt{PurchaseDate: "2022-03-20", PurchaseTime: "14:33", Timezone: testCase.timezone}
+		timestamp, err := pt.Timestamp(testReceipt, testCase.defaultZone)
+		if err != nil {
+			test.Fatalf("Test failed with error: %v", err)
+		}
+		if !timestamp.Equal(testCase.expectedTimestamp) {
+			test.Errorf("For zone '%s', got %v, but expected %v", testCase.timezone, timestamp, testCase.expectedTimestamp)
+		}
+	}
+
+	if _, err := pt.Timestamp(receipt.Receipt{PurchaseDate: "2022-03-20", PurchaseTime: "14:33"}, ""); err == nil {
+		test.Errorf("Expected a receipt with no zone at all to fail")
+	}
+}
+
+func TestLocalize(test *testing.T) {
+	testCases := []struct {
+		timezone     string
+		storeZone    string
+		expectedDate string
+		expectedTime string
+	}{
+		// Submitted in UTC, the store in New York saw it before 2pm
+		{timezone: "Z", storeZone: "America/New_York", expectedDate: "2022-03-20", expectedTime: "10:33"},
+		// Crossing midnight moves the purchase date too
+		{timezone: "+14:00", storeZone: "America/Chicago", expectedDate: "2022-03-19", expectedTime: "19:33"},
+		{timezone: "", storeZone: "America/Chicago", expectedDate: "2022-03-20", expectedTime: "14:33"},
+		{timezone: "-05:00", storeZone: "", expectedDate: "2022-03-20", expectedTime: "14:33"},
+	}
+
+	for _, testCase := range testCases {
+		testReceipt := receipt.Receipt{PurchaseDate: "2022-03-20", PurchaseTime: "14:33", Timezone: testCase.timezone}
+		localReceipt, err := pt.Localize(testReceipt, testCase.storeZone)
+		if err != nil {
+			test.Fatalf("Test failed with error: %v", err)
+		}
+		if localReceipt.PurchaseDate != testCase.expectedDate || localReceipt.PurchaseTime != testCase.expectedTime {
+			test.Errorf("For zone '%s' in store zone '%s', got %s %s, but expected %s %s", testCase.timezone, testCase.storeZone,
+				localReceipt.PurchaseDate, localReceipt.PurchaseTime, testCase.expectedDate, testCase.expectedTime)
+		}
+	}
+}
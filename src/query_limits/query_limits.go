@@ -0,0 +1,118 @@
+package receipt_manager
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+var (
+	ErrQueryTooDeep    = errors.New("query is too deep")
+	ErrQueryTooComplex = errors.New("query is too complex")
+)
+
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+	// The page size assumed for a list field queried without a first argument, by field name
+	PageSizes map[string]int
+}
+
+func Check(document *ast.Document, operationName string, variables map[string]interface{}, limits Limits) error {
+	/*
+		Measures the operation that will run before it runs. Depth counts nested field
+		selections, and complexity counts every field that could resolve, multiplying
+		a list field's selections by how many items it may return. Introspection
+		fields count like any other, so they can't be nested past the limits either
+	*/
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	measure := measurer{fragments: fragments, variables: variables, pageSizes: limits.PageSizes}
+	depth, complexity := measure.selections(operation.SelectionSet, map[string]bool{})
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return fmt.Errorf("%w: depth %d exceeds the limit of %d", ErrQueryTooDeep, depth, limits.MaxDepth)
+	}
+	if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+		return fmt.Errorf("%w: complexity %d exceeds the limit of %d", ErrQueryTooComplex, complexity, limits.MaxComplexity)
+	}
+	return nil
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	pageSizes map[string]int
+}
+
+func (measure measurer) selections(selectionSet *ast.SelectionSet, spreading map[string]bool) (int, int) {
+	// Fragments count as if written inline; spreading guards against a fragment that includes itself
+	if selectionSet == nil {
+		return 0, 0
+	}
+	depth, complexity := 0, 0
+	for _, selection := range selectionSet.Selections {
+		selectionDepth, selectionComplexity := 0, 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			childDepth, childComplexity := measure.selections(selection.SelectionSet, spreading)
+			selectionDepth = childDepth + 1
+			selectionComplexity = 1 + measure.pageSize(selection)*childComplexity
+		case *ast.InlineFragment:
+			selectionDepth, selectionComplexity = measure.selections(selection.SelectionSet, spreading)
+		case *ast.FragmentSpread:
+			fragment := measure.fragments[selection.Name.Value]
+			if fragment == nil || spreading[fragment.Name.Value] {
+				continue
+			}
+			spreading[fragment.Name.Value] = true
+			selectionDepth, selectionComplexity = measure.selections(fragment.SelectionSet, spreading)
+			delete(spreading, fragment.Name.Value)
+		}
+		if selectionDepth > depth {
+			depth = selectionDepth
+		}
+		complexity += selectionComplexity
+	}
+	return depth, complexity
+}
+
+func (measure measurer) pageSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if size, err := strconv.Atoi(value.Value); err == nil && size > 0 {
+				return size
+			}
+		case *ast.Variable:
+			if size, isNumber := measure.variables[value.Name.Value].(float64); isNumber && size > 0 {
+				return int(size)
+			}
+			if size, isInt := measure.variables[value.Name.Value].(int); isInt && size > 0 {
+				return size
+			}
+		}
+	}
+	if size, hasPageSize := measure.pageSizes[field.Name.Value]; hasPageSize {
+		return size
+	}
+	return 1
+}
@@ -0,0 +1,43 @@
+package receipt_manager_test
+
+import (
+	"errors"
+	query_limits "receipt_manager/query_limits"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+func TestCheck(test *testing.T) {
+	limits := query_limits.Limits{MaxDepth: 3, MaxComplexity: 30, PageSizes: map[string]int{"receipts": 10}}
+
+	testCases := []struct {
+		name          string
+		query         string
+		operationName string
+		variables     map[string]interface{}
+		expectedError error
+	}{
+		{name: "shallow", query: `{ receipt(id: "a") { id items { price } } }`},
+		{name: "too deep", query: `{ receipt(id: "a") { account { receipts(first: 1) { id } } } }`, expectedError: query_limits.ErrQueryTooDeep},
+		{name: "too deep through a fragment", query: `{ receipt(id: "a") { ...withAccount } } fragment withAccount on Receipt { account { receipts(first: 1) { id } } }`, expectedError: query_limits.ErrQueryTooDeep},
+		{name: "within the assumed page size", query: `{ receipts { id points } }`},
+		{name: "assumed page size too complex", query: `{ receipts { id points total } }`, expectedError: query_limits.ErrQueryTooComplex},
+		{name: "small first", query: `{ receipts(first: 2) { id points total } }`},
+		{name: "first too complex", query: `{ receipts(first: 50) { id } }`, expectedError: query_limits.ErrQueryTooComplex},
+		{name: "first from a variable", query: `query Q($first: Int) { receipts(first: $first) { id } }`, variables: map[string]interface{}{"first": float64(50)}, expectedError: query_limits.ErrQueryTooComplex},
+		{name: "introspection is counted", query: `{ __schema { types { fields { type { ofType { ofType { name } } } } } } }`, expectedError: query_limits.ErrQueryTooDeep},
+		{name: "only the named operation", query: `query Small { receipt(id: "a") { id } } query Big { receipts(first: 50) { id } }`, operationName: "Small"},
+	}
+
+	for _, testCase := range testCases {
+		document, err := parser.Parse(parser.ParseParams{Source: testCase.query})
+		if err != nil {
+			test.Fatalf("Case '%s', parsing failed with error: %v", testCase.name, err)
+		}
+		err = query_limits.Check(document, testCase.operationName, testCase.variables, limits)
+		if !errors.Is(err, testCase.expectedError) {
+			test.Errorf("Case '%s', got error %v, but expected %v", testCase.name, err, testCase.expectedError)
+		}
+	}
+}
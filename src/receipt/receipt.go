@@ -3,9 +3,12 @@ package receipt_manager
 import item "receipt_manager/item"
 
 type Receipt struct {
-	Retailer      string       `json:"retailer"`
-	PurchaseDate  string       `json:"purchaseDate"`
-	PurchaseTime  string       `json:"purchaseTime"`
-	Items         []item.Item  `json:"items"`
-	Total         string       `json:"total"`
+	Retailer     string      `json:"retailer"                xml:"retailer"`
+	PurchaseDate string      `json:"purchaseDate"            xml:"purchaseDate"`
+	PurchaseTime string      `json:"purchaseTime"            xml:"purchaseTime"`
+	Items        []item.Item `json:"items"                   xml:"items>item"`
+	Total        string      `json:"total"                   xml:"total"`
+	AccountId    string      `json:"accountId,omitempty"     xml:"accountId,omitempty"`
+	Timezone     string      `json:"timezone,omitempty"      xml:"timezone,omitempty"`
+	Currency     string      `json:"currency,omitempty"      xml:"currency,omitempty"`
 }
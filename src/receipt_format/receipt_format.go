@@ -0,0 +1,208 @@
+package receipt_manager
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	item "receipt_manager/item"
+	receipt "receipt_manager/receipt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("receipt media type is not supported")
+	ErrMalformedReceipt     = errors.New("receipt data could not be decoded")
+	ErrBodyTooLarge         = errors.New("body exceeds the size limit")
+	ErrUnknownField         = errors.New("unknown field")
+	ErrWrongFieldType       = errors.New("wrong type for field")
+	ErrTrailingData         = errors.New("unexpected data after the receipt")
+	ErrTooManyItems         = errors.New("too many items")
+)
+
+type Limits struct {
+	MaxBodyBytes int64
+	MaxItems     int
+}
+
+func DecodeReceipt(contentType string, body io.Reader, limits Limits) (receipt.Receipt, error) {
+	mediaType, err := receiptMediaType(contentType)
+	if err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	decodedReceipt := receipt.Receipt{}
+	switch mediaType {
+	case "application/json":
+		err = DecodeJSON(body, &decodedReceipt)
+	case "application/xml", "text/xml":
+		err = xml.NewDecoder(body).Decode(&decodedReceipt)
+	case "text/csv":
+		decodedReceipt, err = decodeCSV(body)
+	}
+	if err != nil {
+		return receipt.Receipt{}, decodeError(err)
+	}
+	return decodedReceipt, CheckLimits(decodedReceipt, limits)
+}
+
+func Supported(contentType string) bool {
+	_, err := receiptMediaType(contentType)
+	return err == nil
+}
+
+func receiptMediaType(contentType string) (string, error) {
+	// A request without a content type is taken to be JSON, as before formats were negotiated
+	if contentType == "" {
+		return "application/json", nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedMediaType
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		return "application/json", nil
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "text/xml", "text/csv":
+		return mediaType, nil
+	}
+	return "", ErrUnsupportedMediaType
+}
+
+func DecodeJSON(body io.Reader, target interface{}) error {
+	/*
+		Receipts are decoded strictly: a misspelled field is an error rather than
+		silently dropped, and the body must hold exactly one JSON value
+	*/
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return decodeError(err)
+	}
+
+	err := decoder.Decode(&struct{}{})
+	if err == io.EOF {
+		return nil
+	}
+	if errors.Is(decodeError(err), ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+	return ErrTrailingData
+}
+
+func CheckLimits(checkedReceipt receipt.Receipt, limits Limits) error {
+	if limits.MaxItems > 0 && len(checkedReceipt.Items) > limits.MaxItems {
+		return fmt.Errorf("%w, the limit is %d", ErrTooManyItems, limits.MaxItems)
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	// Each failure is reported distinctly so clients can tell how to fix the payload
+	var maxBytesError *http.MaxBytesError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesError):
+		return ErrBodyTooLarge
+	case errors.As(err, &typeError):
+		return fmt.Errorf("%w %q", ErrWrongFieldType, typeError.Field)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("%w %s", ErrUnknownField, strings.TrimPrefix(err.Error(), "json: unknown field "))
+	case errors.Is(err, ErrUnknownField), errors.Is(err, ErrWrongFieldType),
+		errors.Is(err, ErrTrailingData), errors.Is(err, ErrBodyTooLarge):
+		return err
+	}
+	return ErrMalformedReceipt
+}
+
+func decodeCSV(body io.Reader) (receipt.Receipt, error) {
+	/*
+		CSV exports have a header row naming the JSON fields and one row per item.
+		Receipt fields are repeated on every row and must agree, so a file can't
+		quietly merge two receipts
+	*/
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return receipt.Receipt{}, err
+	}
+	if len(rows) < 2 {
+		return receipt.Receipt{}, ErrMalformedReceipt
+	}
+
+	header := rows[0]
+	decodedReceipt := receipt.Receipt{Items: []item.Item{}}
+	for rowIndex, row := range rows[1:] {
+		rowReceipt := receipt.Receipt{}
+		rowItem := item.Item{}
+		for columnIndex, column := range header {
+			if err := setColumn(&rowReceipt, &rowItem, column, strings.TrimSpace(row[columnIndex])); err != nil {
+				return receipt.Receipt{}, err
+			}
+		}
+
+		if rowIndex == 0 {
+			decodedReceipt = rowReceipt
+			decodedReceipt.Items = []item.Item{}
+		} else if !reflect.DeepEqual(rowReceipt, receiptFields(decodedReceipt)) {
+			return receipt.Receipt{}, ErrMalformedReceipt
+		}
+		decodedReceipt.Items = append(decodedReceipt.Items, rowItem)
+	}
+	return decodedReceipt, nil
+}
+
+func setColumn(rowReceipt *receipt.Receipt, rowItem *item.Item, column string, value string) error {
+	switch column {
+	case "retailer":
+		rowReceipt.Retailer = value
+	case "purchaseDate":
+		rowReceipt.PurchaseDate = value
+	case "purchaseTime":
+		rowReceipt.PurchaseTime = value
+	case "total":
+		rowReceipt.Total = value
+	case "accountId":
+		rowReceipt.AccountId = value
+	case "timezone":
+		rowReceipt.Timezone = value
+	case "currency":
+		rowReceipt.Currency = value
+	case "shortDescription":
+		rowItem.ShortDescription = value
+	case "price":
+		rowItem.Price = value
+	case "quantity":
+		if value != "" {
+			quantity, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%w %q", ErrWrongFieldType, column)
+			}
+			rowItem.Quantity = quantity
+		}
+	case "unitPrice":
+		rowItem.UnitPrice = value
+	case "sku":
+		rowItem.Sku = value
+	case "upc":
+		rowItem.Upc = value
+	case "category":
+		rowItem.Category = value
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownField, column)
+	}
+	return nil
+}
+
+func receiptFields(fullReceipt receipt.Receipt) receipt.Receipt {
+	fullReceipt.Items = nil
+	return fullReceipt
+}
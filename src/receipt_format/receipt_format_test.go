@@ -0,0 +1,151 @@
+package receipt_manager_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	item "receipt_manager/item"
+	receipt "receipt_manager/receipt"
+	rf "receipt_manager/receipt_format"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeReceipt(test *testing.T) {
+	expectedReceipt := receipt.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []item.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Gatorade", Price: "4.50", Quantity: 2, UnitPrice: "2.25"},
+		},
+		Total: "10.99",
+	}
+
+	testCases := []struct {
+		contentType string
+		body        string
+	}{
+		{
+			contentType: "",
+			body: `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "10.99",
+				"items": [{"shortDescription": "Mountain Dew 12PK", "price": "6.49"},
+					{"shortDescription": "Gatorade", "price": "4.50", "quantity": 2, "unitPrice": "2.25"}]}`,
+		},
+		{
+			contentType: "application/xml; charset=utf-8",
+			body: `<receipt>
+				<retailer>Target</retailer>
+				<purchaseDate>2022-01-01</purchaseDate>
+				<purchaseTime>13:01</purchaseTime>
+				<items>
+					<item><shortDescription>Mountain Dew 12PK</shortDescription><price>6.49</price></item>
+					<item><shortDescription>Gatorade</shortDescription><price>4.50</price><quantity>2</quantity><unitPrice>2.25</unitPrice></item>
+				</items>
+				<total>10.99</total>
+			</receipt>`,
+		},
+		{
+			contentType: "text/csv",
+			body: "retailer,purchaseDate,purchaseTime,total,shortDescription,price,quantity,unitPrice\n" +
+				"Target,2022-01-01,13:01,10.99,Mountain Dew 12PK,6.49,,\n" +
+				"Target,2022-01-01,13:01,10.99,Gatorade,4.50,2,2.25\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		decodedReceipt, err := rf.DecodeReceipt(testCase.contentType, strings.NewReader(testCase.body), rf.Limits{})
+		if err != nil {
+			test.Errorf("Content type '%s' failed with error: %v", testCase.contentType, err)
+		}
+
+		if !reflect.DeepEqual(decodedReceipt, expectedReceipt) {
+			test.Errorf("Content type '%s', got %+v, but expected %+v", testCase.contentType, decodedReceipt, expectedReceipt)
+		}
+	}
+}
+
+func TestDecodeReceiptErrors(test *testing.T) {
+	testCases := []struct {
+		contentType string
+		body        string
+		expectedErr error
+	}{
+		{
+			contentType: "application/x-yaml",
+			body:        "retailer: Target",
+			expectedErr: rf.ErrUnsupportedMediaType,
+		},
+		{
+			contentType: "text/csv",
+			body:        "retailer,shortDescription,price\nTarget,Gatorade,2.25\nWalmart,Gatorade,2.25\n",
+			expectedErr: rf.ErrMalformedReceipt, // Rows disagree on the retailer
+		},
+		{
+			contentType: "text/csv",
+			body:        "retailer,description,price\nTarget,Gatorade,2.25\n",
+			expectedErr: rf.ErrUnknownField,
+		},
+		{
+			contentType: "text/csv",
+			body:        "shortDescription,price,quantity\nGatorade,2.25,two\n",
+			expectedErr: rf.ErrWrongFieldType,
+		},
+		{
+			contentType: "application/json",
+			body:        `{"retailer": "Target", "store": "1284"}`,
+			expectedErr: rf.ErrUnknownField,
+		},
+		{
+			contentType: "application/json",
+			body:        `{"retailer": "Target", "items": [{"shortDescription": "Gatorade", "quantity": "2"}]}`,
+			expectedErr: rf.ErrWrongFieldType,
+		},
+		{
+			contentType: "application/json",
+			body:        `{"retailer": "Target"} {"retailer": "Walmart"}`,
+			expectedErr: rf.ErrTrailingData,
+		},
+		{
+			contentType: "application/json",
+			body:        `{"retailer": "Target"`,
+			expectedErr: rf.ErrMalformedReceipt,
+		},
+		{
+			contentType: "application/json",
+			body:        `{"items": [{"shortDescription": "A"}, {"shortDescription": "B"}, {"shortDescription": "C"}]}`,
+			expectedErr: rf.ErrTooManyItems,
+		},
+		{
+			contentType: "text/csv",
+			body:        "retailer,shortDescription,price\n",
+			expectedErr: rf.ErrMalformedReceipt, // No item rows
+		},
+		{
+			contentType: "application/xml",
+			body:        "<receipt><retailer>Target</retailer>",
+			expectedErr: rf.ErrMalformedReceipt,
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := rf.DecodeReceipt(testCase.contentType, strings.NewReader(testCase.body), rf.Limits{MaxItems: 2})
+		if !errors.Is(err, testCase.expectedErr) {
+			test.Errorf("Content type '%s', got error %v, but expected %v", testCase.contentType, err, testCase.expectedErr)
+		}
+	}
+}
+
+func TestDecodeReceiptBodyLimit(test *testing.T) {
+	body := `{"retailer": "` + strings.Repeat("A", 100) + `"}`
+	recorder := httptest.NewRecorder()
+	limitedBody := http.MaxBytesReader(recorder, io.NopCloser(strings.NewReader(body)), 64)
+
+	_, err := rf.DecodeReceipt("application/json", limitedBody, rf.Limits{MaxBodyBytes: 64})
+	if !errors.Is(err, rf.ErrBodyTooLarge) {
+		test.Errorf("Body of %d bytes, got error %v, but expected %v", len(body), err, rf.ErrBodyTooLarge)
+	}
+}
@@ -0,0 +1,3 @@
+package receipt_manager
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative receipts.proto
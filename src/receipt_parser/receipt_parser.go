@@ -0,0 +1,214 @@
+package receipt_manager
+
+import (
+	"fmt"
+	item "receipt_manager/item"
+	receipt "receipt_manager/receipt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Draft struct {
+	Receipt       receipt.Receipt    `json:"receipt"`
+	Confidence    map[string]float64 `json:"confidence"`
+	UnparsedLines []string           `json:"unparsedLines"`
+}
+
+var (
+	isoDatePattern   = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	slashDatePattern = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4}|\d{2})\b`)
+	timePattern      = regexp.MustCompile(`\b(\d{1,2}):(\d{2})(?::\d{2})?\s*([AaPp][Mm])?\b`)
+	amountPattern    = regexp.MustCompile(`\$?(\d+)[.,](\d{2})\s*[A-Z]?$`)
+	quantityPattern  = regexp.MustCompile(`^(\d+)\s*[xX@]\s+`)
+	totalPattern     = regexp.MustCompile(`(?i)^(grand\s+)?total\b`)
+	subtotalPattern  = regexp.MustCompile(`(?i)^sub\s*-?\s*total\b`)
+	// Payment, tax and change lines carry amounts but aren't items
+	nonItemPattern = regexp.MustCompile(`(?i)\b(tax|change|cash|visa|mastercard|amex|debit|credit|tender|balance|due|savings|discount)\b`)
+	// Greetings and slogans are printed like a header but never name the retailer
+	footerPattern = regexp.MustCompile(`(?i)\b(thank\s*you|thanks|come\s+again|have\s+a\s+(nice|good|great)\s+day|customer\s+copy)\b`)
+)
+
+func Parse(text string) Draft {
+	/*
+		OCR text is read top to bottom: the first line with letters and no amount is
+		the retailer, the first date and time found are the purchase date and time,
+		lines ending in an amount are items until the TOTAL line
+	*/
+	draft := Draft{
+		Confidence:    map[string]float64{"retailer": 0, "purchaseDate": 0, "purchaseTime": 0, "items": 0, "total": 0},
+		UnparsedLines: []string{},
+	}
+	draft.Receipt.Items = []item.Item{}
+
+	subtotal := ""
+	totalSeen := false
+	for index, rawLine := range strings.Split(text, "\n") {
+		line := strings.Join(strings.Fields(rawLine), " ")
+		if line == "" {
+			continue
+		}
+
+		lineUsed := false
+		if draft.Receipt.PurchaseDate == "" {
+			lineUsed = parseDate(line, &draft) || lineUsed
+		}
+		if draft.Receipt.PurchaseTime == "" {
+			lineUsed = parseTime(line, &draft) || lineUsed
+		}
+		if lineUsed {
+			continue
+		}
+
+		amount, description, hasAmount := splitAmount(line)
+		switch {
+		case !hasAmount && draft.Receipt.Retailer == "" && hasLetters(line) && !footerPattern.MatchString(line):
+			draft.Receipt.Retailer = cleanName(line, "-&'’.")
+			// A retailer further down than the header is more likely a slogan or address
+			draft.Confidence["retailer"] = 0.9
+			if index > 2 {
+				draft.Confidence["retailer"] = 0.5
+			}
+		case hasAmount && subtotalPattern.MatchString(description):
+			subtotal = amount
+		case hasAmount && totalPattern.MatchString(description) && !totalSeen:
+			draft.Receipt.Total = amount
+			draft.Confidence["total"] = 0.9
+			totalSeen = true
+		case hasAmount && !totalSeen && !nonItemPattern.MatchString(description) && hasLetters(description):
+			draft.Receipt.Items = append(draft.Receipt.Items, parseItem(description, amount))
+		default:
+			draft.UnparsedLines = append(draft.UnparsedLines, line)
+		}
+	}
+
+	scoreItems(&draft, subtotal)
+	return draft
+}
+
+func parseDate(line string, draft *Draft) bool {
+	// Dates that aren't on the calendar, such as 02/31, are misreads and left for a later line
+	if dateParts := isoDatePattern.FindStringSubmatch(line); dateParts != nil {
+		purchaseDate := dateParts[1] + "-" + dateParts[2] + "-" + dateParts[3]
+		if _, err := time.Parse("2006-01-02", purchaseDate); err != nil {
+			return false
+		}
+		draft.Receipt.PurchaseDate = purchaseDate
+		draft.Confidence["purchaseDate"] = 0.95
+		return true
+	}
+
+	// Slash dates are read as US month/day/year, which is less certain, and two-digit years more so
+	dateParts := slashDatePattern.FindStringSubmatch(line)
+	if dateParts == nil {
+		return false
+	}
+	month, _ := strconv.Atoi(dateParts[1])
+	day, _ := strconv.Atoi(dateParts[2])
+	year, _ := strconv.Atoi(dateParts[3])
+	if len(dateParts[3]) == 2 {
+		year += 2000
+	}
+	purchaseDate := fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	if _, err := time.Parse("2006-01-02", purchaseDate); err != nil {
+		return false
+	}
+	draft.Confidence["purchaseDate"] = 0.8
+	if len(dateParts[3]) == 2 {
+		draft.Confidence["purchaseDate"] = 0.7
+	}
+	draft.Receipt.PurchaseDate = purchaseDate
+	return true
+}
+
+func parseTime(line string, draft *Draft) bool {
+	timeParts := timePattern.FindStringSubmatch(line)
+	if timeParts == nil {
+		return false
+	}
+	hours, _ := strconv.Atoi(timeParts[1])
+	minutes, _ := strconv.Atoi(timeParts[2])
+	meridiem := strings.ToLower(timeParts[3])
+	if minutes > 59 || hours > 23 || (meridiem != "" && (hours < 1 || hours > 12)) {
+		return false
+	}
+
+	draft.Confidence["purchaseTime"] = 0.85
+	if meridiem != "" {
+		hours %= 12
+		if meridiem == "pm" {
+			hours += 12
+		}
+		draft.Confidence["purchaseTime"] = 0.9
+	}
+	draft.Receipt.PurchaseTime = fmt.Sprintf("%02d:%02d", hours, minutes)
+	return true
+}
+
+func splitAmount(line string) (string, string, bool) {
+	// OCR reads decimal commas as often as points, so both are accepted
+	amountParts := amountPattern.FindStringSubmatchIndex(line)
+	if amountParts == nil {
+		return "", line, false
+	}
+	amount := line[amountParts[2]:amountParts[3]] + "." + line[amountParts[4]:amountParts[5]]
+	return amount, strings.TrimSpace(line[:amountParts[0]]), true
+}
+
+func parseItem(description string, price string) item.Item {
+	parsedItem := item.Item{Price: price}
+	if quantityParts := quantityPattern.FindStringSubmatch(description); quantityParts != nil {
+		quantity, _ := strconv.Atoi(quantityParts[1])
+		if quantity > 1 {
+			parsedItem.Quantity = quantity
+		}
+		description = description[len(quantityParts[0]):]
+	}
+	parsedItem.ShortDescription = cleanName(description, "-&'’./%")
+	return parsedItem
+}
+
+func scoreItems(draft *Draft, subtotal string) {
+	/*
+		Items are only as trustworthy as their sum: matching the subtotal or total
+		means no line was dropped or misread
+	*/
+	if len(draft.Receipt.Items) == 0 {
+		return
+	}
+	itemCents := 0
+	for _, parsedItem := range draft.Receipt.Items {
+		itemCents += amountCents(parsedItem.Price)
+	}
+
+	draft.Confidence["items"] = 0.6
+	if subtotal != "" && itemCents == amountCents(subtotal) {
+		draft.Confidence["items"] = 0.95
+	}
+	if draft.Receipt.Total != "" && itemCents == amountCents(draft.Receipt.Total) {
+		draft.Confidence["items"] = 0.95
+		draft.Confidence["total"] = 1
+	}
+}
+
+func amountCents(amount string) int {
+	cents, _ := strconv.Atoi(strings.Replace(amount, ".", "", 1))
+	return cents
+}
+
+func hasLetters(line string) bool {
+	return strings.IndexFunc(line, unicode.IsLetter) >= 0
+}
+
+func cleanName(name string, allowedPunctuation string) string {
+	// Drops OCR noise such as "*" and "#" that the name validation would reject
+	cleanedName := strings.Map(func(char rune) rune {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) || unicode.IsSpace(char) || strings.ContainsRune(allowedPunctuation, char) {
+			return char
+		}
+		return ' '
+	}, name)
+	return strings.Join(strings.Fields(cleanedName), " ")
+}
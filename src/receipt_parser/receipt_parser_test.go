@@ -0,0 +1,97 @@
+package receipt_manager_test
+
+import (
+	"os"
+	item "receipt_manager/item"
+	receipt "receipt_manager/receipt"
+	rp "receipt_manager/receipt_parser"
+	"reflect"
+	"testing"
+)
+
+func TestParse(test *testing.T) {
+	testCases := []struct {
+		fixture            string
+		expectedReceipt    receipt.Receipt
+		expectedConfidence map[string]float64
+	}{
+		{
+			fixture: "testdata/target.txt",
+			expectedReceipt: receipt.Receipt{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []item.Item{
+					{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+					{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+					{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+					{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+					{ShortDescription: "Klarbrunn 12-PK 12 FL OZ", Price: "12.00"},
+				},
+				Total: "35.35",
+			},
+			expectedConfidence: map[string]float64{"retailer": 0.9, "purchaseDate": 0.95, "purchaseTime": 0.85, "items": 0.95, "total": 1},
+		},
+		{
+			fixture: "testdata/corner_market.txt",
+			expectedReceipt: receipt.Receipt{
+				Retailer:     "M&M CORNER MARKET",
+				PurchaseDate: "2022-03-20",
+				PurchaseTime: "14:33",
+				Items: []item.Item{
+					{ShortDescription: "Gatorade", Price: "4.50", Quantity: 2},
+					{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+					{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+				},
+				Total: "21.31",
+			},
+			expectedConfidence: map[string]float64{"retailer": 0.9, "purchaseDate": 0.8, "purchaseTime": 0.9, "items": 0.95, "total": 0.9},
+		},
+		{
+			fixture: "testdata/smudged.txt",
+			expectedReceipt: receipt.Receipt{
+				Items: []item.Item{
+					{ShortDescription: "Milk 2%", Price: "3.99"},
+					{ShortDescription: "Bread", Price: "2.49"},
+				},
+				Total: "7.00",
+			},
+			expectedConfidence: map[string]float64{"retailer": 0, "purchaseDate": 0, "purchaseTime": 0, "items": 0.6, "total": 0.9},
+		},
+	}
+
+	for _, testCase := range testCases {
+		text, err := os.ReadFile(testCase.fixture)
+		if err != nil {
+			test.Fatalf("Reading fixture '%s' failed with error: %v", testCase.fixture, err)
+		}
+
+		draft := rp.Parse(string(text))
+		if !reflect.DeepEqual(draft.Receipt, testCase.expectedReceipt) {
+			test.Errorf("Fixture '%s', got receipt %+v, but expected %+v", testCase.fixture, draft.Receipt, testCase.expectedReceipt)
+		}
+		if !reflect.DeepEqual(draft.Confidence, testCase.expectedConfidence) {
+			test.Errorf("Fixture '%s', got confidence %v, but expected %v", testCase.fixture, draft.Confidence, testCase.expectedConfidence)
+		}
+	}
+}
+
+func TestParseImpossibleDate(test *testing.T) {
+	// A date that isn't on the calendar is skipped in favor of a later one
+	testCases := []struct {
+		text         string
+		expectedDate string
+	}{
+		{text: "02/31/2022\n03/01/2022", expectedDate: "2022-03-01"},
+		{text: "2022-02-30\n2022-03-01", expectedDate: "2022-03-01"},
+		{text: "02/29/23", expectedDate: ""},
+		{text: "02/29/24", expectedDate: "2024-02-29"},
+	}
+
+	for _, testCase := range testCases {
+		draft := rp.Parse(testCase.text)
+		if draft.Receipt.PurchaseDate != testCase.expectedDate {
+			test.Errorf("Case '%s', got date '%s', but expected '%s'", testCase.text, draft.Receipt.PurchaseDate, testCase.expectedDate)
+		}
+	}
+}
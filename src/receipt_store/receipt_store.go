@@ -0,0 +1,174 @@
+package receipt_manager
+
+import (
+	"errors"
+	receipt_processor "receipt_manager/point_calculator"
+	receipt "receipt_manager/receipt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrReceiptExists   = errors.New("receipt already exists")
+	ErrReceiptNotFound = errors.New("receipt not found")
+	ErrReceiptDeleted  = errors.New("receipt has been deleted")
+)
+
+type Revision struct {
+	Number    int             `json:"revision"`
+	Receipt   receipt.Receipt `json:"receipt"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type StoredReceipt struct {
+	Id      string
+	Receipt receipt.Receipt
+}
+
+type ReceiptStore struct {
+	mutex      sync.RWMutex
+	revisions  map[string][]Revision
+	breakdowns map[string]receipt_processor.Breakdown
+	contexts   map[string]receipt_processor.ScoringContext
+	tombstones map[string]time.Time
+}
+
+func NewReceiptStore() *ReceiptStore {
+	return &ReceiptStore{
+		revisions:  make(map[string][]Revision),
+		breakdowns: make(map[string]receipt_processor.Breakdown),
+		contexts:   make(map[string]receipt_processor.ScoringContext),
+		tombstones: make(map[string]time.Time),
+	}
+}
+
+func (store *ReceiptStore) Add(id string, newReceipt receipt.Receipt) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, receiptExists := store.revisions[id]; receiptExists {
+		return ErrReceiptExists
+	}
+
+	// Resubmitting erased content is a new receipt, so the tombstone is lifted
+	delete(store.tombstones, id)
+	store.revisions[id] = []Revision{{
+		Number:    1,
+		Receipt:   newReceipt,
+		CreatedAt: time.Now().UTC(),
+	}}
+	return nil
+}
+
+func (store *ReceiptStore) Get(id string) (receipt.Receipt, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	revisions, err := store.lookup(id)
+	if err != nil {
+		return receipt.Receipt{}, err
+	}
+	return revisions[len(revisions)-1].Receipt, nil
+}
+
+func (store *ReceiptStore) List() []StoredReceipt {
+	// The current revision of every receipt that hasn't been deleted, ordered by id
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	storedReceipts := []StoredReceipt{}
+	for id, revisions := range store.revisions {
+		storedReceipts = append(storedReceipts, StoredReceipt{Id: id, Receipt: revisions[len(revisions)-1].Receipt})
+	}
+	sort.Slice(storedReceipts, func(i, j int) bool {
+		return storedReceipts[i].Id < storedReceipts[j].Id
+	})
+	return storedReceipts
+}
+
+func (store *ReceiptStore) Amend(id string, amendedReceipt receipt.Receipt) (Revision, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	revisions, err := store.lookup(id)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	// The id stays the hash of the original submission; only the revision advances
+	revision := Revision{
+		Number:    len(revisions) + 1,
+		Receipt:   amendedReceipt,
+		CreatedAt: time.Now().UTC(),
+	}
+	store.revisions[id] = append(revisions, revision)
+	delete(store.breakdowns, id)
+	delete(store.contexts, id)
+	return revision, nil
+}
+
+func (store *ReceiptStore) Revisions(id string) ([]Revision, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	revisions, err := store.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Revision(nil), revisions...), nil
+}
+
+func (store *ReceiptStore) CachedBreakdown(id string) (receipt_processor.Breakdown, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	breakdown, breakdownCached := store.breakdowns[id]
+	return breakdown, breakdownCached
+}
+
+func (store *ReceiptStore) CacheBreakdown(id string, breakdown receipt_processor.Breakdown, context receipt_processor.ScoringContext) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// The context the breakdown was scored with is kept so the receipt can be rescored as it was
+	if _, receiptExists := store.revisions[id]; receiptExists {
+		store.breakdowns[id] = breakdown
+		store.contexts[id] = context
+	}
+}
+
+func (store *ReceiptStore) ScoringContext(id string) (receipt_processor.ScoringContext, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	context, contextSaved := store.contexts[id]
+	return context, contextSaved
+}
+
+func (store *ReceiptStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, err := store.lookup(id); err != nil {
+		return err
+	}
+
+	// Remove the receipt and everything derived from it, keeping only a tombstone
+	delete(store.revisions, id)
+	delete(store.breakdowns, id)
+	delete(store.contexts, id)
+	store.tombstones[id] = time.Now().UTC()
+	return nil
+}
+
+func (store *ReceiptStore) lookup(id string) ([]Revision, error) {
+	revisions, receiptExists := store.revisions[id]
+	if receiptExists {
+		return revisions, nil
+	}
+	if _, receiptDeleted := store.tombstones[id]; receiptDeleted {
+		return nil, ErrReceiptDeleted
+	}
+	return nil, ErrReceiptNotFound
+}
@@ -0,0 +1,152 @@
+package receipt_manager_test
+
+import (
+	pc "receipt_manager/point_calculator"
+	receipt "receipt_manager/receipt"
+	rs "receipt_manager/receipt_store"
+	"testing"
+)
+
+func TestAddAndGet(test *testing.T) {
+	store := rs.NewReceiptStore()
+	storedReceipt := receipt.Receipt{Retailer: "Target", Total: "35.35"}
+
+	if err := store.Add("abc", storedReceipt); err != nil {
+		test.Errorf("Add failed with error: %v", err)
+	}
+
+	if err := store.Add("abc", storedReceipt); err != rs.ErrReceiptExists {
+		test.Errorf("Duplicate add returned %v, but expected %v", err, rs.ErrReceiptExists)
+	}
+
+	fetchedReceipt, err := store.Get("abc")
+	if err != nil {
+		test.Errorf("Get failed with error: %v", err)
+	}
+	if fetchedReceipt.Retailer != storedReceipt.Retailer {
+		test.Errorf("Got retailer '%s', but expected '%s'",
+			fetchedReceipt.Retailer, storedReceipt.Retailer)
+	}
+
+	if _, err := store.Get("missing"); err != rs.ErrReceiptNotFound {
+		test.Errorf("Get of missing id returned %v, but expected %v", err, rs.ErrReceiptNotFound)
+	}
+}
+
+func TestList(test *testing.T) {
+	store := rs.NewReceiptStore()
+	store.Add("b", receipt.Receipt{Retailer: "Target"})
+	store.Add("a", receipt.Receipt{Retailer: "Walgreens"})
+	store.Add("c", receipt.Receipt{Retailer: "Costco"})
+	store.Amend("b", receipt.Receipt{Retailer: "Target", Total: "1.00"})
+	store.Delete("c")
+
+	storedReceipts := store.List()
+	if len(storedReceipts) != 2 || storedReceipts[0].Id != "a" || storedReceipts[1].Id != "b" {
+		test.Fatalf("Got receipts %v, but expected a and b", storedReceipts)
+	}
+	if storedReceipts[1].Receipt.Total != "1.00" {
+		test.Errorf("Got total '%s' for b, but expected its amended total '1.00'", storedReceipts[1].Receipt.Total)
+	}
+}
+
+func TestDelete(test *testing.T) {
+	testCases := []struct {
+		deleteTwice bool
+		id          string
+		expectedErr error
+	}{
+		{
+			id:          "abc",
+			expectedErr: nil,
+		},
+		{
+			id:          "abc",
+			deleteTwice: true,
+			expectedErr: rs.ErrReceiptDeleted,
+		},
+		{
+			id:          "missing",
+			expectedErr: rs.ErrReceiptNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		store := rs.NewReceiptStore()
+		store.Add("abc", receipt.Receipt{Retailer: "Target"})
+		store.CacheBreakdown("abc", pc.Breakdown{Points: 28}, pc.ScoringContext{})
+
+		err := store.Delete(testCase.id)
+		if testCase.deleteTwice {
+			err = store.Delete(testCase.id)
+		}
+
+		if err != testCase.expectedErr {
+			test.Errorf("Deleting '%s', got error %v, but expected %v",
+				testCase.id, err, testCase.expectedErr)
+		}
+
+		if testCase.expectedErr == rs.ErrReceiptNotFound {
+			continue
+		}
+
+		if _, err := store.Get(testCase.id); err != rs.ErrReceiptDeleted {
+			test.Errorf("Get after delete returned %v, but expected %v", err, rs.ErrReceiptDeleted)
+		}
+		if _, breakdownCached := store.CachedBreakdown(testCase.id); breakdownCached {
+			test.Errorf("Points for '%s' are still cached after delete", testCase.id)
+		}
+	}
+}
+
+func TestResubmitAfterDelete(test *testing.T) {
+	store := rs.NewReceiptStore()
+	store.Add("abc", receipt.Receipt{Retailer: "Target"})
+	store.Delete("abc")
+
+	if err := store.Add("abc", receipt.Receipt{Retailer: "Target"}); err != nil {
+		test.Errorf("Resubmitting a deleted receipt failed with error: %v", err)
+	}
+	if _, err := store.Get("abc"); err != nil {
+		test.Errorf("Get after resubmission failed with error: %v", err)
+	}
+}
+
+func TestAmend(test *testing.T) {
+	store := rs.NewReceiptStore()
+	store.Add("abc", receipt.Receipt{Retailer: "Target", Total: "35.35"})
+	store.CacheBreakdown("abc", pc.Breakdown{Points: 28}, pc.ScoringContext{})
+
+	revision, err := store.Amend("abc", receipt.Receipt{Retailer: "Target", Total: "35.00"})
+	if err != nil {
+		test.Errorf("Amend failed with error: %v", err)
+	}
+	if revision.Number != 2 {
+		test.Errorf("Got revision %d, but expected %d", revision.Number, 2)
+	}
+
+	currentReceipt, _ := store.Get("abc")
+	if currentReceipt.Total != "35.00" {
+		test.Errorf("Got total '%s' after amendment, but expected '%s'", currentReceipt.Total, "35.00")
+	}
+	if _, breakdownCached := store.CachedBreakdown("abc"); breakdownCached {
+		test.Errorf("Points are still cached after amendment")
+	}
+
+	revisions, err := store.Revisions("abc")
+	if err != nil {
+		test.Errorf("Revisions failed with error: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Receipt.Total != "35.35" {
+		test.Errorf("Got revisions %+v, but expected the original followed by the amendment", revisions)
+	}
+
+	if _, err := store.Amend("missing", receipt.Receipt{}); err != rs.ErrReceiptNotFound {
+		test.Errorf("Amend of missing id returned %v, but expected %v", err, rs.ErrReceiptNotFound)
+	}
+
+	store.Delete("abc")
+	if _, err := store.Revisions("abc"); err != rs.ErrReceiptDeleted {
+		test.Errorf("Revisions after delete returned %v, but expected %v", err, rs.ErrReceiptDeleted)
+	}
+}
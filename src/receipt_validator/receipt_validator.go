@@ -1,12 +1,18 @@
 package receipt_manager
 
 import (
-	"fmt"
+	currency "receipt_manager/currency"
+	name_policy "receipt_manager/name_policy"
+	purchase_time "receipt_manager/purchase_time"
 	receipt "receipt_manager/receipt"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
+// Set at startup when NAME_POLICY_FILE overrides the default name rules
+var NamePolicies = name_policy.DefaultPolicies()
+
 func StringIsInt(str string) bool {
 	_, err := strconv.Atoi(str)
 	return err == nil
@@ -14,46 +20,53 @@ func StringIsInt(str string) bool {
 
 func ReceiptMissingFields(receipt receipt.Receipt) bool {
 	return receipt.Retailer == "" ||
-	   receipt.PurchaseDate == "" ||
-	   receipt.PurchaseTime == "" ||
-	   len(receipt.Items) == 0 ||
-	   receipt.Total == ""
+		receipt.PurchaseDate == "" ||
+		receipt.PurchaseTime == "" ||
+		len(receipt.Items) == 0 ||
+		receipt.Total == ""
 }
 
 func ReceiptFieldsValid(receipt receipt.Receipt) bool {
 	return RetailerValid(receipt) &&
-		  PurchaseDateValid(receipt) &&
-		  PurchaseTimeValid(receipt) &&
-		  ItemsValid(receipt) &&
-		  TotalValid(receipt)
+		PurchaseDateValid(receipt) &&
+		PurchaseTimeValid(receipt) &&
+		ItemsValid(receipt) &&
+		TotalValid(receipt) &&
+		AccountIdValid(receipt) &&
+		TimezoneValid(receipt) &&
+		CurrencyValid(receipt)
 }
 
 func RetailerValid(receipt receipt.Receipt) bool {
-	pattern := "^[\\w\\s\\-]+$"
-	retailerIsValid, _ := regexp.MatchString(pattern, receipt.Retailer)
-	return retailerIsValid
+	return NamePolicies.Retailer.Valid(receipt.Retailer)
 }
 
 func PurchaseDateValid(receipt receipt.Receipt) bool {
 	return len(receipt.PurchaseDate) == 10 &&
-	   StringIsInt(receipt.PurchaseDate[0:4]) &&
-	   receipt.PurchaseDate[4:5] == "-" &&
-	   StringIsInt(receipt.PurchaseDate[5:7]) &&
-	   receipt.PurchaseDate[7:8] == "-" &&
-	   StringIsInt(receipt.PurchaseDate[8:10])
+		StringIsInt(receipt.PurchaseDate[0:4]) &&
+		receipt.PurchaseDate[4:5] == "-" &&
+		StringIsInt(receipt.PurchaseDate[5:7]) &&
+		receipt.PurchaseDate[7:8] == "-" &&
+		StringIsInt(receipt.PurchaseDate[8:10])
 }
 
 func PurchaseTimeValid(receipt receipt.Receipt) bool {
 	// Assuming all timestamps are written HH:MM
 	return len(receipt.PurchaseTime) == 5 &&
-	    StringIsInt(receipt.PurchaseTime[0:2]) &&
-	    receipt.PurchaseTime[2:3] == ":" &&
-	    StringIsInt(receipt.PurchaseTime[3:5])
+		StringIsInt(receipt.PurchaseTime[0:2]) &&
+		receipt.PurchaseTime[2:3] == ":" &&
+		StringIsInt(receipt.PurchaseTime[3:5])
 }
 
 func ItemsValid(receipt receipt.Receipt) bool {
 	for _, item := range receipt.Items {
-		if !validateItemDescription(item.ShortDescription) || !validateItemPrice(item.Price) {
+		if !validateItemDescription(item.ShortDescription) || !validateItemPrice(item.Price, receipt.Currency) {
+			return false
+		}
+		if !validateItemCatalogFields(item.Sku, item.Upc, item.Category) {
+			return false
+		}
+		if !validateItemQuantity(item.Quantity, item.UnitPrice, item.Price, receipt.Currency) {
 			return false
 		}
 	}
@@ -61,31 +74,84 @@ func ItemsValid(receipt receipt.Receipt) bool {
 }
 
 func validateItemDescription(description string) bool {
-	pattern := "^[\\w\\s\\-]+$"
-	descriptionIsValid, err := regexp.MatchString(pattern, description)
+	return NamePolicies.Description.Valid(description)
+}
+
+func validateItemPrice(price string, currencyCode string) bool {
+	// Prices carry as many decimals as the currency has minor units, none for JPY
+	priceCurrency, err := currency.Lookup(currencyCode)
 	if err != nil {
-		fmt.Println("Error while validating item description:", err)
 		return false
 	}
-	return descriptionIsValid
+	_, err = priceCurrency.ParseAmount(price)
+	return err == nil
 }
 
-func validateItemPrice(price string) bool {
-	pattern := "^\\d+\\.\\d{2}$"
-	priceIsValid, err := regexp.MatchString(pattern, price)
-	if err != nil {
-		fmt.Println("Error while validating item price:", err)
+func validateItemCatalogFields(sku string, upc string, category string) bool {
+	// SKU, UPC and category are optional, but must be well formed when present
+	skuIsValid, _ := regexp.MatchString("^[\\w\\-]+$", sku)
+	upcIsValid, _ := regexp.MatchString("^\\d{8,14}$", upc)
+	categoryIsValid, _ := regexp.MatchString("^[\\w\\s\\-]+$", category)
+	return (sku == "" || skuIsValid) &&
+		(upc == "" || upcIsValid) &&
+		(category == "" || categoryIsValid)
+}
+
+func validateItemQuantity(quantity int, unitPrice string, price string, currencyCode string) bool {
+	// Quantity and unit price are optional; with either, price must equal quantity × unit price
+	if quantity == 0 && unitPrice == "" {
+		return true
+	}
+	if quantity < 0 || (unitPrice != "" && !validateItemPrice(unitPrice, currencyCode)) {
+		return false
+	}
+
+	units := quantity
+	if units == 0 {
+		units = 1
+	}
+	priceCents, priceErr := strconv.Atoi(strings.Replace(price, ".", "", 1))
+	if priceErr != nil {
 		return false
 	}
-	return priceIsValid
+	if unitPrice == "" {
+		// Without a unit price, the price has to split evenly into whole cents
+		return priceCents%units == 0
+	}
+	unitPriceCents, unitPriceErr := strconv.Atoi(strings.Replace(unitPrice, ".", "", 1))
+	return unitPriceErr == nil && priceCents == units*unitPriceCents
 }
 
 func TotalValid(receipt receipt.Receipt) bool {
-	pattern := "^\\d+\\.\\d{2}$"
-	totalIsValid, err := regexp.MatchString(pattern, receipt.Total)
+	totalCurrency, err := currency.Lookup(receipt.Currency)
 	if err != nil {
-		fmt.Println("Error while validating total price:", err)
 		return false
 	}
-	return totalIsValid
+	_, err = totalCurrency.ParseAmount(receipt.Total)
+	return err == nil
+}
+
+func AccountIdValid(receipt receipt.Receipt) bool {
+	// The account id is optional, but must be a single token when present
+	if receipt.AccountId == "" {
+		return true
+	}
+	pattern := "^[\\w\\-]+$"
+	accountIdIsValid, _ := regexp.MatchString(pattern, receipt.AccountId)
+	return accountIdIsValid
+}
+
+func TimezoneValid(receipt receipt.Receipt) bool {
+	// The timezone is optional, but with one the date and time must name a real instant
+	if receipt.Timezone == "" {
+		return true
+	}
+	_, err := purchase_time.Timestamp(receipt, "")
+	return err == nil
+}
+
+func CurrencyValid(receipt receipt.Receipt) bool {
+	// The currency is optional, but must be a supported ISO 4217 code when present
+	_, err := currency.Lookup(receipt.Currency)
+	return err == nil
 }
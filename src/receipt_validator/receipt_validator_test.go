@@ -1,92 +1,121 @@
-package receipt_manager
+package receipt_manager_test
 
 import (
 	item "receipt_manager/item"
 	receipt "receipt_manager/receipt"
 	rv "receipt_manager/receipt_validator"
+	"strings"
 	"testing"
 )
 
 func TestRetailerValid(test *testing.T) {
 	testCases := []struct {
-		receipt receipt.Receipt
+		receipt          receipt.Receipt
 		expectedValidity bool
 	}{
 		{
-			receipt: receipt.Receipt{Retailer: "The Corner Store"},
+			receipt:          receipt.Receipt{Retailer: "The Corner Store"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{Retailer: "Walmart 123"},
+			receipt:          receipt.Receipt{Retailer: "Walmart 123"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{Retailer: "Target!"},
+			receipt:          receipt.Receipt{Retailer: "Target!"},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{Retailer: "Best Buy"},
+			receipt:          receipt.Receipt{Retailer: "Best Buy"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{Retailer: "123 Grocery Store"},
+			receipt:          receipt.Receipt{Retailer: "123 Grocery Store"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{Retailer: ""},
+			receipt:          receipt.Receipt{Retailer: ""},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{Retailer: "Test*Store"},
+			receipt:          receipt.Receipt{Retailer: "Test*Store"},
 			expectedValidity: false, // False case: contains special character
 		},
 		{
-			receipt: receipt.Receipt{Retailer: "Supermarket!"},
+			receipt:          receipt.Receipt{Retailer: "Supermarket!"},
 			expectedValidity: false, // False case: contains special character
 		},
-		}
+		{
+			receipt:          receipt.Receipt{Retailer: "M&M Corner Market"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{Retailer: "Trader Joe's"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{Retailer: "Cafe\u0301 Ole\u0301"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{Retailer: "ローソン"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{Retailer: "&'-."},
+			expectedValidity: false, // False case: no letters or digits
+		},
+		{
+			receipt:          receipt.Receipt{Retailer: strings.Repeat("é", 101)},
+			expectedValidity: true, // No length limit by default
+		},
+		{
+			receipt:          receipt.Receipt{Retailer: "Joe_s Market"},
+			expectedValidity: true,
+		},
+	}
 
 	for _, testCase := range testCases {
 		retailerValidity := rv.RetailerValid(testCase.receipt)
 
-	if retailerValidity != testCase.expectedValidity {
-		test.Errorf("Retailer '%s', validity is %t, but expected %t",
-		testCase.receipt.Retailer, retailerValidity, testCase.expectedValidity)
+		if retailerValidity != testCase.expectedValidity {
+			test.Errorf("Retailer '%s', validity is %t, but expected %t",
+				testCase.receipt.Retailer, retailerValidity, testCase.expectedValidity)
 		}
 	}
 }
 
 func TestPurchaseDateValid(test *testing.T) {
 	testCases := []struct {
-		receipt receipt.Receipt
+		receipt          receipt.Receipt
 		expectedValidity bool
 	}{
 		{
-			receipt: receipt.Receipt{PurchaseDate: "2000-01-01"},
+			receipt:          receipt.Receipt{PurchaseDate: "2000-01-01"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{PurchaseDate: "2000001-01"},
+			receipt:          receipt.Receipt{PurchaseDate: "2000001-01"},
 			expectedValidity: false,
 		},
-		
+
 		{
-			receipt: receipt.Receipt{PurchaseDate: "2000-1-1"},
+			receipt:          receipt.Receipt{PurchaseDate: "2000-1-1"},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{PurchaseDate: "today's date"},
+			receipt:          receipt.Receipt{PurchaseDate: "today's date"},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{PurchaseDate: ""},
+			receipt:          receipt.Receipt{PurchaseDate: ""},
 			expectedValidity: false,
 		},
 	}
 
 	for _, testCase := range testCases {
 		purchaseDateValidity := rv.PurchaseDateValid(testCase.receipt)
-		
+
 		if purchaseDateValidity != testCase.expectedValidity {
 			test.Errorf("Purchase Date '%s', validity is %t, but expected %t",
 				testCase.receipt.PurchaseDate, purchaseDateValidity, testCase.expectedValidity)
@@ -96,34 +125,34 @@ func TestPurchaseDateValid(test *testing.T) {
 
 func TestPurchaseTimeValid(test *testing.T) {
 	testCases := []struct {
-		receipt receipt.Receipt
+		receipt          receipt.Receipt
 		expectedValidity bool
 	}{
 		{
-			receipt: receipt.Receipt{PurchaseTime: "12:00"},
+			receipt:          receipt.Receipt{PurchaseTime: "12:00"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{PurchaseTime: "9:30"},
+			receipt:          receipt.Receipt{PurchaseTime: "9:30"},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{PurchaseTime: "A9:30"},
+			receipt:          receipt.Receipt{PurchaseTime: "A9:30"},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{PurchaseTime: "9"},
+			receipt:          receipt.Receipt{PurchaseTime: "9"},
 			expectedValidity: false,
 		},
-				{
-			receipt: receipt.Receipt{PurchaseTime: ""},
+		{
+			receipt:          receipt.Receipt{PurchaseTime: ""},
 			expectedValidity: false,
 		},
 	}
-		
+
 	for _, testCase := range testCases {
 		purchaseTimeValidity := rv.PurchaseTimeValid(testCase.receipt)
-		
+
 		if purchaseTimeValidity != testCase.expectedValidity {
 			test.Errorf("Purchase Time '%s', validity is %t, but expected %t",
 				testCase.receipt.PurchaseTime, purchaseTimeValidity, testCase.expectedValidity)
@@ -133,11 +162,11 @@ func TestPurchaseTimeValid(test *testing.T) {
 
 func TestValidateItems(test *testing.T) {
 	testCases := []struct {
-		receipt receipt.Receipt
+		receipt          receipt.Receipt
 		expectedValidity bool
 	}{
 		{
-			receipt: receipt.Receipt{ 
+			receipt: receipt.Receipt{
 				Items: []item.Item{
 					{ShortDescription: "400 fish sticks", Price: "6.49"},
 					{ShortDescription: "Coca Cola 6pack", Price: "5.99"},
@@ -146,7 +175,7 @@ func TestValidateItems(test *testing.T) {
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{ 
+			receipt: receipt.Receipt{
 				Items: []item.Item{
 					{ShortDescription: "4,000 fish sticks", Price: "10.00"},
 					{ShortDescription: "Coca Cola 6-pack", Price: "5.99"},
@@ -193,37 +222,201 @@ func TestValidateItems(test *testing.T) {
 
 func TestTotalValid(test *testing.T) {
 	testCases := []struct {
-		receipt receipt.Receipt
+		receipt          receipt.Receipt
 		expectedValidity bool
 	}{
 		{
-			receipt: receipt.Receipt{Total: "50.00"},
+			receipt:          receipt.Receipt{Total: "50.00"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{Total: "0.00"},
+			receipt:          receipt.Receipt{Total: "0.00"},
 			expectedValidity: true,
 		},
 		{
-			receipt: receipt.Receipt{Total: "10.5"},
+			receipt:          receipt.Receipt{Total: "10.5"},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{Total: "50"},
+			receipt:          receipt.Receipt{Total: "50"},
 			expectedValidity: false,
 		},
 		{
-			receipt: receipt.Receipt{Total: ""},
+			receipt:          receipt.Receipt{Total: ""},
+			expectedValidity: false,
+		},
+		{
+			receipt:          receipt.Receipt{Total: "1250", Currency: "JPY"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{Total: "1250.00", Currency: "JPY"},
+			expectedValidity: false,
+		},
+		{
+			receipt:          receipt.Receipt{Total: "12.50", Currency: "EUR"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{Total: "12.50", Currency: "XYZ"},
 			expectedValidity: false,
 		},
 	}
-		
+
 	for _, testCase := range testCases {
 		totalValidity := rv.TotalValid(testCase.receipt)
-		
+
 		if totalValidity != testCase.expectedValidity {
 			test.Errorf("Total '%s', validity is %t, but expected %t",
 				testCase.receipt.Total, totalValidity, testCase.expectedValidity)
 		}
 	}
 }
+
+func TestAccountIdValid(test *testing.T) {
+	testCases := []struct {
+		receipt          receipt.Receipt
+		expectedValidity bool
+	}{
+		{
+			receipt:          receipt.Receipt{AccountId: ""},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{AccountId: "customer-42"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{AccountId: "customer 42"},
+			expectedValidity: false,
+		},
+		{
+			receipt:          receipt.Receipt{AccountId: "customer/42"},
+			expectedValidity: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		accountIdValidity := rv.AccountIdValid(testCase.receipt)
+
+		if accountIdValidity != testCase.expectedValidity {
+			test.Errorf("Account Id '%s', validity is %t, but expected %t",
+				testCase.receipt.AccountId, accountIdValidity, testCase.expectedValidity)
+		}
+	}
+}
+
+func TestTimezoneValid(test *testing.T) {
+	testCases := []struct {
+		receipt          receipt.Receipt
+		expectedValidity bool
+	}{
+		{
+			receipt:          receipt.Receipt{PurchaseDate: "2022-01-01", PurchaseTime: "13:01"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Timezone: "America/Denver"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Timezone: "-07:00"},
+			expectedValidity: true,
+		},
+		{
+			receipt:          receipt.Receipt{PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Timezone: "Mountain"},
+			expectedValidity: false,
+		},
+		{
+			receipt:          receipt.Receipt{PurchaseDate: "2022-02-30", PurchaseTime: "13:01", Timezone: "America/Denver"},
+			expectedValidity: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		timezoneValidity := rv.TimezoneValid(testCase.receipt)
+
+		if timezoneValidity != testCase.expectedValidity {
+			test.Errorf("Timezone '%s', validity is %t, but expected %t",
+				testCase.receipt.Timezone, timezoneValidity, testCase.expectedValidity)
+		}
+	}
+}
+
+func TestValidateItemCatalogFields(test *testing.T) {
+	testCases := []struct {
+		item             item.Item
+		expectedValidity bool
+	}{
+		{
+			item:             item.Item{ShortDescription: "Gatorade", Price: "2.25", Sku: "GAT-32", Upc: "052000328684", Category: "beverages"},
+			expectedValidity: true,
+		},
+		{
+			item:             item.Item{ShortDescription: "Gatorade", Price: "2.25"},
+			expectedValidity: true,
+		},
+		{
+			item:             item.Item{ShortDescription: "Gatorade", Price: "2.25", Sku: "GAT 32"},
+			expectedValidity: false,
+		},
+		{
+			item:             item.Item{ShortDescription: "Gatorade", Price: "2.25", Upc: "05200032868A"},
+			expectedValidity: false,
+		},
+		{
+			item:             item.Item{ShortDescription: "Gatorade", Price: "2.25", Category: "drinks & more"},
+			expectedValidity: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		result := rv.ItemsValid(receipt.Receipt{Items: []item.Item{testCase.item}})
+		if result != testCase.expectedValidity {
+			test.Errorf("Item %+v, validity is %t, but expected %t", testCase.item, result, testCase.expectedValidity)
+		}
+	}
+}
+
+func TestValidateItemQuantity(test *testing.T) {
+	testCases := []struct {
+		item             item.Item
+		expectedValidity bool
+	}{
+		{
+			item:             item.Item{ShortDescription: "Doritos", Price: "4.50", Quantity: 3, UnitPrice: "1.50"},
+			expectedValidity: true,
+		},
+		{
+			item:             item.Item{ShortDescription: "Doritos", Price: "4.50", Quantity: 3},
+			expectedValidity: true,
+		},
+		{
+			item:             item.Item{ShortDescription: "Doritos", Price: "1.50", UnitPrice: "1.50"},
+			expectedValidity: true,
+		},
+		{
+			item:             item.Item{ShortDescription: "Doritos", Price: "4.00", Quantity: 3, UnitPrice: "1.50"},
+			expectedValidity: false,
+		},
+		{
+			item:             item.Item{ShortDescription: "Doritos", Price: "1.00", Quantity: 3},
+			expectedValidity: false,
+		},
+		{
+			item:             item.Item{ShortDescription: "Doritos", Price: "4.50", Quantity: -3, UnitPrice: "-1.50"},
+			expectedValidity: false,
+		},
+		{
+			item:             item.Item{ShortDescription: "Doritos", Price: "4.50", Quantity: 3, UnitPrice: "1.5"},
+			expectedValidity: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		result := rv.ItemsValid(receipt.Receipt{Items: []item.Item{testCase.item}})
+		if result != testCase.expectedValidity {
+			test.Errorf("Item %+v, validity is %t, but expected %t", testCase.item, result, testCase.expectedValidity)
+		}
+	}
+}
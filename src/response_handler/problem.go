@@ -0,0 +1,142 @@
+package receipt_manager
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Stable, machine-readable error codes; clients should match on these rather than on details
+type ErrorCode string
+
+const (
+	ErrorCodeRequestDecodingFailed    ErrorCode = "request_decoding_failed"
+	ErrorCodeUnknownField             ErrorCode = "unknown_field"
+	ErrorCodeWrongFieldType           ErrorCode = "wrong_field_type"
+	ErrorCodeTrailingData             ErrorCode = "trailing_data"
+	ErrorCodeTooManyItems             ErrorCode = "too_many_items"
+	ErrorCodeBodyTooLarge             ErrorCode = "body_too_large"
+	ErrorCodeUnsupportedMediaType     ErrorCode = "unsupported_media_type"
+	ErrorCodeInvalidParameter         ErrorCode = "invalid_parameter"
+	ErrorCodeMissingFields            ErrorCode = "missing_fields"
+	ErrorCodeInvalidFields            ErrorCode = "invalid_fields"
+	ErrorCodeUnsupportedCurrency      ErrorCode = "unsupported_currency"
+	ErrorCodeInvalidPurchaseDate      ErrorCode = "invalid_purchase_date"
+	ErrorCodeDuplicateReceipt         ErrorCode = "duplicate_receipt"
+	ErrorCodeReceiptClaimed           ErrorCode = "receipt_claimed"
+	ErrorCodeAccountChanged           ErrorCode = "account_changed"
+	ErrorCodeReceiptNotFound          ErrorCode = "receipt_not_found"
+	ErrorCodeReceiptDeleted           ErrorCode = "receipt_deleted"
+	ErrorCodeAccountNotFound          ErrorCode = "account_not_found"
+	ErrorCodeInsufficientPoints       ErrorCode = "insufficient_points"
+	ErrorCodeRedemptionKeyReused      ErrorCode = "redemption_key_reused"
+	ErrorCodeInvalidRedemption        ErrorCode = "invalid_redemption"
+	ErrorCodeCampaignNotFound         ErrorCode = "campaign_not_found"
+	ErrorCodeInvalidCampaign          ErrorCode = "invalid_campaign"
+	ErrorCodeRetailerNotFound         ErrorCode = "retailer_not_found"
+	ErrorCodeInvalidRetailer          ErrorCode = "invalid_retailer"
+	ErrorCodeRetailerConflict         ErrorCode = "retailer_conflict"
+	ErrorCodeProductNotFound          ErrorCode = "product_not_found"
+	ErrorCodeInvalidProduct           ErrorCode = "invalid_product"
+	ErrorCodeItemRuleNotFound         ErrorCode = "item_rule_not_found"
+	ErrorCodeInvalidItemRule          ErrorCode = "invalid_item_rule"
+	ErrorCodeWebhookNotFound          ErrorCode = "webhook_not_found"
+	ErrorCodeInvalidWebhook           ErrorCode = "invalid_webhook"
+	ErrorCodeInvalidIdempotencyKey    ErrorCode = "invalid_idempotency_key"
+	ErrorCodeIdempotencyKeyInProgress ErrorCode = "idempotency_key_in_progress"
+	ErrorCodeIdempotencyKeyReused     ErrorCode = "idempotency_key_reused"
+	ErrorCodeNotAcceptable            ErrorCode = "not_acceptable"
+	ErrorCodeRouteNotFound            ErrorCode = "route_not_found"
+	ErrorCodeMethodNotAllowed         ErrorCode = "method_not_allowed"
+	ErrorCodeInternalError            ErrorCode = "internal_error"
+	// Only over gRPC, whose points fields are 32-bit
+	ErrorCodePointsOutOfRange ErrorCode = "points_out_of_range"
+)
+
+// Set on every response by the request id middleware, and echoed in problem bodies
+const RequestIdHeader = "X-Request-Id"
+
+// An RFC 7807 problem details body, with the error code and request id as extension members
+type Problem struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    int       `json:"status"`
+	Detail    string    `json:"detail"`
+	Instance  string    `json:"instance,omitempty"`
+	Code      ErrorCode `json:"code"`
+	RequestId string    `json:"requestId,omitempty"`
+	// The existing resource's id, for conflicts with something already stored
+	Id string `json:"id,omitempty"`
+}
+
+// The error body of servers frozen before problem details, such as /v1
+type LegacyError struct {
+	Error string `json:"Error"`
+}
+
+type legacyErrorsResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (writer *legacyErrorsResponseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+func LegacyErrors(response http.ResponseWriter) http.ResponseWriter {
+	// Errors written through the returned writer are LegacyError bodies rather than problems
+	return &legacyErrorsResponseWriter{ResponseWriter: response}
+}
+
+func legacyErrorsFor(response http.ResponseWriter) bool {
+	// Writers wrapped around the legacy one, such as recorders, are looked through
+	for {
+		switch writer := response.(type) {
+		case *legacyErrorsResponseWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			response = writer.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+func NewProblem(code ErrorCode, statusCode int, detail string, requestId string) Problem {
+	problem := Problem{
+		Type:      "urn:receipt-processor:problem:" + string(code),
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    detail,
+		Code:      code,
+		RequestId: requestId,
+	}
+	if requestId != "" {
+		problem.Instance = "urn:request:" + requestId
+	}
+	return problem
+}
+
+func sendProblem(response http.ResponseWriter, code ErrorCode, statusCode int, detail string) {
+	writeProblem(response, NewProblem(code, statusCode, detail, response.Header().Get(RequestIdHeader)))
+}
+
+func writeProblem(response http.ResponseWriter, problem Problem) {
+	contentType := "application/problem+json"
+	var problemBody []byte
+	var err error
+	if legacyErrorsFor(response) {
+		// The shape every error had before problem details, with no code to match on
+		contentType = "application/json"
+		problemBody, err = json.Marshal(LegacyError{Error: problem.Detail})
+	} else {
+		problemBody, err = json.Marshal(problem)
+	}
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Headers only reach the client if they are set before the status is written
+	response.Header().Set("Content-Type", contentType)
+	response.WriteHeader(problem.Status)
+	response.Write(problemBody)
+}
@@ -0,0 +1,168 @@
+package receipt_manager
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Format struct {
+	MediaType string
+	encode    func(writer io.Writer, body interface{}) error
+}
+
+var cborEncoding, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+// In order of preference when a client accepts several equally
+var formats = []Format{
+	{
+		MediaType: "application/json",
+		encode: func(writer io.Writer, body interface{}) error {
+			return json.NewEncoder(writer).Encode(body)
+		},
+	},
+	{
+		MediaType: "application/msgpack",
+		encode: func(writer io.Writer, body interface{}) error {
+			// Field names follow the json tags, so every format has the same shape
+			encoder := msgpack.NewEncoder(writer)
+			encoder.SetCustomStructTag("json")
+			return encoder.Encode(body)
+		},
+	},
+	{
+		MediaType: "application/cbor",
+		encode: func(writer io.Writer, body interface{}) error {
+			return cborEncoding.NewEncoder(writer).Encode(body)
+		},
+	},
+}
+
+type Negotiation struct {
+	Format Format
+	Gzip   bool
+	// False when Accept allows none of the formats
+	Acceptable bool
+}
+
+type negotiatedResponseWriter struct {
+	http.ResponseWriter
+	negotiation Negotiation
+}
+
+func (writer *negotiatedResponseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+func NegotiateResponses(next http.Handler) http.Handler {
+	/*
+		Picks the body format from Accept and compression from Accept-Encoding for
+		Respond to use. Requests accepting none of the formats are rejected by the
+		router before their handler runs, so they have no side effects
+	*/
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		negotiation := Negotiate(request.Header.Get("Accept"), request.Header.Get("Accept-Encoding"))
+		response.Header().Add("Vary", "Accept, Accept-Encoding")
+		next.ServeHTTP(&negotiatedResponseWriter{ResponseWriter: response, negotiation: negotiation}, request)
+	})
+}
+
+func Negotiate(accept string, acceptEncoding string) Negotiation {
+	negotiation := Negotiation{Format: formats[0], Gzip: qualityOf(acceptEncoding, "gzip", "*") > 0, Acceptable: true}
+	if strings.TrimSpace(accept) == "" {
+		return negotiation
+	}
+
+	bestQuality := 0.0
+	for _, format := range formats {
+		mediaRange := format.MediaType[:strings.Index(format.MediaType, "/")] + "/*"
+		quality := qualityOf(accept, format.MediaType, mediaRange, "*/*")
+		if quality > bestQuality {
+			bestQuality = quality
+			negotiation.Format = format
+		}
+	}
+	negotiation.Acceptable = bestQuality > 0
+	return negotiation
+}
+
+func qualityOf(header string, candidates ...string) float64 {
+	// Returns the q-value the header gives the most specific of the candidates it
+	// mentions, so "application/cbor;q=0.5, */*;q=0.1" rates CBOR 0.5 and JSON 0.1
+	qualities := map[string]float64{}
+	for _, headerPart := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(headerPart))
+		if err != nil {
+			continue
+		}
+		partQuality := 1.0
+		if qValue, qGiven := params["q"]; qGiven {
+			partQuality, err = strconv.ParseFloat(qValue, 64)
+			if err != nil {
+				continue
+			}
+		}
+		qualities[mediaType] = partQuality
+	}
+	for _, candidate := range candidates {
+		if quality, mentioned := qualities[candidate]; mentioned {
+			return quality
+		}
+	}
+	return 0
+}
+
+func negotiationFor(response http.ResponseWriter) Negotiation {
+	// Writers wrapped around the negotiated one, such as recorders, are looked through
+	for {
+		switch writer := response.(type) {
+		case *negotiatedResponseWriter:
+			return writer.negotiation
+		case interface{ Unwrap() http.ResponseWriter }:
+			response = writer.Unwrap()
+		default:
+			return Negotiation{Format: formats[0], Acceptable: true}
+		}
+	}
+}
+
+func Acceptable(response http.ResponseWriter) bool {
+	// Whether the request's Accept header allows one of the formats Respond can send
+	return negotiationFor(response).Acceptable
+}
+
+func Respond(response http.ResponseWriter, statusCode int, body interface{}) {
+	/*
+		Headers are all set before the status is written, and the body is encoded
+		straight onto the connection rather than buffered. A response without a
+		body is just the status
+	*/
+	if body == nil {
+		response.WriteHeader(statusCode)
+		return
+	}
+
+	negotiation := negotiationFor(response)
+	if !negotiation.Acceptable {
+		// Routed operations are turned away before they run; this covers handlers served some other way
+		HandleNotAcceptable(response)
+		return
+	}
+	response.Header().Set("Content-Type", negotiation.Format.MediaType)
+	var bodyWriter io.Writer = response
+	if negotiation.Gzip {
+		response.Header().Set("Content-Encoding", "gzip")
+		gzipWriter := gzip.NewWriter(response)
+		defer gzipWriter.Close()
+		bodyWriter = gzipWriter
+	}
+	response.WriteHeader(statusCode)
+	negotiation.Format.encode(bodyWriter, body)
+}
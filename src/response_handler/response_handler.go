@@ -1,8 +1,16 @@
 package receipt_manager
 
 import (
-	"encoding/json"
 	"net/http"
+	account_store "receipt_manager/account_store"
+	campaign "receipt_manager/campaign"
+	receipt_processor "receipt_manager/point_calculator"
+	product_catalog "receipt_manager/product_catalog"
+	receipt "receipt_manager/receipt"
+	receipt_parser "receipt_manager/receipt_parser"
+	receipt_store "receipt_manager/receipt_store"
+	retailer_catalog "receipt_manager/retailer_catalog"
+	webhook "receipt_manager/webhook"
 )
 
 type IdResponse struct {
@@ -10,7 +18,7 @@ type IdResponse struct {
 }
 
 func SendIdResponse(id string, response http.ResponseWriter) {
-	responseStruct := IdResponse {
+	responseStruct := IdResponse{
 		Id: id,
 	}
 	sendHttpResponse(responseStruct, response)
@@ -21,66 +29,382 @@ type PointsResponse struct {
 }
 
 func SendPointsResponse(points int, response http.ResponseWriter) {
-	responseStruct := PointsResponse {
+	responseStruct := PointsResponse{
 		Points: points,
 	}
 	sendHttpResponse(responseStruct, response)
 }
 
-func sendHttpResponse(responseStruct interface{}, response http.ResponseWriter) {
-	responseBody, err := json.Marshal(responseStruct)
-		if err != nil {
-			HandleInternalServerError(response)
-			return
-		}
+type ReceiptResponse struct {
+	Id string `json:"id"`
+	receipt.Receipt
+}
+
+func SendReceiptResponse(id string, storedReceipt receipt.Receipt, response http.ResponseWriter) {
+	responseStruct := ReceiptResponse{
+		Id:      id,
+		Receipt: storedReceipt,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type RevisionResponse struct {
+	Id       string `json:"id"`
+	Revision int    `json:"revision"`
+	Points   int    `json:"points"`
+}
+
+func SendRevisionResponse(id string, revision int, points int, response http.ResponseWriter) {
+	responseStruct := RevisionResponse{
+		Id:       id,
+		Revision: revision,
+		Points:   points,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type RevisionsResponse struct {
+	Id        string                   `json:"id"`
+	Revisions []receipt_store.Revision `json:"revisions"`
+}
+
+func SendRevisionsResponse(id string, revisions []receipt_store.Revision, response http.ResponseWriter) {
+	responseStruct := RevisionsResponse{
+		Id:        id,
+		Revisions: revisions,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type BalanceResponse struct {
+	AccountId string `json:"accountId"`
+	Balance   int    `json:"balance"`
+}
+
+func SendBalanceResponse(accountId string, balance int, response http.ResponseWriter) {
+	responseStruct := BalanceResponse{
+		AccountId: accountId,
+		Balance:   balance,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type AccountReceiptsResponse struct {
+	AccountId string                 `json:"accountId"`
+	Receipts  []account_store.Credit `json:"receipts"`
+}
+
+func SendAccountReceiptsResponse(accountId string, credits []account_store.Credit, response http.ResponseWriter) {
+	responseStruct := AccountReceiptsResponse{
+		AccountId: accountId,
+		Receipts:  credits,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type LedgerResponse struct {
+	AccountId string                `json:"accountId"`
+	Balance   int                   `json:"balance"`
+	Entries   []account_store.Entry `json:"entries"`
+}
+
+func SendLedgerResponse(accountId string, balance int, entries []account_store.Entry, response http.ResponseWriter) {
+	responseStruct := LedgerResponse{
+		AccountId: accountId,
+		Balance:   balance,
+		Entries:   entries,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type RedemptionResponse struct {
+	AccountId string              `json:"accountId"`
+	Balance   int                 `json:"balance"`
+	Entry     account_store.Entry `json:"entry"`
+}
+
+func SendRedemptionResponse(accountId string, balance int, entry account_store.Entry, response http.ResponseWriter) {
+	responseStruct := RedemptionResponse{
+		AccountId: accountId,
+		Balance:   balance,
+		Entry:     entry,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type ExpirationsResponse struct {
+	AccountId   string                     `json:"accountId"`
+	Expirations []account_store.Expiration `json:"expirations"`
+}
+
+func SendExpirationsResponse(accountId string, expirations []account_store.Expiration, response http.ResponseWriter) {
+	responseStruct := ExpirationsResponse{
+		AccountId:   accountId,
+		Expirations: expirations,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type ReceiptDiscrepancy struct {
+	ReceiptId        string `json:"receiptId"`
+	LedgerPoints     int    `json:"ledgerPoints"`
+	RecomputedPoints int    `json:"recomputedPoints"`
+}
+
+type ReconciliationResponse struct {
+	AccountId      string               `json:"accountId"`
+	Reconciled     bool                 `json:"reconciled"`
+	LedgerBalanced bool                 `json:"ledgerBalanced"`
+	Discrepancies  []ReceiptDiscrepancy `json:"discrepancies"`
+}
+
+func SendReconciliationResponse(accountId string, ledgerBalanced bool, discrepancies []ReceiptDiscrepancy, response http.ResponseWriter) {
+	responseStruct := ReconciliationResponse{
+		AccountId:      accountId,
+		Reconciled:     ledgerBalanced && len(discrepancies) == 0,
+		LedgerBalanced: ledgerBalanced,
+		Discrepancies:  discrepancies,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type BreakdownResponse struct {
+	Id string `json:"id"`
+	receipt_processor.Breakdown
+}
+
+func SendBreakdownResponse(id string, breakdown receipt_processor.Breakdown, response http.ResponseWriter) {
+	responseStruct := BreakdownResponse{
+		Id:        id,
+		Breakdown: breakdown,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type ProcessedReceiptResponse struct {
+	BreakdownResponse
+	Account *BalanceResponse `json:"account,omitempty"`
+}
+
+func SendProcessedReceiptResponse(id string, breakdown receipt_processor.Breakdown, account *BalanceResponse, created bool, response http.ResponseWriter) {
+	responseStruct := ProcessedReceiptResponse{
+		BreakdownResponse: BreakdownResponse{
+			Id:        id,
+			Breakdown: breakdown,
+		},
+		Account: account,
+	}
+	// 201 when this request stored the receipt, 200 when it returned an existing one
+	statusCode := http.StatusOK
+	if created {
+		statusCode = http.StatusCreated
+	}
+	Respond(response, statusCode, responseStruct)
+}
+
+type DraftResponse struct {
+	receipt_parser.Draft
+	Valid bool `json:"valid"`
+}
+
+func SendDraftResponse(draft receipt_parser.Draft, valid bool, response http.ResponseWriter) {
+	responseStruct := DraftResponse{
+		Draft: draft,
+		Valid: valid,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type CampaignsResponse struct {
+	Campaigns []campaign.Campaign `json:"campaigns"`
+}
+
+func SendCampaignsResponse(campaigns []campaign.Campaign, response http.ResponseWriter) {
+	responseStruct := CampaignsResponse{
+		Campaigns: campaigns,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+func SendCampaignResponse(campaign campaign.Campaign, response http.ResponseWriter) {
+	sendHttpResponse(campaign, response)
+}
+
+type RetailersResponse struct {
+	Retailers []retailer_catalog.Retailer `json:"retailers"`
+}
+
+func SendRetailersResponse(retailers []retailer_catalog.Retailer, response http.ResponseWriter) {
+	responseStruct := RetailersResponse{
+		Retailers: retailers,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+func SendRetailerResponse(retailer retailer_catalog.Retailer, response http.ResponseWriter) {
+	sendHttpResponse(retailer, response)
+}
+
+type UnknownRetailersResponse struct {
+	Retailers []retailer_catalog.UnknownRetailer `json:"retailers"`
+}
+
+func SendUnknownRetailersResponse(retailers []retailer_catalog.UnknownRetailer, response http.ResponseWriter) {
+	responseStruct := UnknownRetailersResponse{
+		Retailers: retailers,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type ProductsResponse struct {
+	Products []product_catalog.Product `json:"products"`
+}
+
+func SendProductsResponse(products []product_catalog.Product, response http.ResponseWriter) {
+	responseStruct := ProductsResponse{
+		Products: products,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+func SendProductResponse(product product_catalog.Product, response http.ResponseWriter) {
+	sendHttpResponse(product, response)
+}
+
+type ItemRulesResponse struct {
+	ItemRules []product_catalog.ItemRule `json:"itemRules"`
+}
+
+func SendItemRulesResponse(rules []product_catalog.ItemRule, response http.ResponseWriter) {
+	responseStruct := ItemRulesResponse{
+		ItemRules: rules,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+func SendItemRuleResponse(rule product_catalog.ItemRule, response http.ResponseWriter) {
+	sendHttpResponse(rule, response)
+}
+
+type WebhooksResponse struct {
+	Webhooks []webhook.Subscription `json:"webhooks"`
+}
+
+func SendWebhooksResponse(subscriptions []webhook.Subscription, response http.ResponseWriter) {
+	responseStruct := WebhooksResponse{
+		Webhooks: subscriptions,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+func SendWebhookResponse(subscription webhook.Subscription, response http.ResponseWriter) {
+	sendHttpResponse(subscription, response)
+}
 
-	response.WriteHeader(http.StatusOK)
-	response.Header().Set("Content-Type", "application/json")
-	response.Write(responseBody)
+type DeliveriesResponse struct {
+	Deliveries []webhook.Delivery `json:"deliveries"`
 }
 
-func HandleBadRequestError(response http.ResponseWriter, errorMsg string) {
+func SendDeliveriesResponse(deliveries []webhook.Delivery, response http.ResponseWriter) {
+	responseStruct := DeliveriesResponse{
+		Deliveries: deliveries,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+type DeadLettersResponse struct {
+	DeadLetters []webhook.DeadLetter `json:"deadLetters"`
+}
+
+func SendDeadLettersResponse(deadLetters []webhook.DeadLetter, response http.ResponseWriter) {
+	responseStruct := DeadLettersResponse{
+		DeadLetters: deadLetters,
+	}
+	sendHttpResponse(responseStruct, response)
+}
+
+func SendNoContentResponse(response http.ResponseWriter) {
+	Respond(response, http.StatusNoContent, nil)
+}
+
+func sendHttpResponse(responseStruct interface{}, response http.ResponseWriter) {
+	Respond(response, http.StatusOK, responseStruct)
+}
+
+func HandleBadRequestError(response http.ResponseWriter, code ErrorCode, errorMsg string) {
 	if errorMsg == "" {
 		errorMsg = "The request is invalid"
 	}
-	handleClientError(response, errorMsg, http.StatusBadRequest)
+	sendProblem(response, code, http.StatusBadRequest, errorMsg)
 }
 
-func HandleNotFoundError(response http.ResponseWriter, errorMsg string) {
+func HandleNotFoundError(response http.ResponseWriter, code ErrorCode, errorMsg string) {
 	if errorMsg == "" {
 		errorMsg = "The request resource was not found"
 	}
-	handleClientError(response, errorMsg, http.StatusNotFound)
+	sendProblem(response, code, http.StatusNotFound, errorMsg)
 }
 
-func HandleMethodNotAllowed(response http.ResponseWriter) {
-	handleClientError(response,
-		"This method is not allowed on this endpoint",
-		http.StatusMethodNotAllowed)
+func HandleGoneError(response http.ResponseWriter, code ErrorCode, errorMsg string) {
+	if errorMsg == "" {
+		errorMsg = "The requested resource has been deleted"
+	}
+	sendProblem(response, code, http.StatusGone, errorMsg)
 }
 
-func HandleInternalServerError(response http.ResponseWriter) {
-	handleClientError(response,
-		"Internal Server Error", 
-		http.StatusInternalServerError)
+func HandleConflictError(response http.ResponseWriter, code ErrorCode, errorMsg string) {
+	if errorMsg == "" {
+		errorMsg = "The request conflicts with the current state of the resource"
+	}
+	sendProblem(response, code, http.StatusConflict, errorMsg)
+}
+
+func HandleUnprocessableEntityError(response http.ResponseWriter, code ErrorCode, errorMsg string) {
+	if errorMsg == "" {
+		errorMsg = "The request can't be processed"
+	}
+	sendProblem(response, code, http.StatusUnprocessableEntity, errorMsg)
 }
 
-func handleClientError(response http.ResponseWriter, errorMsg string, statusCode int) {
-	errorResponse := map[string]string{"Error": errorMsg}
+func HandlePayloadTooLargeError(response http.ResponseWriter, errorMsg string) {
+	if errorMsg == "" {
+		errorMsg = "The request body is too large"
+	}
+	sendProblem(response, ErrorCodeBodyTooLarge, http.StatusRequestEntityTooLarge, errorMsg)
+}
 
-	jsonResponse, err := json.Marshal(errorResponse)
-	if err != nil {
-		HandleInternalServerError(response)
-		return
+func HandleUnsupportedMediaTypeError(response http.ResponseWriter, errorMsg string) {
+	if errorMsg == "" {
+		errorMsg = "The request body's media type is not supported"
 	}
+	sendProblem(response, ErrorCodeUnsupportedMediaType, http.StatusUnsupportedMediaType, errorMsg)
+}
 
-	response.WriteHeader(statusCode)
-	response.Header().Set("Content-Type", "application/json")
-	response.Write(jsonResponse)
+func HandleNotAcceptable(response http.ResponseWriter) {
+	sendProblem(response, ErrorCodeNotAcceptable, http.StatusNotAcceptable,
+		"Responses are available as application/json, application/msgpack or application/cbor")
 }
 
-func HandleDuplicateReceipt(response http.ResponseWriter, errorMsg string) {
-	handleClientError(response,
-		"This receipt already exists",
-		http.StatusBadRequest)
+func HandleRouteNotFound(response http.ResponseWriter) {
+	sendProblem(response, ErrorCodeRouteNotFound, http.StatusNotFound,
+		"There is no endpoint at this path")
+}
+
+func HandleMethodNotAllowed(response http.ResponseWriter) {
+	sendProblem(response, ErrorCodeMethodNotAllowed, http.StatusMethodNotAllowed,
+		"This method is not allowed on this endpoint")
+}
+
+func HandleInternalServerError(response http.ResponseWriter) {
+	sendProblem(response, ErrorCodeInternalError, http.StatusInternalServerError,
+		"Internal Server Error")
+}
+
+func HandleDuplicateReceipt(response http.ResponseWriter, id string, errorMsg string) {
+	if errorMsg == "" {
+		errorMsg = "This receipt already exists"
+	}
+	problem := NewProblem(ErrorCodeDuplicateReceipt, http.StatusConflict, errorMsg, response.Header().Get(RequestIdHeader))
+	problem.Id = id
+	writeProblem(response, problem)
 }
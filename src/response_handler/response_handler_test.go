@@ -0,0 +1,197 @@
+package receipt_manager_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	rh "receipt_manager/response_handler"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestProblemResponses(test *testing.T) {
+	testCases := []struct {
+		respond            func(response http.ResponseWriter)
+		expectedStatusCode int
+		expectedCode       rh.ErrorCode
+		expectedDetail     string
+		expectedId         string
+	}{
+		{
+			respond: func(response http.ResponseWriter) {
+				rh.HandleBadRequestError(response, rh.ErrorCodeMissingFields, "Receipt is missing required data fields")
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedCode:       rh.ErrorCodeMissingFields,
+			expectedDetail:     "Receipt is missing required data fields",
+		},
+		{
+			respond: func(response http.ResponseWriter) {
+				rh.HandleDuplicateReceipt(response, "receipt-1", "Receipt already exists")
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedCode:       rh.ErrorCodeDuplicateReceipt,
+			expectedDetail:     "Receipt already exists",
+			expectedId:         "receipt-1",
+		},
+		{
+			respond:            rh.HandleMethodNotAllowed,
+			expectedStatusCode: http.StatusMethodNotAllowed,
+			expectedCode:       rh.ErrorCodeMethodNotAllowed,
+			expectedDetail:     "This method is not allowed on this endpoint",
+		},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		recorder.Header().Set(rh.RequestIdHeader, "request-42")
+		testCase.respond(recorder)
+
+		if recorder.Code != testCase.expectedStatusCode {
+			test.Errorf("Code '%s', got status %d, but expected %d", testCase.expectedCode, recorder.Code, testCase.expectedStatusCode)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "application/problem+json" {
+			test.Errorf("Code '%s', got content type '%s', but expected application/problem+json", testCase.expectedCode, contentType)
+		}
+
+		problem := rh.Problem{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+			test.Fatalf("Code '%s', body failed to decode with error: %v", testCase.expectedCode, err)
+		}
+		expectedProblem := rh.NewProblem(testCase.expectedCode, testCase.expectedStatusCode, testCase.expectedDetail, "request-42")
+		expectedProblem.Id = testCase.expectedId
+		if problem != expectedProblem {
+			test.Errorf("Code '%s', got %+v, but expected %+v", testCase.expectedCode, problem, expectedProblem)
+		}
+		if problem.Instance != "urn:request:request-42" {
+			test.Errorf("Code '%s', got instance '%s', but expected urn:request:request-42", testCase.expectedCode, problem.Instance)
+		}
+	}
+}
+
+func TestLegacyErrorResponses(test *testing.T) {
+	// Writers wrapped around the legacy one still get the legacy shape
+	recorder := httptest.NewRecorder()
+	response := rh.NegotiateResponses(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		rh.HandleDuplicateReceipt(response, "receipt-1", "Receipt already exists")
+	}))
+	response.ServeHTTP(rh.LegacyErrors(recorder), httptest.NewRequest(http.MethodPost, "/receipts/process", nil))
+
+	if recorder.Code != http.StatusConflict {
+		test.Errorf("Got status %d, but expected %d", recorder.Code, http.StatusConflict)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		test.Errorf("Got content type '%s', but expected application/json", contentType)
+	}
+	if body := recorder.Body.String(); body != `{"Error":"Receipt already exists"}` {
+		test.Errorf("Got body '%s', but expected the legacy error", body)
+	}
+}
+
+func TestNegotiate(test *testing.T) {
+	testCases := []struct {
+		accept             string
+		acceptEncoding     string
+		expectedMediaType  string
+		expectedGzip       bool
+		expectedAcceptable bool
+	}{
+		{"", "", "application/json", false, true},
+		{"*/*", "gzip, deflate", "application/json", true, true},
+		{"application/msgpack", "", "application/msgpack", false, true},
+		{"application/json;q=0.5, application/cbor", "identity", "application/cbor", false, true},
+		{"application/cbor;q=0.5, */*;q=0.1", "", "application/cbor", false, true},
+		{"application/*", "gzip;q=0", "application/json", false, true},
+		{"*/*, application/json;q=0", "", "application/msgpack", false, true},
+		{"text/html", "gzip", "application/json", true, false},
+	}
+
+	for _, testCase := range testCases {
+		negotiation := rh.Negotiate(testCase.accept, testCase.acceptEncoding)
+		acceptable := negotiation.Acceptable
+		if acceptable != testCase.expectedAcceptable {
+			test.Errorf("Accept '%s', got acceptable %t, but expected %t", testCase.accept, acceptable, testCase.expectedAcceptable)
+		}
+		if !acceptable {
+			continue
+		}
+		if negotiation.Format.MediaType != testCase.expectedMediaType {
+			test.Errorf("Accept '%s', got '%s', but expected '%s'", testCase.accept, negotiation.Format.MediaType, testCase.expectedMediaType)
+		}
+		if negotiation.Gzip != testCase.expectedGzip {
+			test.Errorf("Accept-Encoding '%s', got gzip %t, but expected %t", testCase.acceptEncoding, negotiation.Gzip, testCase.expectedGzip)
+		}
+	}
+}
+
+func TestNegotiatedResponses(test *testing.T) {
+	testCases := []struct {
+		accept         string
+		acceptEncoding string
+		decode         func(body []byte, target interface{}) error
+	}{
+		{"", "", json.Unmarshal},
+		{"application/json", "gzip", json.Unmarshal},
+		{"application/msgpack", "", func(body []byte, target interface{}) error {
+			decoder := msgpack.NewDecoder(bytes.NewReader(body))
+			decoder.SetCustomStructTag("json")
+			return decoder.Decode(target)
+		}},
+		{"application/cbor", "gzip", cbor.Unmarshal},
+	}
+
+	handler := rh.NegotiateResponses(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		rh.SendPointsResponse(109, response)
+	}))
+	for _, testCase := range testCases {
+		request := httptest.NewRequest(http.MethodGet, "/receipts/receipt-1/points", nil)
+		request.Header.Set("Accept", testCase.accept)
+		request.Header.Set("Accept-Encoding", testCase.acceptEncoding)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusOK {
+			test.Fatalf("Accept '%s', got status %d, but expected 200", testCase.accept, recorder.Code)
+		}
+		expectedMediaType := testCase.accept
+		if expectedMediaType == "" {
+			expectedMediaType = "application/json"
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != expectedMediaType {
+			test.Errorf("Accept '%s', got content type '%s', but expected '%s'", testCase.accept, contentType, expectedMediaType)
+		}
+
+		var body io.Reader = recorder.Body
+		if testCase.acceptEncoding == "gzip" {
+			if encoding := recorder.Header().Get("Content-Encoding"); encoding != "gzip" {
+				test.Fatalf("Accept '%s', got content encoding '%s', but expected gzip", testCase.accept, encoding)
+			}
+			gzipReader, err := gzip.NewReader(recorder.Body)
+			if err != nil {
+				test.Fatalf("Accept '%s', body is not gzip: %v", testCase.accept, err)
+			}
+			body = gzipReader
+		}
+		bodyBytes, _ := io.ReadAll(body)
+		points := rh.PointsResponse{}
+		if err := testCase.decode(bodyBytes, &points); err != nil {
+			test.Fatalf("Accept '%s', body failed to decode with error: %v", testCase.accept, err)
+		}
+		if points.Points != 109 {
+			test.Errorf("Accept '%s', got %d points, but expected 109", testCase.accept, points.Points)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/receipts/receipt-1/points", nil)
+	request.Header.Set("Accept", "text/html")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotAcceptable {
+		test.Errorf("Accept 'text/html', got status %d, but expected 406", recorder.Code)
+	}
+}
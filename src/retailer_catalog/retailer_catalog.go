@@ -0,0 +1,269 @@
+package receipt_manager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	name_policy "receipt_manager/name_policy"
+	purchase_time "receipt_manager/purchase_time"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRetailerNotFound = errors.New("retailer not found")
+	ErrInvalidRetailer  = errors.New("retailer is invalid")
+	ErrRetailerConflict = errors.New("retailer name or alias is already in the catalog")
+)
+
+type Retailer struct {
+	Id              string             `json:"id"`
+	CanonicalName   string             `json:"canonicalName"`
+	Aliases         []string           `json:"aliases,omitempty"`
+	Category        string             `json:"category,omitempty"`
+	Timezone        string             `json:"timezone,omitempty"`
+	Multiplier      float64            `json:"multiplier,omitempty"`
+	RuleMultipliers map[string]float64 `json:"ruleMultipliers,omitempty"`
+}
+
+// The base rules RuleMultipliers can scale, named as point breakdowns name them
+var RuleNames = []string{
+	"retailerName",
+	"roundDollarAmount",
+	"multipleOfQuarter",
+	"everyTwoItems",
+	"descriptionLength",
+	"oddPurchaseDate",
+	"purchaseTime",
+}
+
+// Only the most recently seen unknown names are kept, however many distinct ones are submitted
+const maxUnknownRetailers = 1000
+
+type UnknownRetailer struct {
+	Name      string    `json:"name"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type Catalog struct {
+	mutex          sync.RWMutex
+	retailers      []Retailer
+	lastRetailerId int
+	unknown        map[string]UnknownRetailer
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		unknown: make(map[string]UnknownRetailer),
+	}
+}
+
+func LoadCatalogFile(path string) (*Catalog, error) {
+	// The file holds a JSON array of retailers; ids are assigned on load
+	catalogData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	retailers := []Retailer{}
+	if err := json.Unmarshal(catalogData, &retailers); err != nil {
+		return nil, err
+	}
+
+	catalog := NewCatalog()
+	for _, retailer := range retailers {
+		if _, err := catalog.Add(retailer); err != nil {
+			return nil, err
+		}
+	}
+	return catalog, nil
+}
+
+func NormalizeName(name string) string {
+	// Catalog matching ignores case, runs of whitespace and how accents were composed
+	return strings.ToLower(strings.Join(strings.Fields(name_policy.Normalize(name)), " "))
+}
+
+func (catalog *Catalog) Add(retailer Retailer) (Retailer, error) {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	if err := catalog.validate(retailer, ""); err != nil {
+		return Retailer{}, err
+	}
+
+	catalog.lastRetailerId++
+	retailer.Id = "retailer-" + strconv.Itoa(catalog.lastRetailerId)
+	catalog.retailers = append(catalog.retailers, retailer)
+	catalog.forgetUnknown(retailer)
+	return retailer, nil
+}
+
+func (catalog *Catalog) Update(id string, retailer Retailer) (Retailer, error) {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	for index, storedRetailer := range catalog.retailers {
+		if storedRetailer.Id != id {
+			continue
+		}
+		if err := catalog.validate(retailer, id); err != nil {
+			return Retailer{}, err
+		}
+		retailer.Id = id
+		catalog.retailers[index] = retailer
+		catalog.forgetUnknown(retailer)
+		return retailer, nil
+	}
+	return Retailer{}, ErrRetailerNotFound
+}
+
+func (catalog *Catalog) Delete(id string) error {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	for index, storedRetailer := range catalog.retailers {
+		if storedRetailer.Id == id {
+			catalog.retailers = append(catalog.retailers[:index:index], catalog.retailers[index+1:]...)
+			return nil
+		}
+	}
+	return ErrRetailerNotFound
+}
+
+func (catalog *Catalog) Get(id string) (Retailer, error) {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	for _, storedRetailer := range catalog.retailers {
+		if storedRetailer.Id == id {
+			return storedRetailer, nil
+		}
+	}
+	return Retailer{}, ErrRetailerNotFound
+}
+
+func (catalog *Catalog) List() []Retailer {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	return append([]Retailer{}, catalog.retailers...)
+}
+
+func (catalog *Catalog) Lookup(name string) (Retailer, bool) {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	return catalog.lookup(NormalizeName(name))
+}
+
+func (catalog *Catalog) RecordUnknown(name string) {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	// Names of stored receipts missing from the catalog are tallied so they can be curated later
+	normalizedName := NormalizeName(name)
+	if _, retailerKnown := catalog.lookup(normalizedName); retailerKnown {
+		return
+	}
+
+	now := time.Now().UTC()
+	unknownRetailer, seenBefore := catalog.unknown[normalizedName]
+	if !seenBefore {
+		if len(catalog.unknown) >= maxUnknownRetailers {
+			catalog.forgetLeastRecentUnknown()
+		}
+		unknownRetailer = UnknownRetailer{Name: strings.TrimSpace(name), FirstSeen: now}
+	}
+	unknownRetailer.Count++
+	unknownRetailer.LastSeen = now
+	catalog.unknown[normalizedName] = unknownRetailer
+}
+
+func (catalog *Catalog) Unknown() []UnknownRetailer {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	unknownRetailers := []UnknownRetailer{}
+	for _, unknownRetailer := range catalog.unknown {
+		unknownRetailers = append(unknownRetailers, unknownRetailer)
+	}
+	sort.Slice(unknownRetailers, func(i, j int) bool {
+		if unknownRetailers[i].Count != unknownRetailers[j].Count {
+			return unknownRetailers[i].Count > unknownRetailers[j].Count
+		}
+		return unknownRetailers[i].Name < unknownRetailers[j].Name
+	})
+	return unknownRetailers
+}
+
+func (catalog *Catalog) lookup(normalizedName string) (Retailer, bool) {
+	for _, retailer := range catalog.retailers {
+		for _, name := range retailerNames(retailer) {
+			if NormalizeName(name) == normalizedName {
+				return retailer, true
+			}
+		}
+	}
+	return Retailer{}, false
+}
+
+func (catalog *Catalog) validate(retailer Retailer, id string) error {
+	// A multiplier only ever adds points, so one below 1 would silently do nothing
+	if NormalizeName(retailer.CanonicalName) == "" || (retailer.Multiplier != 0 && retailer.Multiplier < 1) {
+		return ErrInvalidRetailer
+	}
+	if retailer.Timezone != "" {
+		if _, err := purchase_time.ParseZone(retailer.Timezone); err != nil {
+			return ErrInvalidRetailer
+		}
+	}
+	for ruleName, ruleMultiplier := range retailer.RuleMultipliers {
+		if ruleMultiplier < 0 || !isRuleName(ruleName) {
+			return ErrInvalidRetailer
+		}
+	}
+
+	// A name may only ever resolve to a single catalog entry
+	for _, name := range retailerNames(retailer) {
+		existingRetailer, nameTaken := catalog.lookup(NormalizeName(name))
+		if nameTaken && existingRetailer.Id != id {
+			return ErrRetailerConflict
+		}
+	}
+	return nil
+}
+
+func (catalog *Catalog) forgetUnknown(retailer Retailer) {
+	for _, name := range retailerNames(retailer) {
+		delete(catalog.unknown, NormalizeName(name))
+	}
+}
+
+func (catalog *Catalog) forgetLeastRecentUnknown() {
+	leastRecentName := ""
+	for normalizedName, unknownRetailer := range catalog.unknown {
+		if leastRecentName == "" || unknownRetailer.LastSeen.Before(catalog.unknown[leastRecentName].LastSeen) {
+			leastRecentName = normalizedName
+		}
+	}
+	delete(catalog.unknown, leastRecentName)
+}
+
+func isRuleName(name string) bool {
+	for _, ruleName := range RuleNames {
+		if ruleName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func retailerNames(retailer Retailer) []string {
+	return append([]string{retailer.CanonicalName}, retailer.Aliases...)
+}
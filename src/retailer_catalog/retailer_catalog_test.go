@@ -0,0 +1,124 @@
+package receipt_manager_test
+
+import (
+	rc "receipt_manager/retailer_catalog"
+	"strconv"
+	"testing"
+)
+
+func TestLookup(test *testing.T) {
+	catalog := rc.NewCatalog()
+	catalog.Add(rc.Retailer{CanonicalName: "Walgreens", Aliases: []string{"Walgreens Pharmacy"}, Category: "pharmacy"})
+
+	testCases := []struct {
+		name              string
+		expectedKnown     bool
+		expectedCanonical string
+	}{
+		{name: "Walgreens", expectedKnown: true, expectedCanonical: "Walgreens"},
+		{name: "  WALGREENS  ", expectedKnown: true, expectedCanonical: "Walgreens"},
+		{name: "walgreens   pharmacy", expectedKnown: true, expectedCanonical: "Walgreens"},
+		{name: "Walmart", expectedKnown: false},
+	}
+
+	for _, testCase := range testCases {
+		retailer, known := catalog.Lookup(testCase.name)
+		if known != testCase.expectedKnown {
+			test.Errorf("Retailer '%s', known is %t, but expected %t", testCase.name, known, testCase.expectedKnown)
+		}
+		if retailer.CanonicalName != testCase.expectedCanonical {
+			test.Errorf("Retailer '%s', got canonical name '%s', but expected '%s'",
+				testCase.name, retailer.CanonicalName, testCase.expectedCanonical)
+		}
+	}
+}
+
+func TestUnknownRetailers(test *testing.T) {
+	catalog := rc.NewCatalog()
+	catalog.RecordUnknown("Corner Shop")
+	catalog.RecordUnknown("corner  shop")
+	catalog.RecordUnknown("Bodega")
+
+	unknownRetailers := catalog.Unknown()
+	if len(unknownRetailers) != 2 {
+		test.Fatalf("Got unknown retailers %+v, but expected %d", unknownRetailers, 2)
+	}
+	if unknownRetailers[0].Name != "Corner Shop" || unknownRetailers[0].Count != 2 {
+		test.Errorf("Got %+v as the most frequent unknown retailer, but expected 'Corner Shop' seen twice",
+			unknownRetailers[0])
+	}
+
+	catalog.Add(rc.Retailer{CanonicalName: "Corner Shop"})
+	if len(catalog.Unknown()) != 1 {
+		test.Errorf("Adding a retailer to the catalog didn't remove it from the unknown retailers")
+	}
+}
+
+func TestUnknownRetailersCapped(test *testing.T) {
+	// Past the cap, the least recently seen name makes room for a new one
+	catalog := rc.NewCatalog()
+	for index := 0; index <= 1000; index++ {
+		catalog.RecordUnknown("Shop " + strconv.Itoa(index))
+	}
+
+	unknownRetailers := catalog.Unknown()
+	if len(unknownRetailers) != 1000 {
+		test.Errorf("Got %d unknown retailers, but expected %d", len(unknownRetailers), 1000)
+	}
+	for _, unknownRetailer := range unknownRetailers {
+		if unknownRetailer.Name == "Shop 1000" {
+			return
+		}
+	}
+	test.Errorf("The most recently seen unknown retailer wasn't kept")
+}
+
+func TestAddConflicts(test *testing.T) {
+	testCases := []struct {
+		retailer    rc.Retailer
+		expectedErr error
+	}{
+		{
+			retailer:    rc.Retailer{CanonicalName: "Target"},
+			expectedErr: nil,
+		},
+		{
+			retailer:    rc.Retailer{CanonicalName: "Walgreens Pharmacy"},
+			expectedErr: rc.ErrRetailerConflict,
+		},
+		{
+			retailer:    rc.Retailer{CanonicalName: "Target", Aliases: []string{"WALGREENS"}},
+			expectedErr: rc.ErrRetailerConflict,
+		},
+		{
+			retailer:    rc.Retailer{CanonicalName: "  "},
+			expectedErr: rc.ErrInvalidRetailer,
+		},
+		{
+			retailer:    rc.Retailer{CanonicalName: "Target", Multiplier: -1},
+			expectedErr: rc.ErrInvalidRetailer,
+		},
+		{
+			retailer:    rc.Retailer{CanonicalName: "Target", Multiplier: 0.5},
+			expectedErr: rc.ErrInvalidRetailer,
+		},
+		{
+			retailer:    rc.Retailer{CanonicalName: "Target", Multiplier: 1.5, RuleMultipliers: map[string]float64{"purchaseTime": 0}},
+			expectedErr: nil,
+		},
+		{
+			retailer:    rc.Retailer{CanonicalName: "Target", RuleMultipliers: map[string]float64{"purchaseHour": 2}},
+			expectedErr: rc.ErrInvalidRetailer,
+		},
+	}
+
+	for _, testCase := range testCases {
+		catalog := rc.NewCatalog()
+		catalog.Add(rc.Retailer{CanonicalName: "Walgreens", Aliases: []string{"Walgreens Pharmacy"}})
+
+		_, err := catalog.Add(testCase.retailer)
+		if err != testCase.expectedErr {
+			test.Errorf("Retailer %+v, got error %v, but expected %v", testCase.retailer, err, testCase.expectedErr)
+		}
+	}
+}
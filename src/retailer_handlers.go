@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	response_handler "receipt_manager/response_handler"
+	retailer_catalog "receipt_manager/retailer_catalog"
+
+	"github.com/gorilla/mux"
+)
+
+func retailersHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		response_handler.SendRetailersResponse(retailerCatalog.List(), response)
+	case http.MethodPost:
+		retailer, decoded := decodeRetailer(response, request)
+		if !decoded {
+			return
+		}
+		storedRetailer, catalogError := retailerCatalog.Add(retailer)
+		if catalogError != nil {
+			handleCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendRetailerResponse(storedRetailer, response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func retailerHandler(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	switch request.Method {
+	case http.MethodGet:
+		storedRetailer, catalogError := retailerCatalog.Get(id)
+		if catalogError != nil {
+			handleCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendRetailerResponse(storedRetailer, response)
+	case http.MethodPut:
+		retailer, decoded := decodeRetailer(response, request)
+		if !decoded {
+			return
+		}
+		storedRetailer, catalogError := retailerCatalog.Update(id, retailer)
+		if catalogError != nil {
+			handleCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendRetailerResponse(storedRetailer, response)
+	case http.MethodDelete:
+		catalogError := retailerCatalog.Delete(id)
+		if catalogError != nil {
+			handleCatalogError(response, catalogError)
+			return
+		}
+		response_handler.SendNoContentResponse(response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func getUnknownRetailersHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	response_handler.SendUnknownRetailersResponse(retailerCatalog.Unknown(), response)
+}
+
+func decodeRetailer(response http.ResponseWriter, request *http.Request) (retailer_catalog.Retailer, bool) {
+	retailer := retailer_catalog.Retailer{}
+	decoderError := json.NewDecoder(request.Body).Decode(&retailer)
+	if decoderError != nil {
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Retailer data decoding failed")
+		return retailer, false
+	}
+	return retailer, true
+}
+
+func handleCatalogError(response http.ResponseWriter, catalogError error) {
+	switch catalogError {
+	case retailer_catalog.ErrRetailerNotFound:
+		response_handler.HandleNotFoundError(response, response_handler.ErrorCodeRetailerNotFound, "The requested retailer doesn't exist")
+	case retailer_catalog.ErrInvalidRetailer:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidRetailer, "Retailer needs a canonical name and non-negative multipliers")
+	case retailer_catalog.ErrRetailerConflict:
+		response_handler.HandleConflictError(response, response_handler.ErrorCodeRetailerConflict, "A retailer name or alias is already in the catalog")
+	default:
+		response_handler.HandleInternalServerError(response)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUnknownRetailersOnlyCountStoredReceipts(test *testing.T) {
+	// Duplicates, rejected receipts and amendments don't inflate the tally
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	location := serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`}`).Header().Get("Location")
+	serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`}`)
+	serveTestRequest(router, "POST", "/v1/receipts/process", strings.Replace(specReceipt, `"6.75"`, `"abc"`, 1)+`}`)
+	if recorder := serveTestRequest(router, "PATCH", location, `{"purchaseTime": "14:01"}`); recorder.Code != http.StatusOK {
+		test.Fatalf("Amending the receipt, got status %d and %s", recorder.Code, recorder.Body.String())
+	}
+
+	unknownRetailers := retailerCatalog.Unknown()
+	if len(unknownRetailers) != 1 || unknownRetailers[0].Count != 1 {
+		test.Errorf("Got unknown retailers %+v, but expected 'Target' seen once", unknownRetailers)
+	}
+}
@@ -0,0 +1,69 @@
+package receipt_manager_test
+
+import (
+	"errors"
+	webhook "receipt_manager/webhook"
+	"testing"
+	"time"
+)
+
+func TestValidate(test *testing.T) {
+	testCases := []struct {
+		name          string
+		subscription  webhook.Subscription
+		expectedError error
+	}{
+		{"valid", webhook.Subscription{Url: "https://example.com/hooks", Events: []string{webhook.EventReceiptProcessed}}, nil},
+		{"every event", webhook.Subscription{Url: "http://localhost:9000", Events: webhook.EventTypes}, nil},
+		{"relative url", webhook.Subscription{Url: "/hooks", Events: []string{webhook.EventReceiptProcessed}}, webhook.ErrInvalidSubscription},
+		{"other scheme", webhook.Subscription{Url: "ftp://example.com", Events: []string{webhook.EventReceiptProcessed}}, webhook.ErrInvalidSubscription},
+		{"no events", webhook.Subscription{Url: "https://example.com/hooks"}, webhook.ErrInvalidSubscription},
+		{"unknown event", webhook.Subscription{Url: "https://example.com/hooks", Events: []string{"receipt.eaten"}}, webhook.ErrInvalidSubscription},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.subscription.Validate()
+		if err != testCase.expectedError {
+			test.Errorf("Case '%s', got error %v, but expected %v", testCase.name, err, testCase.expectedError)
+		}
+	}
+}
+
+func TestSignAndVerify(test *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":"evt_1","type":"receipt.processed"}`)
+	header := webhook.Sign("whsec_test", signedAt, payload)
+
+	testCases := []struct {
+		name          string
+		secret        string
+		header        string
+		payload       []byte
+		now           time.Time
+		expectedError error
+	}{
+		{"valid", "whsec_test", header, payload, signedAt.Add(time.Minute), nil},
+		{"other secret", "whsec_other", header, payload, signedAt, webhook.ErrInvalidSignature},
+		{"altered payload", "whsec_test", header, []byte(`{"id":"evt_1","type":"receipt.deleted"}`), signedAt, webhook.ErrInvalidSignature},
+		{"stale", "whsec_test", header, payload, signedAt.Add(time.Hour), webhook.ErrInvalidSignature},
+		{"malformed", "whsec_test", "v1=abc", payload, signedAt, webhook.ErrInvalidSignature},
+	}
+
+	for _, testCase := range testCases {
+		err := webhook.Verify(testCase.secret, testCase.header, testCase.payload, 5*time.Minute, testCase.now)
+		if !errors.Is(err, testCase.expectedError) {
+			test.Errorf("Case '%s', got error %v, but expected %v", testCase.name, err, testCase.expectedError)
+		}
+	}
+}
+
+func TestBackoffDelay(test *testing.T) {
+	backoff := webhook.Backoff{MaxAttempts: 6, InitialDelay: time.Second, MaxDelay: 5 * time.Second}
+	expectedDelays := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+
+	for index, expectedDelay := range expectedDelays {
+		if delay := backoff.Delay(index + 1); delay != expectedDelay {
+			test.Errorf("Attempt %d, got delay %s, but expected %s", index+1, delay, expectedDelay)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package receipt_manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	webhook "receipt_manager/webhook"
+	webhook_store "receipt_manager/webhook_store"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var ErrAddressNotAllowed = errors.New("webhook receivers must have a public address")
+
+type Dispatcher struct {
+	store         *webhook_store.WebhookStore
+	client        *http.Client
+	backoff       webhook.Backoff
+	pending       sync.WaitGroup
+	allowLoopback bool
+	mutex         sync.Mutex
+	inFlight      map[string]*flight
+}
+
+// An event still being delivered to at least one subscriber
+type flight struct {
+	accountId  string
+	deliveries int
+	cancelled  bool
+}
+
+func NewDispatcher(store *webhook_store.WebhookStore, backoff webhook.Backoff) *Dispatcher {
+	/*
+		Receivers are named by whoever subscribes, so deliveries only go to public
+		addresses. The check runs on the address actually dialed, after DNS, and
+		redirects aren't followed, so neither can lead to an internal service
+	*/
+	dispatcher := &Dispatcher{store: store, backoff: backoff, inFlight: make(map[string]*flight)}
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dispatcher.checkAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	dispatcher.client = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return dispatcher
+}
+
+func (dispatcher *Dispatcher) SetAllowLoopback(allowLoopback bool) {
+	// For tests, whose receivers listen on 127.0.0.1; set before anything is published
+	dispatcher.allowLoopback = allowLoopback
+}
+
+func (dispatcher *Dispatcher) Publish(eventType string, accountId string, data interface{}) (webhook.Event, error) {
+	/*
+		Sends the event to every subscriber of its type in the background, so
+		publishing never holds up the request that caused it. Each subscriber is
+		retried on its own, and the same signed body is sent on every attempt
+	*/
+	eventId, err := webhook.NewEventId()
+	if err != nil {
+		return webhook.Event{}, err
+	}
+	event := webhook.Event{Id: eventId, Type: eventType, CreatedAt: time.Now().UTC(), Data: data, AccountId: accountId}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return webhook.Event{}, err
+	}
+
+	subscribers := dispatcher.store.Subscribers(eventType)
+	if len(subscribers) > 0 {
+		dispatcher.mutex.Lock()
+		dispatcher.inFlight[event.Id] = &flight{accountId: accountId, deliveries: len(subscribers)}
+		dispatcher.mutex.Unlock()
+	}
+	for _, subscription := range subscribers {
+		dispatcher.pending.Add(1)
+		go func(subscription webhook.Subscription) {
+			defer dispatcher.pending.Done()
+			dispatcher.deliver(subscription, event, payload)
+		}(subscription)
+	}
+	return event, nil
+}
+
+func (dispatcher *Dispatcher) Wait() {
+	// Blocks until every delivery published so far has succeeded or been dead-lettered
+	dispatcher.pending.Wait()
+}
+
+func (dispatcher *Dispatcher) EraseAccount(accountId string) {
+	/*
+		Cancels the remaining attempts of the account's events and drops what was
+		recorded of them. Both happen under the lock that recording takes, so an
+		attempt already underway can't record anything afterwards
+	*/
+	if accountId == "" {
+		return
+	}
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	for _, eventFlight := range dispatcher.inFlight {
+		if eventFlight.accountId == accountId {
+			eventFlight.cancelled = true
+		}
+	}
+	dispatcher.store.EraseAccount(accountId)
+}
+
+func (dispatcher *Dispatcher) deliver(subscription webhook.Subscription, event webhook.Event, payload []byte) {
+	/*
+		Any 2xx answer is a delivery; anything else is retried until the attempts
+		run out, or until the subscription is deleted
+	*/
+	defer dispatcher.land(event.Id)
+	maxAttempts := dispatcher.backoff.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; !dispatcher.cancelled(event.Id) && dispatcher.subscribed(subscription.Id); attempt++ {
+		delivery := webhook.Delivery{
+			SubscriptionId: subscription.Id,
+			EventId:        event.Id,
+			EventType:      event.Type,
+			Attempt:        attempt,
+			AttemptedAt:    time.Now().UTC(),
+			AccountId:      event.AccountId,
+		}
+		delivery.StatusCode, delivery.Error = dispatcher.send(subscription, event, payload)
+		delivery.Succeeded = delivery.Error == ""
+
+		if delivery.Succeeded || attempt == maxAttempts {
+			var deadLetter *webhook.DeadLetter
+			if !delivery.Succeeded {
+				deadLetter = &webhook.DeadLetter{
+					SubscriptionId: subscription.Id,
+					Url:            subscription.Url,
+					Event:          event,
+					Attempts:       attempt,
+					LastError:      delivery.Error,
+					FailedAt:       delivery.AttemptedAt,
+				}
+			}
+			dispatcher.record(delivery, deadLetter)
+			return
+		}
+
+		delay := dispatcher.backoff.Delay(attempt)
+		nextAttemptAt := delivery.AttemptedAt.Add(delay)
+		delivery.NextAttemptAt = &nextAttemptAt
+		dispatcher.record(delivery, nil)
+		time.Sleep(delay)
+	}
+}
+
+func (dispatcher *Dispatcher) record(delivery webhook.Delivery, deadLetter *webhook.DeadLetter) {
+	// Nothing is recorded for an event whose account was erased while it was being sent
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	if eventFlight := dispatcher.inFlight[delivery.EventId]; eventFlight != nil && eventFlight.cancelled {
+		return
+	}
+	dispatcher.store.RecordDelivery(delivery)
+	if deadLetter != nil {
+		dispatcher.store.AddDeadLetter(*deadLetter)
+	}
+}
+
+func (dispatcher *Dispatcher) cancelled(eventId string) bool {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	eventFlight := dispatcher.inFlight[eventId]
+	return eventFlight != nil && eventFlight.cancelled
+}
+
+func (dispatcher *Dispatcher) subscribed(subscriptionId string) bool {
+	_, err := dispatcher.store.Get(subscriptionId)
+	return err == nil
+}
+
+func (dispatcher *Dispatcher) land(eventId string) {
+	// The event is forgotten once its last subscriber's delivery has finished
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	eventFlight := dispatcher.inFlight[eventId]
+	if eventFlight == nil {
+		return
+	}
+	eventFlight.deliveries--
+	if eventFlight.deliveries <= 0 {
+		delete(dispatcher.inFlight, eventId)
+	}
+}
+
+func (dispatcher *Dispatcher) checkAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ErrAddressNotAllowed
+	}
+	if ip.IsLoopback() && dispatcher.allowLoopback {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return ErrAddressNotAllowed
+	}
+	return nil
+}
+
+func (dispatcher *Dispatcher) send(subscription webhook.Subscription, event webhook.Event, payload []byte) (int, string) {
+	request, err := http.NewRequest(http.MethodPost, subscription.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "receipt_manager-webhooks")
+	request.Header.Set(webhook.IdHeader, event.Id)
+	request.Header.Set(webhook.EventHeader, event.Type)
+	request.Header.Set(webhook.SignatureHeader, webhook.Sign(subscription.Secret, time.Now(), payload))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+
+	// Redirects count as failures like any other non-2xx answer
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Sprintf("receiver answered %d", response.StatusCode)
+	}
+	return response.StatusCode, ""
+}
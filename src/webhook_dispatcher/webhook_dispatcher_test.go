@@ -0,0 +1,221 @@
+package receipt_manager_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	webhook "receipt_manager/webhook"
+	webhook_dispatcher "receipt_manager/webhook_dispatcher"
+	webhook_store "receipt_manager/webhook_store"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testBackoff = webhook.Backoff{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond}
+
+type receiver struct {
+	mutex    sync.Mutex
+	failures int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (testReceiver *receiver) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	// Fails the first failures requests, then accepts everything
+	testReceiver.mutex.Lock()
+	defer testReceiver.mutex.Unlock()
+
+	body, _ := io.ReadAll(request.Body)
+	testReceiver.received = append(testReceiver.received, request)
+	testReceiver.bodies = append(testReceiver.bodies, body)
+	if len(testReceiver.received) <= testReceiver.failures {
+		response.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func TestPublish(test *testing.T) {
+	testCases := []struct {
+		name              string
+		failures          int
+		expectedAttempts  int
+		expectedDelivered bool
+	}{
+		{"delivered", 0, 1, true},
+		{"delivered after retries", 2, 3, true},
+		{"dead-lettered", 3, 3, false},
+	}
+
+	for _, testCase := range testCases {
+		testReceiver := &receiver{failures: testCase.failures}
+		server := httptest.NewServer(testReceiver)
+		store := webhook_store.NewWebhookStore()
+		subscription, _ := store.Add(webhook.Subscription{Url: server.URL, Events: []string{webhook.EventReceiptProcessed}})
+		store.Add(webhook.Subscription{Url: server.URL, Events: []string{webhook.EventReceiptDeleted}})
+
+		dispatcher := webhook_dispatcher.NewDispatcher(store, testBackoff)
+		dispatcher.SetAllowLoopback(true)
+		event, publishError := dispatcher.Publish(webhook.EventReceiptProcessed, "", map[string]interface{}{"id": "receipt-1", "points": 42})
+		if publishError != nil {
+			test.Fatalf("Case '%s', publishing failed with error: %v", testCase.name, publishError)
+		}
+		dispatcher.Wait()
+		server.Close()
+
+		// Only the receipt.processed subscriber hears about it, once per attempt
+		if len(testReceiver.received) != testCase.expectedAttempts {
+			test.Errorf("Case '%s', got %d requests, but expected %d", testCase.name, len(testReceiver.received), testCase.expectedAttempts)
+			continue
+		}
+		for index, request := range testReceiver.received {
+			signatureError := webhook.Verify(subscription.Secret, request.Header.Get(webhook.SignatureHeader), testReceiver.bodies[index], time.Minute, time.Now())
+			if signatureError != nil || request.Header.Get(webhook.IdHeader) != event.Id || request.Header.Get(webhook.EventHeader) != webhook.EventReceiptProcessed {
+				test.Errorf("Case '%s', request %d has headers %v, signature error %v", testCase.name, index, request.Header, signatureError)
+			}
+			var received webhook.Event
+			json.Unmarshal(testReceiver.bodies[index], &received)
+			if received.Id != event.Id || received.Type != webhook.EventReceiptProcessed {
+				test.Errorf("Case '%s', got event %v, but expected %s", testCase.name, received, event.Id)
+			}
+		}
+
+		deliveries, _ := store.Deliveries(subscription.Id)
+		if len(deliveries) != testCase.expectedAttempts || deliveries[0].Succeeded != testCase.expectedDelivered {
+			test.Errorf("Case '%s', got deliveries %v", testCase.name, deliveries)
+		}
+		for _, delivery := range deliveries[1:] {
+			if delivery.Succeeded || delivery.StatusCode != http.StatusServiceUnavailable || delivery.NextAttemptAt == nil {
+				test.Errorf("Case '%s', got failed delivery %v", testCase.name, delivery)
+			}
+		}
+
+		deadLetters := store.DeadLetters()
+		if testCase.expectedDelivered != (len(deadLetters) == 0) {
+			test.Errorf("Case '%s', got dead letters %v", testCase.name, deadLetters)
+		}
+		if len(deadLetters) == 1 && (deadLetters[0].Event.Id != event.Id || deadLetters[0].Attempts != testCase.expectedAttempts) {
+			test.Errorf("Case '%s', got dead letter %v", testCase.name, deadLetters[0])
+		}
+	}
+}
+
+func TestPublishUnreachable(test *testing.T) {
+	// A receiver that isn't listening is retried and dead-lettered like one that answers with an error
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	store := webhook_store.NewWebhookStore()
+	subscription, _ := store.Add(webhook.Subscription{Url: server.URL, Events: []string{webhook.EventReceiptDeleted}})
+
+	dispatcher := webhook_dispatcher.NewDispatcher(store, testBackoff)
+	dispatcher.SetAllowLoopback(true)
+	dispatcher.Publish(webhook.EventReceiptDeleted, "", map[string]string{"id": "receipt-1"})
+	dispatcher.Wait()
+
+	deliveries, _ := store.Deliveries(subscription.Id)
+	if len(deliveries) != testBackoff.MaxAttempts || deliveries[0].Error == "" || deliveries[0].StatusCode != 0 {
+		test.Errorf("Got deliveries %v, but expected %d failed attempts", deliveries, testBackoff.MaxAttempts)
+	}
+	if deadLetters := store.DeadLetters(); len(deadLetters) != 1 || deadLetters[0].Url != server.URL {
+		test.Errorf("Got dead letters %v, but expected one for %s", deadLetters, server.URL)
+	}
+}
+
+func TestEraseAccountCancelsRetries(test *testing.T) {
+	// An erased account's events aren't retried, recorded or dead-lettered
+	testReceiver := &receiver{failures: 10}
+	server := httptest.NewServer(testReceiver)
+	defer server.Close()
+	store := webhook_store.NewWebhookStore()
+	subscription, _ := store.Add(webhook.Subscription{Url: server.URL, Events: []string{webhook.EventReceiptDeleted}})
+
+	slowBackoff := webhook.Backoff{MaxAttempts: 3, InitialDelay: 50 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	dispatcher := webhook_dispatcher.NewDispatcher(store, slowBackoff)
+	dispatcher.SetAllowLoopback(true)
+	dispatcher.Publish(webhook.EventReceiptDeleted, "customer-42", map[string]string{"id": "receipt-1", "accountId": "customer-42"})
+	dispatcher.Publish(webhook.EventReceiptDeleted, "customer-43", map[string]string{"id": "receipt-2", "accountId": "customer-43"})
+	time.Sleep(20 * time.Millisecond)
+	dispatcher.EraseAccount("customer-42")
+	dispatcher.Wait()
+
+	deliveries, _ := store.Deliveries(subscription.Id)
+	for _, delivery := range deliveries {
+		if delivery.AccountId == "customer-42" {
+			test.Errorf("Got delivery %v after erasing customer-42", delivery)
+		}
+	}
+	if len(deliveries) != slowBackoff.MaxAttempts {
+		test.Errorf("Got %d deliveries, but expected %d for customer-43", len(deliveries), slowBackoff.MaxAttempts)
+	}
+	if deadLetters := store.DeadLetters(); len(deadLetters) != 1 || deadLetters[0].Event.AccountId != "customer-43" {
+		test.Errorf("Got dead letters %v, but expected one for customer-43", deadLetters)
+	}
+	if len(testReceiver.received) != slowBackoff.MaxAttempts+1 {
+		test.Errorf("Got %d requests, but expected %d", len(testReceiver.received), slowBackoff.MaxAttempts+1)
+	}
+}
+
+func TestDeleteSubscriptionStopsRetries(test *testing.T) {
+	// A subscription deleted between attempts isn't sent the rest, or dead-lettered
+	testReceiver := &receiver{failures: 10}
+	server := httptest.NewServer(testReceiver)
+	defer server.Close()
+	store := webhook_store.NewWebhookStore()
+	subscription, _ := store.Add(webhook.Subscription{Url: server.URL, Events: []string{webhook.EventReceiptDeleted}})
+
+	slowBackoff := webhook.Backoff{MaxAttempts: 3, InitialDelay: 50 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	dispatcher := webhook_dispatcher.NewDispatcher(store, slowBackoff)
+	dispatcher.SetAllowLoopback(true)
+	dispatcher.Publish(webhook.EventReceiptDeleted, "", map[string]string{"id": "receipt-1"})
+	time.Sleep(20 * time.Millisecond)
+	store.Delete(subscription.Id)
+	dispatcher.Wait()
+
+	if len(testReceiver.received) != 1 {
+		test.Errorf("Got %d requests, but expected only the one before deleting", len(testReceiver.received))
+	}
+	if deadLetters := store.DeadLetters(); len(deadLetters) != 0 {
+		test.Errorf("Got dead letters %v, but expected none", deadLetters)
+	}
+}
+
+func TestPublishPrivateReceivers(test *testing.T) {
+	// Without the loopback opt-in, a receiver on 127.0.0.1 is never reached, and redirects are never followed
+	testCases := []struct {
+		name          string
+		allowLoopback bool
+		redirect      bool
+	}{
+		{"loopback", false, false},
+		{"redirect", true, true},
+	}
+
+	for _, testCase := range testCases {
+		target := &receiver{}
+		targetServer := httptest.NewServer(target)
+		redirecting := httptest.NewServer(http.RedirectHandler(targetServer.URL, http.StatusTemporaryRedirect))
+		receiverUrl := targetServer.URL
+		if testCase.redirect {
+			receiverUrl = redirecting.URL
+		}
+		store := webhook_store.NewWebhookStore()
+		subscription, _ := store.Add(webhook.Subscription{Url: receiverUrl, Events: []string{webhook.EventReceiptDeleted}})
+
+		dispatcher := webhook_dispatcher.NewDispatcher(store, webhook.Backoff{MaxAttempts: 1})
+		dispatcher.SetAllowLoopback(testCase.allowLoopback)
+		dispatcher.Publish(webhook.EventReceiptDeleted, "", map[string]string{"id": "receipt-1"})
+		dispatcher.Wait()
+		redirecting.Close()
+		targetServer.Close()
+
+		if len(target.received) != 0 {
+			test.Errorf("Case '%s', got %d requests, but expected none", testCase.name, len(target.received))
+		}
+		deliveries, _ := store.Deliveries(subscription.Id)
+		if len(deliveries) != 1 || deliveries[0].Succeeded {
+			test.Errorf("Case '%s', got deliveries %v, but expected one failure", testCase.name, deliveries)
+		}
+	}
+}
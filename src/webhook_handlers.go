@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	currency "receipt_manager/currency"
+	receipt_processor "receipt_manager/point_calculator"
+	receipt "receipt_manager/receipt"
+	response_handler "receipt_manager/response_handler"
+	webhook "receipt_manager/webhook"
+	webhook_store "receipt_manager/webhook_store"
+
+	"github.com/gorilla/mux"
+)
+
+// The items of a receipt flagged totalMismatch don't add up to its total
+const flagTotalMismatch = "totalMismatch"
+
+type receiptProcessedData struct {
+	Id        string `json:"id"`
+	AccountId string `json:"accountId,omitempty"`
+	Points    int    `json:"points"`
+}
+
+type receiptFlaggedData struct {
+	Id         string   `json:"id"`
+	AccountId  string   `json:"accountId,omitempty"`
+	Reasons    []string `json:"reasons"`
+	Total      string   `json:"total"`
+	ItemsTotal string   `json:"itemsTotal"`
+}
+
+type receiptDeletedData struct {
+	Id        string `json:"id"`
+	AccountId string `json:"accountId,omitempty"`
+}
+
+func webhooksHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		response_handler.SendWebhooksResponse(webhookStore.List(), response)
+	case http.MethodPost:
+		createWebhook(response, request)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func createWebhook(response http.ResponseWriter, request *http.Request) {
+	subscription := webhook.Subscription{}
+	decoderError := json.NewDecoder(request.Body).Decode(&subscription)
+	if decoderError != nil {
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeRequestDecodingFailed, "Webhook data decoding failed")
+		return
+	}
+
+	storedSubscription, webhookError := webhookStore.Add(subscription)
+	if webhookError != nil {
+		handleWebhookError(response, webhookError)
+		return
+	}
+
+	response_handler.SendWebhookResponse(storedSubscription, response)
+}
+
+func webhookHandler(response http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+	switch request.Method {
+	case http.MethodGet:
+		storedSubscription, webhookError := webhookStore.Get(id)
+		if webhookError != nil {
+			handleWebhookError(response, webhookError)
+			return
+		}
+		response_handler.SendWebhookResponse(storedSubscription, response)
+	case http.MethodDelete:
+		webhookError := webhookStore.Delete(id)
+		if webhookError != nil {
+			handleWebhookError(response, webhookError)
+			return
+		}
+		response_handler.SendNoContentResponse(response)
+	default:
+		response_handler.HandleMethodNotAllowed(response)
+	}
+}
+
+func getWebhookDeliveriesHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	deliveries, webhookError := webhookStore.Deliveries(mux.Vars(request)["id"])
+	if webhookError != nil {
+		handleWebhookError(response, webhookError)
+		return
+	}
+	response_handler.SendDeliveriesResponse(deliveries, response)
+}
+
+func getDeadLettersHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		response_handler.HandleMethodNotAllowed(response)
+		return
+	}
+
+	response_handler.SendDeadLettersResponse(webhookStore.DeadLetters(), response)
+}
+
+func handleWebhookError(response http.ResponseWriter, webhookError error) {
+	switch webhookError {
+	case webhook_store.ErrSubscriptionNotFound:
+		response_handler.HandleNotFoundError(response, response_handler.ErrorCodeWebhookNotFound, "The requested webhook doesn't exist")
+	case webhook.ErrInvalidSubscription:
+		response_handler.HandleBadRequestError(response, response_handler.ErrorCodeInvalidWebhook, "Webhook needs an absolute http(s) url and one or more known events")
+	default:
+		response_handler.HandleInternalServerError(response)
+	}
+}
+
+func publishReceiptScored(id string, scoredReceipt receipt.Receipt, breakdown receipt_processor.Breakdown) {
+	// Sent whenever a receipt is stored with new points, by any transport
+	publishEvent(webhook.EventReceiptProcessed, scoredReceipt.AccountId, receiptProcessedData{Id: id, AccountId: scoredReceipt.AccountId, Points: breakdown.Points})
+
+	if itemsTotal, mismatched := totalMismatch(scoredReceipt); mismatched {
+		publishEvent(webhook.EventReceiptFlagged, scoredReceipt.AccountId, receiptFlaggedData{
+			Id:         id,
+			AccountId:  scoredReceipt.AccountId,
+			Reasons:    []string{flagTotalMismatch},
+			Total:      scoredReceipt.Total,
+			ItemsTotal: itemsTotal,
+		})
+	}
+}
+
+func publishReceiptDeleted(id string, accountId string) {
+	publishEvent(webhook.EventReceiptDeleted, accountId, receiptDeletedData{Id: id, AccountId: accountId})
+}
+
+func publishEvent(eventType string, accountId string, data interface{}) {
+	// Deliveries run in the background; a failure to publish is logged rather than failing the request
+	if _, publishError := webhookDispatcher.Publish(eventType, accountId, data); publishError != nil {
+		log.Printf("Publishing %s failed: %v", eventType, publishError)
+	}
+}
+
+func totalMismatch(scoredReceipt receipt.Receipt) (string, bool) {
+	// Returns the sum of the item prices when it differs from the total, in the receipt's currency
+	receiptCurrency, currencyError := currency.Lookup(scoredReceipt.Currency)
+	if currencyError != nil {
+		return "", false
+	}
+	total, totalError := receiptCurrency.ParseAmount(scoredReceipt.Total)
+	if totalError != nil {
+		return "", false
+	}
+
+	itemsTotal := int64(0)
+	for _, receiptItem := range scoredReceipt.Items {
+		price, priceError := receiptCurrency.ParseAmount(receiptItem.Price)
+		if priceError != nil {
+			return "", false
+		}
+		itemsTotal += price
+	}
+	if itemsTotal == total {
+		return "", false
+	}
+	return receiptCurrency.FormatAmount(itemsTotal), true
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	webhook "receipt_manager/webhook"
+	webhook_dispatcher "receipt_manager/webhook_dispatcher"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type receivedEvent struct {
+	event     webhook.Event
+	data      map[string]interface{}
+	signature string
+	body      []byte
+}
+
+func TestWebhookEvents(test *testing.T) {
+	/*
+		Subscribes a local receiver to every event, then processes, amends and deletes
+		receipts through the API, checking what arrives and how it's signed
+	*/
+	resetStores()
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+
+	var mutex sync.Mutex
+	received := []receivedEvent{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		var event webhook.Event
+		json.Unmarshal(body, &event)
+		data, _ := event.Data.(map[string]interface{})
+
+		mutex.Lock()
+		received = append(received, receivedEvent{event: event, data: data, signature: request.Header.Get(webhook.SignatureHeader), body: body})
+		mutex.Unlock()
+		response.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	subscription := webhook.Subscription{}
+	recorder := serveTestRequest(router, "POST", "/v1/webhooks", `{"url": "`+receiver.URL+`", "events": ["receipt.processed", "receipt.flagged", "receipt.deleted"]}`)
+	json.Unmarshal(recorder.Body.Bytes(), &subscription)
+	if recorder.Code != http.StatusOK || subscription.Secret == "" {
+		test.Fatalf("Subscribing, got status %d and %s", recorder.Code, recorder.Body.String())
+	}
+
+	// The first receipt adds up; the second's items come to less than its total
+	balanced := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "6.75", "accountId": "customer-42",
+		"items": [{"shortDescription": "Gatorade 32oz", "price": "6.75", "quantity": 3}]}`
+	unbalanced := strings.Replace(strings.Replace(balanced, "2022-01-01", "2022-01-02", 1), `"price": "6.75"`, `"price": "2.25"`, 1)
+	ids := []string{}
+	for _, body := range []string{balanced, unbalanced} {
+		recorder := serveTestRequest(router, "POST", "/v1/receipts/process", body)
+		var idResponse struct{ Id string }
+		json.Unmarshal(recorder.Body.Bytes(), &idResponse)
+		if recorder.Code != http.StatusOK {
+			test.Fatalf("Processing a receipt, got status %d and %s", recorder.Code, recorder.Body.String())
+		}
+		ids = append(ids, idResponse.Id)
+	}
+	webhookDispatcher.Wait()
+	if recorder := serveTestRequest(router, "DELETE", "/v1/receipts/"+ids[0], ""); recorder.Code != http.StatusNoContent {
+		test.Fatalf("Deleting a receipt, got status %d", recorder.Code)
+	}
+	webhookDispatcher.Wait()
+
+	expectedEvents := []struct {
+		eventType string
+		id        string
+		field     string
+		value     interface{}
+	}{
+		{webhook.EventReceiptProcessed, ids[0], "points", float64(42)},
+		{webhook.EventReceiptProcessed, ids[1], "accountId", "customer-42"},
+		{webhook.EventReceiptFlagged, ids[1], "itemsTotal", "2.25"},
+		{webhook.EventReceiptDeleted, ids[0], "accountId", "customer-42"},
+	}
+	if len(received) != len(expectedEvents) {
+		test.Fatalf("Got %d events, but expected %d", len(received), len(expectedEvents))
+	}
+	for _, expected := range expectedEvents {
+		found := false
+		for _, event := range received {
+			if event.event.Type == expected.eventType && event.data["id"] == expected.id {
+				found = true
+				if event.data[expected.field] != expected.value {
+					test.Errorf("Event %s for %s, got %s %v, but expected %v", expected.eventType, expected.id, expected.field, event.data[expected.field], expected.value)
+				}
+				if signatureError := webhook.Verify(subscription.Secret, event.signature, event.body, time.Minute, time.Now()); signatureError != nil {
+					test.Errorf("Event %s for %s, signature check failed with error: %v", expected.eventType, expected.id, signatureError)
+				}
+			}
+		}
+		if !found {
+			test.Errorf("Event %s for %s wasn't received", expected.eventType, expected.id)
+		}
+	}
+
+	// Every delivery succeeded at the first attempt, and the secret isn't shown again
+	var deliveries struct{ Deliveries []webhook.Delivery }
+	json.Unmarshal(serveTestRequest(router, "GET", "/v1/webhooks/"+subscription.Id+"/deliveries", "").Body.Bytes(), &deliveries)
+	if len(deliveries.Deliveries) != len(expectedEvents) {
+		test.Errorf("Delivery log, got %d deliveries, but expected %d", len(deliveries.Deliveries), len(expectedEvents))
+	}
+	for _, delivery := range deliveries.Deliveries {
+		if !delivery.Succeeded || delivery.Attempt != 1 || delivery.StatusCode != http.StatusNoContent {
+			test.Errorf("Delivery log, got %v", delivery)
+		}
+	}
+	if body := serveTestRequest(router, "GET", "/v1/webhooks/"+subscription.Id, "").Body.String(); strings.Contains(body, subscription.Secret) {
+		test.Errorf("Getting the subscription shows its secret: %s", body)
+	}
+}
+
+func TestWebhookDeadLetters(test *testing.T) {
+	resetStores()
+	webhookDispatcher = webhook_dispatcher.NewDispatcher(webhookStore, webhook.Backoff{MaxAttempts: 2, InitialDelay: time.Millisecond})
+	webhookDispatcher.SetAllowLoopback(true)
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+	receiver := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	serveTestRequest(router, "POST", "/v1/webhooks", `{"url": "`+receiver.URL+`", "events": ["receipt.processed"]}`)
+	serveTestRequest(router, "POST", "/v1/receipts/process", specReceipt+`}`)
+	webhookDispatcher.Wait()
+
+	var deadLetters struct{ DeadLetters []webhook.DeadLetter }
+	json.Unmarshal(serveTestRequest(router, "GET", "/v1/webhooks/dead-letters", "").Body.Bytes(), &deadLetters)
+	if len(deadLetters.DeadLetters) != 1 {
+		test.Fatalf("Got %d dead letters, but expected 1", len(deadLetters.DeadLetters))
+	}
+	deadLetter := deadLetters.DeadLetters[0]
+	if deadLetter.Attempts != 2 || deadLetter.Event.Type != webhook.EventReceiptProcessed || deadLetter.LastError == "" {
+		test.Errorf("Got dead letter %v, but expected a receipt.processed event after 2 attempts", deadLetter)
+	}
+}
+
+func TestEraseAccountForgetsIt(test *testing.T) {
+	// After erasure neither dead letters nor kept idempotent responses mention the account
+	resetStores()
+	webhookDispatcher = webhook_dispatcher.NewDispatcher(webhookStore, webhook.Backoff{MaxAttempts: 1})
+	webhookDispatcher.SetAllowLoopback(true)
+	router, routerError := newRouter()
+	if routerError != nil {
+		test.Fatalf("Routing api.yml failed with error: %v", routerError)
+	}
+	receiver := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	serveTestRequest(router, "POST", "/v1/webhooks", `{"url": "`+receiver.URL+`", "events": ["receipt.processed", "receipt.deleted"]}`)
+
+	submit := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", "/v2/receipts/process", strings.NewReader(specReceipt+`, "accountId": "customer-42"}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(idempotencyKeyHeader, "order-1")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	if recorder := submit(); recorder.Code != http.StatusCreated {
+		test.Fatalf("Submitting the receipt, got status %d and %s", recorder.Code, recorder.Body.String())
+	}
+	webhookDispatcher.Wait()
+	if recorder := serveTestRequest(router, "DELETE", "/v2/accounts/customer-42", ""); recorder.Code != http.StatusNoContent {
+		test.Fatalf("Erasing the account, got status %d, but expected 204", recorder.Code)
+	}
+	webhookDispatcher.Wait()
+
+	deadLetters := serveTestRequest(router, "GET", "/v1/webhooks/dead-letters", "").Body.String()
+	if strings.Contains(deadLetters, "customer-42") || !strings.Contains(deadLetters, webhook.EventReceiptDeleted) {
+		test.Errorf("Got dead letters %s, but expected only the anonymous receipt.deleted event", deadLetters)
+	}
+	if recorder := submit(); recorder.Header().Get("Idempotent-Replayed") != "" {
+		test.Errorf("Retrying after erasure, got the response kept from before: %s", recorder.Body.String())
+	}
+}
+
+func serveTestRequest(router http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
@@ -0,0 +1,195 @@
+package receipt_manager
+
+import (
+	"errors"
+	webhook "receipt_manager/webhook"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// Only the most recent attempts are kept in each subscription's delivery log,
+// and only the most recent dead letters overall
+const (
+	maxDeliveries  = 100
+	maxDeadLetters = 1000
+)
+
+type WebhookStore struct {
+	mutex              sync.RWMutex
+	subscriptions      []webhook.Subscription
+	lastSubscriptionId int
+	deliveries         map[string][]webhook.Delivery
+	lastDeliveryId     int
+	deadLetters        []webhook.DeadLetter
+	lastDeadLetterId   int
+}
+
+func NewWebhookStore() *WebhookStore {
+	return &WebhookStore{
+		deliveries: make(map[string][]webhook.Delivery),
+	}
+}
+
+func (store *WebhookStore) Add(subscription webhook.Subscription) (webhook.Subscription, error) {
+	// A subscription without a secret gets a generated one, returned only here
+	if err := subscription.Validate(); err != nil {
+		return webhook.Subscription{}, err
+	}
+	if subscription.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			return webhook.Subscription{}, err
+		}
+		subscription.Secret = secret
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.lastSubscriptionId++
+	subscription.Id = "webhook-" + strconv.Itoa(store.lastSubscriptionId)
+	subscription.Events = append([]string{}, subscription.Events...)
+	subscription.CreatedAt = time.Now().UTC()
+	store.subscriptions = append(store.subscriptions, subscription)
+	store.deliveries[subscription.Id] = []webhook.Delivery{}
+	return subscription, nil
+}
+
+func (store *WebhookStore) List() []webhook.Subscription {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	subscriptions := []webhook.Subscription{}
+	for _, subscription := range store.subscriptions {
+		subscription.Secret = ""
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions
+}
+
+func (store *WebhookStore) Get(id string) (webhook.Subscription, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, subscription := range store.subscriptions {
+		if subscription.Id == id {
+			subscription.Secret = ""
+			return subscription, nil
+		}
+	}
+	return webhook.Subscription{}, ErrSubscriptionNotFound
+}
+
+func (store *WebhookStore) Delete(id string) error {
+	// Dead letters outlive the subscription, so failed events can still be found
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for index, subscription := range store.subscriptions {
+		if subscription.Id == id {
+			store.subscriptions = append(store.subscriptions[:index:index], store.subscriptions[index+1:]...)
+			delete(store.deliveries, id)
+			return nil
+		}
+	}
+	return ErrSubscriptionNotFound
+}
+
+func (store *WebhookStore) Subscribers(eventType string) []webhook.Subscription {
+	// Unlike List, these keep their secrets, for signing deliveries
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	subscribers := []webhook.Subscription{}
+	for _, subscription := range store.subscriptions {
+		if subscription.Wants(eventType) {
+			subscribers = append(subscribers, subscription)
+		}
+	}
+	return subscribers
+}
+
+func (store *WebhookStore) RecordDelivery(delivery webhook.Delivery) webhook.Delivery {
+	// Attempts for a subscription deleted mid-delivery are dropped along with its log
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.lastDeliveryId++
+	delivery.Id = "delivery-" + strconv.Itoa(store.lastDeliveryId)
+	deliveries, subscribed := store.deliveries[delivery.SubscriptionId]
+	if !subscribed {
+		return delivery
+	}
+	deliveries = append(deliveries, delivery)
+	if len(deliveries) > maxDeliveries {
+		deliveries = deliveries[len(deliveries)-maxDeliveries:]
+	}
+	store.deliveries[delivery.SubscriptionId] = deliveries
+	return delivery
+}
+
+func (store *WebhookStore) Deliveries(subscriptionId string) ([]webhook.Delivery, error) {
+	// The log is newest first
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	deliveries, subscribed := store.deliveries[subscriptionId]
+	if !subscribed {
+		return nil, ErrSubscriptionNotFound
+	}
+	newestFirst := make([]webhook.Delivery, 0, len(deliveries))
+	for index := len(deliveries) - 1; index >= 0; index-- {
+		newestFirst = append(newestFirst, deliveries[index])
+	}
+	return newestFirst, nil
+}
+
+func (store *WebhookStore) AddDeadLetter(deadLetter webhook.DeadLetter) webhook.DeadLetter {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.lastDeadLetterId++
+	deadLetter.Id = "dead-letter-" + strconv.Itoa(store.lastDeadLetterId)
+	store.deadLetters = append(store.deadLetters, deadLetter)
+	if len(store.deadLetters) > maxDeadLetters {
+		store.deadLetters = store.deadLetters[len(store.deadLetters)-maxDeadLetters:]
+	}
+	return deadLetter
+}
+
+func (store *WebhookStore) DeadLetters() []webhook.DeadLetter {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return append([]webhook.DeadLetter{}, store.deadLetters...)
+}
+
+func (store *WebhookStore) EraseAccount(accountId string) {
+	// Drops every delivery attempt and dead letter for the account's events
+	if accountId == "" {
+		return
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for subscriptionId, deliveries := range store.deliveries {
+		keptDeliveries := []webhook.Delivery{}
+		for _, delivery := range deliveries {
+			if delivery.AccountId != accountId {
+				keptDeliveries = append(keptDeliveries, delivery)
+			}
+		}
+		store.deliveries[subscriptionId] = keptDeliveries
+	}
+
+	keptDeadLetters := []webhook.DeadLetter{}
+	for _, deadLetter := range store.deadLetters {
+		if deadLetter.Event.AccountId != accountId {
+			keptDeadLetters = append(keptDeadLetters, deadLetter)
+		}
+	}
+	store.deadLetters = keptDeadLetters
+}
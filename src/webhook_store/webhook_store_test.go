@@ -0,0 +1,46 @@
+package receipt_manager_test
+
+import (
+	webhook "receipt_manager/webhook"
+	webhook_store "receipt_manager/webhook_store"
+	"testing"
+)
+
+func TestDeadLettersCapped(test *testing.T) {
+	// The oldest dead letters are dropped once there are more than a thousand
+	store := webhook_store.NewWebhookStore()
+	for index := 0; index < 1005; index++ {
+		store.AddDeadLetter(webhook.DeadLetter{SubscriptionId: "webhook-1"})
+	}
+
+	deadLetters := store.DeadLetters()
+	if len(deadLetters) != 1000 {
+		test.Fatalf("Got %d dead letters, but expected 1000", len(deadLetters))
+	}
+	if deadLetters[0].Id != "dead-letter-6" || deadLetters[999].Id != "dead-letter-1005" {
+		test.Errorf("Got dead letters %s to %s, but expected dead-letter-6 to dead-letter-1005", deadLetters[0].Id, deadLetters[999].Id)
+	}
+}
+
+func TestEraseAccount(test *testing.T) {
+	// Only the erased account's deliveries and dead letters are dropped
+	store := webhook_store.NewWebhookStore()
+	subscription, _ := store.Add(webhook.Subscription{Url: "https://example.com/hook", Events: []string{webhook.EventReceiptDeleted}})
+	for _, accountId := range []string{"customer-42", "customer-43", ""} {
+		store.RecordDelivery(webhook.Delivery{SubscriptionId: subscription.Id, AccountId: accountId})
+		store.AddDeadLetter(webhook.DeadLetter{SubscriptionId: subscription.Id, Event: webhook.Event{AccountId: accountId}})
+	}
+
+	store.EraseAccount("customer-42")
+
+	deliveries, _ := store.Deliveries(subscription.Id)
+	deadLetters := store.DeadLetters()
+	if len(deliveries) != 2 || len(deadLetters) != 2 {
+		test.Fatalf("Got %d deliveries and %d dead letters, but expected 2 of each", len(deliveries), len(deadLetters))
+	}
+	for index := range deliveries {
+		if deliveries[index].AccountId == "customer-42" || deadLetters[index].Event.AccountId == "customer-42" {
+			test.Errorf("Got delivery %v and dead letter %v after erasing customer-42", deliveries[index], deadLetters[index])
+		}
+	}
+}